@@ -0,0 +1,206 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// --- Per-Site Adapters ---
+// A SiteAdapter is everything site-specific: how a site's product URLs
+// look, where its next-page control is, how its listings load more
+// products and how its listing and product pages are read. Settings in
+// the crawl config win over the adapter, and the adapter over the generic
+// built-in behavior. Adapters are registered from init functions, under a
+// host or a registrable domain, which covers all of its hosts; Match lets
+// one adapter also claim a site's other country domains, such as
+// amazon.in for the amazon.com adapter. A SiteExtractor registered for a
+// host reads its pages in place of the adapter.
+type SiteAdapter interface {
+	Match(domain string) bool
+	// ProductURLPatterns replace the built-in product URL regex; nil keeps
+	// it. The same rules as the productPatterns setting apply.
+	ProductURLPatterns() []*regexp.Regexp
+	// NextPageSelector is the listing's next-page control; "" keeps the
+	// default.
+	NextPageSelector() string
+	// ScrollStrategy is strategyPaginate, strategyScroll or strategyNone,
+	// or "" to probe each listing page.
+	ScrollStrategy() string
+	// ExtractURLs returns the product URLs of a listing page for the
+	// default extractor; the crawler filters and deduplicates them. cfg is
+	// the host's config with the adapter's defaults filled in.
+	ExtractURLs(htmlContent, pageURL string, cfg DomainConfig) ([]string, error)
+	ExtractProduct(htmlContent, pageURL string, cfg DomainConfig) Product
+}
+
+// siteAdapters is filled by init functions and only read afterwards.
+var siteAdapters = make(map[string]SiteAdapter)
+
+func registerAdapter(host string, adapter SiteAdapter) {
+	siteAdapters[strings.ToLower(host)] = adapter
+}
+
+// --- Adapter for a Host ---
+// An adapter registered for the exact host wins, then one for its
+// registrable domain, then the first adapter whose Match accepts the
+// domain, in registration key order so the choice is stable, and finally
+// the generic adapter.
+func adapterFor(host string) SiteAdapter {
+	host = strings.ToLower(host)
+	if adapter, ok := siteAdapters[host]; ok {
+		return adapter
+	}
+	domain := registrableDomain(host)
+	if adapter, ok := siteAdapters[domain]; ok {
+		return adapter
+	}
+	var matched string
+	for registered, adapter := range siteAdapters {
+		if adapter.Match(domain) && (matched == "" || registered < matched) {
+			matched = registered
+		}
+	}
+	if matched != "" {
+		return siteAdapters[matched]
+	}
+	return genericAdapter{}
+}
+
+// --- Fill Unset Settings from the Host's Adapter ---
+func (d DomainConfig) withAdapter(host string) DomainConfig {
+	adapter := adapterFor(host)
+	if len(d.patterns) == 0 {
+		d.patterns = adapter.ProductURLPatterns()
+	}
+	if d.NextPageSelector == "" {
+		d.NextPageSelector = adapter.NextPageSelector()
+	}
+	if d.ListingStrategy == "" {
+		d.ListingStrategy = adapter.ScrollStrategy()
+	}
+	return d
+}
+
+// --- Generic Adapter ---
+// Used for sites without an adapter of their own: built-in product URL
+// pattern matched against the raw HTML, default next-page selector,
+// probed listing strategy and selector-based product extraction. It
+// matches no domain, so adapters embedding it only claim their own.
+type genericAdapter struct{}
+
+func (genericAdapter) Match(string) bool                    { return false }
+func (genericAdapter) ProductURLPatterns() []*regexp.Regexp { return nil }
+func (genericAdapter) NextPageSelector() string             { return "" }
+func (genericAdapter) ScrollStrategy() string               { return "" }
+
+func (genericAdapter) ExtractURLs(htmlContent, pageURL string, cfg DomainConfig) ([]string, error) {
+	return extractProductURLs(htmlContent, pageURL, cfg), nil
+}
+
+func (genericAdapter) ExtractProduct(htmlContent, pageURL string, cfg DomainConfig) Product {
+	return extractProductDetails(htmlContent, pageURL, cfg)
+}
+
+// --- Selector Defaults ---
+// Built-in adapters read product pages like the generic adapter, with
+// their own selectors filling in the ones the config leaves unset.
+type selectorDefaults struct {
+	title, brand, price, image, sku string
+}
+
+func (s selectorDefaults) apply(cfg DomainConfig) DomainConfig {
+	for _, field := range []struct {
+		value    *string
+		fallback string
+	}{
+		{&cfg.TitleSelector, s.title},
+		{&cfg.BrandSelector, s.brand},
+		{&cfg.PriceSelector, s.price},
+		{&cfg.ImageSelector, s.image},
+		{&cfg.SKUSelector, s.sku},
+	} {
+		if *field.value == "" {
+			*field.value = field.fallback
+		}
+	}
+	return cfg
+}
+
+// --- Built-In Adapters ---
+// One per default seed site. Their selectors describe the sites' markup at
+// the time of writing; the config can override any of them.
+type builtinAdapter struct {
+	match     func(domain string) bool
+	patterns  []*regexp.Regexp
+	next      string
+	strategy  string
+	selectors selectorDefaults
+	hrefs     bool // match patterns against each link's href, not the raw HTML
+}
+
+func (a builtinAdapter) Match(domain string) bool             { return a.match(domain) }
+func (a builtinAdapter) ProductURLPatterns() []*regexp.Regexp { return a.patterns }
+func (a builtinAdapter) NextPageSelector() string             { return a.next }
+func (a builtinAdapter) ScrollStrategy() string               { return a.strategy }
+
+func (a builtinAdapter) ExtractURLs(htmlContent, pageURL string, cfg DomainConfig) ([]string, error) {
+	if a.hrefs {
+		return extractLinkedProductURLs(htmlContent, pageURL, cfg)
+	}
+	return extractProductURLs(htmlContent, pageURL, cfg), nil
+}
+
+func (a builtinAdapter) ExtractProduct(htmlContent, pageURL string, cfg DomainConfig) Product {
+	return extractProductDetails(htmlContent, pageURL, a.selectors.apply(cfg))
+}
+
+func init() {
+	// Amazon: ten-character ASINs under /dp/ or /gp/product/, numbered
+	// search result pages. amazon.in, amazon.co.uk and the rest share it.
+	// Links often end right after the ASIN, as in href="/dp/B0XXXXXXXX",
+	// so the pattern is matched against each href, where "$" finds that
+	// end, and sitemap <loc>s are matched one by one anyway.
+	registerAdapter("amazon.com", builtinAdapter{
+		match: func(domain string) bool { return strings.HasPrefix(domain, "amazon.") },
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`(https?://[a-zA-Z0-9.-]+)?/(dp|gp/product)/[A-Z0-9]{10}(/|\?|$)`),
+		},
+		hrefs:    true,
+		next:     "a.s-pagination-next",
+		strategy: strategyPaginate,
+		selectors: selectorDefaults{
+			title: "#productTitle",
+			brand: "#bylineInfo",
+			price: "#corePrice_feature_div .a-offscreen",
+			image: "#landingImage",
+		},
+	})
+	// Snapdeal: /product/<slug>/<numeric id>, listings that load more
+	// results while scrolling.
+	registerAdapter("snapdeal.com", builtinAdapter{
+		match: func(domain string) bool { return domain == "snapdeal.com" },
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`(https?://[a-zA-Z0-9.-]+)?/product/[a-z0-9-]+/[0-9]{6,}`),
+		},
+		strategy: strategyScroll,
+		selectors: selectorDefaults{
+			title: "h1.pdp-e-i-head",
+			price: "span.payBlkBig",
+			image: "img.cloudzoom",
+		},
+	})
+	// Myntra: /<category>/<brand>/<slug>/<numeric id>/buy, numbered pages.
+	registerAdapter("myntra.com", builtinAdapter{
+		match: func(domain string) bool { return domain == "myntra.com" },
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`(https?://[a-zA-Z0-9.-]+)?/[a-z0-9-]+/[a-z0-9-]+/[a-z0-9-]+/[0-9]+/buy`),
+		},
+		next:     "li.pagination-next a",
+		strategy: strategyPaginate,
+		selectors: selectorDefaults{
+			title: "h1.pdp-name",
+			brand: "h1.pdp-title",
+			price: "span.pdp-price strong",
+		},
+	})
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// --- Crawl Audit Trail ---
+// Lifecycle events are appended as JSON lines to a file of their own,
+// separate from the operational log, so the trail is complete regardless
+// of -log-level. Every line carries the run ID; the message is the event:
+//
+//	{"time":"...","level":"INFO","msg":"domain_started","run_id":"...","url":"..."}
+//
+// Events: run_started, domain_started, page_loaded, page_skipped,
+// page_failed, store_failed, domain_finished, run_finished.
+
+// --- Open the Audit Log ---
+// The file is only ever appended to. An empty path discards all events.
+func openAuditLog(path, runID string) (*slog.Logger, io.Closer, error) {
+	if path == "" {
+		return slog.New(slog.NewJSONHandler(io.Discard, nil)), io.NopCloser(nil), nil
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open audit log: %w", err)
+	}
+	return slog.New(slog.NewJSONHandler(file, nil)).With("run_id", runID), file, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAuditLogRecordsCrawlLifecycle(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "User-agent: *\nDisallow: /private/\n")
+	})
+	mux.HandleFunc("/phones", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<a href="/dp/B0AAAAAAAA/">Phone</a>`)
+	})
+	site := httptest.NewServer(mux)
+	defer site.Close()
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	audit, closer, err := openAuditLog(path, "run-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newTestCrawler(t)
+	c.audit = audit
+	c.robots = newRobotsPolicy(nil)
+	for _, page := range []string{"/phones", "/private/deals", "/missing", "/phones"} {
+		crawlPage(t, c, site.URL+page)
+	}
+	if err := closer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var events []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event struct {
+			Msg    string `json:"msg"`
+			RunID  string `json:"run_id"`
+			URL    string `json:"url"`
+			Reason string `json:"reason"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("audit line %q is not JSON: %v", scanner.Text(), err)
+		}
+		if event.RunID != "run-1" {
+			t.Errorf("%s: run_id = %q, want run-1", event.Msg, event.RunID)
+		}
+		name := event.Msg + " " + event.URL[len(site.URL):]
+		if event.Reason != "" {
+			name += " " + event.Reason
+		}
+		events = append(events, name)
+	}
+	want := []string{
+		"domain_started /phones",
+		"page_loaded /phones",
+		"domain_finished /phones",
+		"domain_started /private/deals",
+		"page_skipped /private/deals robots",
+		"domain_started /missing",
+		"page_failed /missing",
+		"domain_started /phones",
+		"page_skipped /phones already_visited",
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("audit events:\n%q\nwant\n%q", events, want)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// --- Per-Domain Login ---
+// Some catalogs are only visible after logging in. Credentials are never
+// stored in the config; it only names the environment variables holding
+// them.
+//
+//	"login": {
+//		"url": "https://b2b.example.com/login",
+//		"usernameSelector": "#email", "passwordSelector": "#password",
+//		"submitSelector": "button[type=submit]", "successSelector": ".account-menu",
+//		"usernameEnv": "EXAMPLE_USER", "passwordEnv": "EXAMPLE_PASSWORD"
+//	}
+type LoginConfig struct {
+	URL              string `json:"url"`
+	UsernameSelector string `json:"usernameSelector"`
+	PasswordSelector string `json:"passwordSelector"`
+	SubmitSelector   string `json:"submitSelector"`  // empty submits the password field's form
+	SuccessSelector  string `json:"successSelector"` // only present once logged in
+	UsernameEnv      string `json:"usernameEnv"`
+	PasswordEnv      string `json:"passwordEnv"`
+}
+
+// --- Validate Login Settings ---
+func (l *LoginConfig) validate() error {
+	switch {
+	case l.URL == "":
+		return fmt.Errorf("login url is required")
+	case l.UsernameSelector == "" || l.PasswordSelector == "":
+		return fmt.Errorf("login usernameSelector and passwordSelector are required")
+	case l.SuccessSelector == "":
+		return fmt.Errorf("login successSelector is required to verify the login")
+	case l.UsernameEnv == "" || l.PasswordEnv == "":
+		return fmt.Errorf("login usernameEnv and passwordEnv are required")
+	}
+	return nil
+}
+
+// --- Authenticated Session Cache ---
+// Every page load starts a fresh browser, so the cookies of one successful
+// login per domain are replayed into each new browser instead. mu is held
+// through a login, so pages waiting on one share its outcome.
+type loginSession struct {
+	mu      sync.Mutex
+	done    bool // the outcome below is final
+	cookies []*network.CookieParam
+	err     error
+}
+
+type sessionCache struct {
+	mu       sync.Mutex
+	sessions map[string]*loginSession
+}
+
+func newSessionCache() *sessionCache {
+	return &sessionCache{sessions: make(map[string]*loginSession)}
+}
+
+func (s *sessionCache) get(key string) *loginSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[key]
+	if !ok {
+		session = &loginSession{}
+		s.sessions[key] = session
+	}
+	return session
+}
+
+// --- Session Cookies for a Host ---
+// Logs in on first use; hosts without a login config get no cookies. A
+// failed login is remembered so the domain is not retried on every page.
+// The login runs under its own crawlTimeout, not the asking page's, and a
+// login that timed out or was cancelled is tried again by the next page.
+func (c *Crawler) sessionCookies(ctx context.Context, host string) ([]*network.CookieParam, error) {
+	cfg := c.config.forHost(host)
+	if cfg.Login == nil {
+		return nil, nil
+	}
+
+	session := c.sessions.get(registrableDomain(host))
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if !session.done {
+		loginCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cfg.crawlTimeout())
+		cookies, err := c.logIn(loginCtx, host, cfg.Login)
+		cancel()
+		switch {
+		case err == nil:
+			slog.Info("Logged in", "host", host, "cookies", len(cookies))
+			session.cookies, session.err, session.done = cookies, nil, true
+		case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+			slog.Warn("Login interrupted, retrying on the next page", "host", host, "url", cfg.Login.URL, "error", err)
+			return nil, fmt.Errorf("login for %s failed: %w", host, err)
+		default:
+			slog.Error("Login failed", "host", host, "url", cfg.Login.URL, "error", err)
+			session.cookies, session.err, session.done = nil, err, true
+		}
+	}
+	if session.err != nil {
+		return nil, fmt.Errorf("login for %s failed: %w", host, session.err)
+	}
+	return session.cookies, nil
+}
+
+// --- Log In and Capture the Session Cookies ---
+func (c *Crawler) logIn(ctx context.Context, host string, login *LoginConfig) ([]*network.CookieParam, error) {
+	username, password := os.Getenv(login.UsernameEnv), os.Getenv(login.PasswordEnv)
+	if username == "" || password == "" {
+		return nil, fmt.Errorf("credentials missing: set %s and %s", login.UsernameEnv, login.PasswordEnv)
+	}
+
+	browserCtx, setup, cancel := c.newBrowser(ctx, host, "")
+	defer cancel()
+
+	submit := chromedp.Submit(login.PasswordSelector, chromedp.ByQuery)
+	if login.SubmitSelector != "" {
+		submit = chromedp.Click(login.SubmitSelector, chromedp.ByQuery)
+	}
+	err := chromedp.Run(browserCtx,
+		setup,
+		chromedp.Navigate(login.URL),
+		chromedp.WaitVisible(login.UsernameSelector, chromedp.ByQuery),
+		chromedp.SendKeys(login.UsernameSelector, username, chromedp.ByQuery),
+		chromedp.SendKeys(login.PasswordSelector, password, chromedp.ByQuery),
+		submit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("submit login form: %w", err)
+	}
+	if err := chromedp.Run(browserCtx, chromedp.WaitVisible(login.SuccessSelector, chromedp.ByQuery)); err != nil {
+		return nil, fmt.Errorf("post-login selector %q never appeared: %w", login.SuccessSelector, err)
+	}
+
+	var cookies []*network.Cookie
+	err = chromedp.Run(browserCtx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		cookies, err = network.GetCookies().Do(ctx)
+		return err
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("read session cookies: %w", err)
+	}
+
+	params := make([]*network.CookieParam, 0, len(cookies))
+	for _, cookie := range cookies {
+		params = append(params, &network.CookieParam{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Domain:   cookie.Domain,
+			Path:     cookie.Path,
+			Secure:   cookie.Secure,
+			HTTPOnly: cookie.HTTPOnly,
+			SameSite: cookie.SameSite,
+		})
+	}
+	return params, nil
+}
+
+// --- Attach Session Cookies to a Plain HTTP Request ---
+// Only the cookies a browser would send to req.URL: its host matches the
+// cookie's domain, its path is under the cookie's path and Secure cookies
+// need https. Cookies without a domain match no host.
+func addSessionCookies(req *http.Request, cookies []*network.CookieParam) {
+	for _, cookie := range cookies {
+		if cookieMatches(cookie, req.URL) {
+			req.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+		}
+	}
+}
+
+// --- Match a Cookie Against a Request URL ---
+// A domain with a leading dot also covers the hosts below it; one without
+// is host-only, as Chrome reports them.
+func cookieMatches(cookie *network.CookieParam, u *url.URL) bool {
+	if cookie.Secure && u.Scheme != "https" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	domain := strings.ToLower(cookie.Domain)
+	if parent, ok := strings.CutPrefix(domain, "."); ok {
+		if host != parent && !strings.HasSuffix(host, domain) {
+			return false
+		}
+	} else if domain == "" || host != domain {
+		return false
+	}
+
+	cookiePath, requestPath := cookie.Path, u.Path
+	if cookiePath == "" {
+		cookiePath = "/"
+	}
+	if requestPath == "" {
+		requestPath = "/"
+	}
+	return requestPath == cookiePath || strings.HasPrefix(requestPath, strings.TrimSuffix(cookiePath, "/")+"/")
+}
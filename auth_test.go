@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/chromedp/cdproto/network"
+)
+
+func TestAddSessionCookiesMatchesDomainPathAndScheme(t *testing.T) {
+	cookies := []*network.CookieParam{
+		{Name: "session", Value: "s", Domain: "b2b.example.com", Path: "/"},
+		{Name: "shared", Value: "d", Domain: ".example.com", Path: "/"},
+		{Name: "catalog", Value: "c", Domain: "b2b.example.com", Path: "/catalog"},
+		{Name: "secure", Value: "x", Domain: "b2b.example.com", Path: "/", Secure: true},
+		{Name: "unscoped", Value: "u"},
+	}
+	tests := []struct {
+		url  string
+		want []string
+	}{
+		{"https://b2b.example.com/catalog/tools", []string{"session", "shared", "catalog", "secure"}},
+		{"https://b2b.example.com/catalogue", []string{"session", "shared", "secure"}},
+		{"http://b2b.example.com/", []string{"session", "shared"}},
+		{"https://cdn.example.com/catalog/tools", []string{"shared"}},
+		{"https://example.com", []string{"shared"}},
+		{"https://b2b.example.com.evil.test/catalog", nil},
+		{"https://notexample.com/", nil},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addSessionCookies(req, cookies)
+		var got []string
+		for _, cookie := range req.Cookies() {
+			got = append(got, cookie.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s got cookies %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
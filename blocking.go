@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// --- Block Heavy and Tracking Requests ---
+// With -block-resources every request a rendered page makes is paused and
+// images, fonts and media are failed before they go out, as are requests
+// to analytics and ad hosts; product links only need the DOM. Image URLs
+// are still read from the <img> attributes. -block-domains adds hosts to
+// defaultBlockedDomains, each also matching its subdomains.
+var blockedResourceTypes = map[network.ResourceType]bool{
+	network.ResourceTypeImage: true,
+	network.ResourceTypeFont:  true,
+	network.ResourceTypeMedia: true,
+}
+
+var defaultBlockedDomains = []string{
+	"google-analytics.com", "googletagmanager.com", "doubleclick.net",
+	"googlesyndication.com", "facebook.net", "connect.facebook.net",
+	"hotjar.com", "segment.io", "segment.com", "criteo.com", "criteo.net",
+	"scorecardresearch.com", "quantserve.com", "bat.bing.com", "clarity.ms",
+	"newrelic.com", "nr-data.net", "amazon-adsystem.com", "taboola.com",
+}
+
+// --- Parse -block-domains ---
+func parseBlockedDomains(list string) []string {
+	domains := append([]string(nil), defaultBlockedDomains...)
+	for _, domain := range strings.Split(list, ",") {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+			domains = append(domains, strings.TrimPrefix(domain, "."))
+		}
+	}
+	return domains
+}
+
+func isBlockedHost(host string, domains []string) bool {
+	host = strings.ToLower(host)
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// --- Intercept a Tab's Requests ---
+// The returned action turns interception on and must run before the first
+// navigation. Paused requests are answered off the event loop, as chromedp
+// requires.
+func blockRequests(ctx context.Context, domains []string) chromedp.Action {
+	chromedp.ListenTarget(ctx, func(ev any) {
+		paused, ok := ev.(*fetch.EventRequestPaused)
+		if !ok {
+			return
+		}
+		go func() {
+			executor := cdp.WithExecutor(ctx, chromedp.FromContext(ctx).Target)
+			var err error
+			if blockedResourceTypes[paused.ResourceType] || isBlockedHost(urlHost(paused.Request.URL), domains) {
+				err = fetch.FailRequest(paused.RequestID, network.ErrorReasonBlockedByClient).Do(executor)
+			} else {
+				err = fetch.ContinueRequest(paused.RequestID).Do(executor)
+			}
+			if err != nil && ctx.Err() == nil {
+				slog.Debug("Intercepted request not answered", "url", paused.Request.URL, "error", err)
+			}
+		}()
+	})
+	return fetch.Enable()
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// --- Bloom Filter ---
+// A fixed-size probabilistic set: Test never misses an added key, but may
+// report a key that was never added (a false positive) with roughly the
+// probability the filter was sized for.
+type bloomFilter struct {
+	mu   sync.RWMutex
+	bits []uint64
+	m    uint64 // number of bits
+	k    uint64 // hash functions per key
+}
+
+// --- Size a Filter for n Keys at False-Positive Rate p ---
+func newBloomFilter(n int, p float64) *bloomFilter {
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+	k := uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &bloomFilter{bits: make([]uint64, (m+63)/64), m: m, k: k}
+}
+
+// locations derives the k bit positions by double hashing one FNV-1a sum.
+func (b *bloomFilter) locations(key string) []uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	h1, h2 := sum&0xffffffff, sum>>32|1
+	locs := make([]uint64, b.k)
+	for i := range locs {
+		locs[i] = (h1 + uint64(i)*h2) % b.m
+	}
+	return locs
+}
+
+func (b *bloomFilter) Add(key string) {
+	locs := b.locations(key)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, loc := range locs {
+		b.bits[loc/64] |= 1 << (loc % 64)
+	}
+}
+
+func (b *bloomFilter) Test(key string) bool {
+	locs := b.locations(key)
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, loc := range locs {
+		if b.bits[loc/64]&(1<<(loc%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// --- Bloom Filter File Format ---
+// magic, version, m and k, followed by the bit words, all little-endian.
+// A file whose version or sizing differs from the running binary is
+// rejected so the caller rebuilds the filter instead. Version 2 filters
+// hold URL fingerprints rather than URLs.
+const (
+	bloomFileMagic   = "CRBF"
+	bloomFileVersion = 2
+)
+
+var errBloomMismatch = errors.New("bloom filter file does not match the current version or size")
+
+// --- Write the Filter to a File ---
+// Written to a temporary file and renamed so an interrupted save never
+// leaves a truncated filter behind.
+func (b *bloomFilter) SaveFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	b.mu.RLock()
+	w.WriteString(bloomFileMagic)
+	err = binary.Write(w, binary.LittleEndian, []uint64{bloomFileVersion, b.m, b.k})
+	if err == nil {
+		err = binary.Write(w, binary.LittleEndian, b.bits)
+	}
+	b.mu.RUnlock()
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// --- Read a Filter Sized Like want from a File ---
+func loadBloomFilter(path string, want *bloomFilter) (*bloomFilter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	r := bufio.NewReader(file)
+
+	magic := make([]byte, len(bloomFileMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != bloomFileMagic {
+		return nil, errBloomMismatch
+	}
+	header := make([]uint64, 3)
+	if err := binary.Read(r, binary.LittleEndian, header); err != nil {
+		return nil, err
+	}
+	if header[0] != bloomFileVersion || header[1] != want.m || header[2] != want.k {
+		return nil, errBloomMismatch
+	}
+
+	filter := &bloomFilter{bits: make([]uint64, len(want.bits)), m: want.m, k: want.k}
+	if err := binary.Read(r, binary.LittleEndian, filter.bits); err != nil {
+		return nil, fmt.Errorf("read bloom filter bits: %w", err)
+	}
+	return filter, nil
+}
+
+// --- Bloom-Fronted Visited Set ---
+// A filter miss means the URL was definitely not visited as far as this
+// process knows, so the wrapped set is skipped entirely. A hit may be a
+// false positive, or a key that has since expired, so it is confirmed
+// against the wrapped set. URLs marked by another process after the filter
+// was populated are not in it and will be re-crawled by this one.
+type bloomVisitedSet struct {
+	filter *bloomFilter
+	next   VisitedSet
+}
+
+// --- Populate a Filter from a File or Redis ---
+// A filter saved by an earlier run at path skips the Redis warm-up scan.
+// URLs other processes marked since that save are missing from it until
+// this process marks them itself. A missing, outdated or unreadable file
+// falls back to a rebuild from Redis.
+func newBloomVisitedSet(redisVisited *redisVisitedSet, path string) (*bloomVisitedSet, error) {
+	filter := newBloomFilter(bloomCapacity, bloomFalsePositive)
+	if path != "" {
+		loadedFilter, err := loadBloomFilter(path, filter)
+		switch {
+		case err == nil:
+			slog.Info("Bloom filter loaded", "path", path)
+			return &bloomVisitedSet{filter: loadedFilter, next: redisVisited}, nil
+		case errors.Is(err, os.ErrNotExist):
+			slog.Info("No saved Bloom filter, rebuilding from Redis", "path", path)
+		default:
+			slog.Warn("Saved Bloom filter unusable, rebuilding from Redis", "path", path, "error", err)
+		}
+	}
+
+	loaded := 0
+	err := redisVisited.Each(context.Background(), func(fingerprint string) {
+		filter.Add(fingerprint)
+		loaded++
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load visited URLs into Bloom filter: %w", err)
+	}
+	slog.Info("Bloom filter populated from Redis", "urls", loaded)
+	return &bloomVisitedSet{filter: filter, next: redisVisited}, nil
+}
+
+func (v *bloomVisitedSet) IsVisited(ctx context.Context, url string) bool {
+	if !v.filter.Test(urlFingerprint(url)) {
+		return false
+	}
+	return v.next.IsVisited(ctx, url)
+}
+
+func (v *bloomVisitedSet) MarkVisited(ctx context.Context, url string) {
+	v.next.MarkVisited(ctx, url)
+	v.filter.Add(urlFingerprint(url))
+}
+
+// Claim always asks Redis: a negative filter test cannot tell whether
+// another process claimed the URL since the filter was loaded.
+func (v *bloomVisitedSet) Claim(ctx context.Context, url string) bool {
+	claimed := v.next.Claim(ctx, url)
+	v.filter.Add(urlFingerprint(url))
+	return claimed
+}
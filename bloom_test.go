@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBloomFilterSurvivesSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "visited.bloom")
+	urls := []string{
+		"https://www.amazon.in/dp/B0AAAAAAAA/",
+		"https://www.snapdeal.com/product/phone/123456",
+		"https://www.myntra.com/shirts/roadster/slim-fit-shirt/123456/buy",
+	}
+	saved := newBloomFilter(bloomCapacity, bloomFalsePositive)
+	for _, url := range urls {
+		saved.Add(urlFingerprint(url))
+	}
+	if err := saved.SaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// A restarted process loads the file instead of rebuilding from Redis,
+	// which a nil Redis set would make fail.
+	visited, err := newBloomVisitedSet(nil, path)
+	if err != nil {
+		t.Fatalf("newBloomVisitedSet: %v", err)
+	}
+	for _, url := range urls {
+		if !visited.filter.Test(urlFingerprint(url)) {
+			t.Errorf("%s missing from the reloaded filter", url)
+		}
+	}
+	if visited.filter.Test(urlFingerprint("https://www.amazon.in/dp/B0ZZZZZZZZ/")) {
+		t.Error("reloaded filter reports a URL that was never added")
+	}
+}
+
+func TestLoadBloomFilterRejectsMismatches(t *testing.T) {
+	dir := t.TempDir()
+	want := newBloomFilter(1000, 0.01)
+
+	resized := filepath.Join(dir, "resized.bloom")
+	if err := newBloomFilter(2000, 0.01).SaveFile(resized); err != nil {
+		t.Fatal(err)
+	}
+	oldVersion := filepath.Join(dir, "v1.bloom")
+	header := append([]byte(bloomFileMagic), make([]byte, 24)...)
+	binary.LittleEndian.PutUint64(header[4:], 1)
+	binary.LittleEndian.PutUint64(header[12:], want.m)
+	binary.LittleEndian.PutUint64(header[20:], want.k)
+	if err := os.WriteFile(oldVersion, append(header, make([]byte, 8*len(want.bits))...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	garbage := filepath.Join(dir, "garbage.bloom")
+	if err := os.WriteFile(garbage, []byte("not a bloom filter"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, path := range map[string]string{"other size": resized, "other version": oldVersion, "bad magic": garbage} {
+		if _, err := loadBloomFilter(path, want); !errors.Is(err, errBloomMismatch) {
+			t.Errorf("%s: err = %v, want errBloomMismatch", name, err)
+		}
+	}
+	if _, err := loadBloomFilter(filepath.Join(dir, "missing.bloom"), want); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file: err = %v, want os.ErrNotExist", err)
+	}
+}
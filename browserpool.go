@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/chromedp/chromedp"
+)
+
+// --- Shared Browser Pool ---
+// With -browsers N, up to N Chrome instances per proxy stay running for the
+// whole crawl and each page load opens a tab in one of them, taken in turn,
+// instead of launching a browser of its own. Per-host settings such as the
+// fingerprint profile are applied to the tab by emulation, so only the
+// proxy and the -stealth launch flags are per browser. A browser that has
+// exited or cannot open a tab is replaced by a fresh one.
+type browserPool struct {
+	mu       sync.Mutex
+	size     int
+	opts     []chromedp.ExecAllocatorOption
+	browsers map[string][]*pooledBrowser // proxy ("" for none) -> browsers
+	next     map[string]int
+	closed   bool
+}
+
+type pooledBrowser struct {
+	ctx    context.Context // the browser's first tab; new tabs derive from it
+	cancel context.CancelFunc
+}
+
+// newBrowserPool returns nil when size is 0, leaving one Chrome per page.
+func newBrowserPool(size int, stealth bool) *browserPool {
+	if size <= 0 {
+		return nil
+	}
+	opts := append([]chromedp.ExecAllocatorOption(nil), chromedp.DefaultExecAllocatorOptions[:]...)
+	if stealth {
+		opts = append(opts, stealthAllocatorOptions()...)
+	}
+	return &browserPool{
+		size:     size,
+		opts:     opts,
+		browsers: make(map[string][]*pooledBrowser),
+		next:     make(map[string]int),
+	}
+}
+
+// --- Open a Tab ---
+// The tab closes when the returned cancel is called or ctx is done. A dead
+// browser is swapped for a new one and the tab is opened there instead.
+func (p *browserPool) Tab(ctx context.Context, proxy string) (context.Context, context.CancelFunc, error) {
+	for attempt := 1; ; attempt++ {
+		browser, err := p.pick(proxy)
+		if err != nil {
+			return nil, nil, err
+		}
+		tabCtx, cancelTab := chromedp.NewContext(browser.ctx)
+		// Running with no actions creates the tab's target.
+		if err := chromedp.Run(tabCtx); err != nil {
+			cancelTab()
+			p.discard(proxy, browser)
+			slog.Warn("Pooled browser is dead, replacing it", "proxy", proxy, "error", err)
+			if attempt >= 2 || ctx.Err() != nil {
+				return nil, nil, fmt.Errorf("open browser tab: %w", err)
+			}
+			continue
+		}
+		stop := context.AfterFunc(ctx, cancelTab)
+		return tabCtx, func() {
+			stop()
+			cancelTab()
+		}, nil
+	}
+}
+
+// pick hands out the proxy's browsers in turn, launching one while fewer
+// than size are running and replacing any that has exited.
+func (p *browserPool) pick(proxy string) (*pooledBrowser, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, context.Canceled
+	}
+	browsers := p.browsers[proxy]
+	if len(browsers) < p.size {
+		browser, err := p.launch(proxy)
+		if err != nil {
+			return nil, err
+		}
+		p.browsers[proxy] = append(browsers, browser)
+		return browser, nil
+	}
+	i := p.next[proxy] % len(browsers)
+	p.next[proxy]++
+	if browsers[i].ctx.Err() != nil {
+		slog.Warn("Pooled browser exited, replacing it", "proxy", proxy)
+		browser, err := p.launch(proxy)
+		if err != nil {
+			return nil, err
+		}
+		browsers[i] = browser
+	}
+	return browsers[i], nil
+}
+
+func (p *browserPool) launch(proxy string) (*pooledBrowser, error) {
+	opts := p.opts
+	if proxy != "" {
+		opts = append(opts[:len(opts):len(opts)], chromedp.ProxyServer(proxy))
+	}
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(context.Background(), opts...)
+	browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
+	cancel := func() {
+		cancelBrowser()
+		cancelAlloc()
+	}
+	if err := chromedp.Run(browserCtx); err != nil {
+		cancel()
+		return nil, fmt.Errorf("launch browser: %w", err)
+	}
+	slog.Debug("Launched pooled browser", "proxy", proxy)
+	return &pooledBrowser{ctx: browserCtx, cancel: cancel}, nil
+}
+
+func (p *browserPool) discard(proxy string, browser *pooledBrowser) {
+	browser.cancel()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	browsers := p.browsers[proxy]
+	for i, candidate := range browsers {
+		if candidate == browser {
+			p.browsers[proxy] = append(browsers[:i], browsers[i+1:]...)
+			break
+		}
+	}
+}
+
+// --- Shut Every Browser Down ---
+func (p *browserPool) Close() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	for _, browsers := range p.browsers {
+		for _, browser := range browsers {
+			browser.cancel()
+		}
+	}
+	p.browsers = nil
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// --- Subcommands ---
+// The first argument picks what the binary does; without one it crawls,
+// as it always has. Every command shares the flags below and those of
+// main.go, given after the command name:
+//
+//	crawler [crawl] [flags] [seed URLs...]  crawl the seeds
+//	crawler export [flags]                  dump the stored product URLs to -output
+//	crawler serve [flags]                   serve the stored product URLs over HTTP
+//	crawler purge [flags]                   clear the Redis visited (dedup) keys
+//	crawler history [flags]                 dump per-product price history to -output
+const (
+	commandCrawl   = "crawl"
+	commandExport  = "export"
+	commandServe   = "serve"
+	commandPurge   = "purge"
+	commandHistory = "history"
+)
+
+// defaultExportPath and defaultHistoryPath are where export and history
+// write when -output is not given.
+const (
+	defaultExportPath  = "product_urls.json"
+	defaultHistoryPath = "price_history.json"
+)
+
+// purgeBatch is how many keys purge removes per Redis call.
+const purgeBatch = 1000
+
+var (
+	listenAddr     = flag.String("listen", ":8080", "Address the serve command listens on")
+	historyProduct = flag.String("product", "", "Product URL the history command is limited to (default every product)")
+	purgeLegacy    = flag.Bool("purge-legacy", false, "With purge, also delete the un-namespaced visited keys of older versions (every key starting with \"http\"), after confirmation")
+
+	// These override the matching variables from the environment or .env.
+	redisAddrFlag = flag.String("redis-addr", "", "Redis address (overrides REDIS_ADDR)")
+	dbHostFlag    = flag.String("db-host", "", "Postgres host (overrides DB_HOST)")
+	dbPortFlag    = flag.String("db-port", "", "Postgres port (overrides DB_PORT)")
+	dbNameFlag    = flag.String("db-name", "", "Postgres database (overrides DB_NAME)")
+	dbUserFlag    = flag.String("db-user", "", "Postgres user (overrides DB_USER)")
+)
+
+// --- Split the Command from Its Arguments ---
+func splitCommand(args []string) (string, []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case commandCrawl, commandExport, commandServe, commandPurge, commandHistory:
+			return args[0], args[1:]
+		}
+	}
+	return commandCrawl, args
+}
+
+// --- Usage Message ---
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [crawl|export|serve|purge|history] [flags] [seed URLs...]\n\n", os.Args[0])
+	fmt.Fprintln(out, "  crawl   crawl the seed URLs (the default)")
+	fmt.Fprintln(out, "  export  write the stored product URLs to -output (default "+defaultExportPath+")")
+	fmt.Fprintln(out, "  serve   serve the stored product URLs over HTTP on -listen")
+	fmt.Fprintln(out, "  purge   delete the Redis visited keys (and the -queue dedup set), so pages are crawled again")
+	fmt.Fprintln(out, "  history write each product's price snapshots (see -price-history) to -output (default "+defaultHistoryPath+")")
+	fmt.Fprintln(out, "\nFlags:")
+	flag.PrintDefaults()
+}
+
+// --- Apply Flags That Override the Environment ---
+// Runs after .env is loaded, so a flag wins over both.
+func applyEnvFlags() {
+	overrides := map[string]string{
+		"REDIS_ADDR": *redisAddrFlag,
+		"DB_HOST":    *dbHostFlag,
+		"DB_PORT":    *dbPortFlag,
+		"DB_NAME":    *dbNameFlag,
+		"DB_USER":    *dbUserFlag,
+	}
+	for name, value := range overrides {
+		if value != "" {
+			os.Setenv(name, value)
+		}
+	}
+}
+
+// --- Open the Configured Store for a Command ---
+// The returned function closes it.
+func openStore() (Store, func()) {
+	store, err := initStore(*storeBackend, "")
+	if err != nil {
+		fatal("Storage setup failed", err)
+	}
+	closer, ok := store.(io.Closer)
+	if !ok {
+		return store, func() {}
+	}
+	return store, func() { closer.Close() }
+}
+
+// --- Export Stored Product URLs ---
+type exportedURL struct {
+	URL       string    `json:"url"`
+	Domain    string    `json:"domain,omitempty"`
+	SourceURL string    `json:"source_url,omitempty"`
+	LastSeen  time.Time `json:"last_seen"`
+	SeenCount int       `json:"seen_count,omitempty"`
+}
+
+func exportRecords(records []ProductURL) []exportedURL {
+	exported := make([]exportedURL, 0, len(records))
+	for _, record := range records {
+		exported = append(exported, exportedURL{
+			URL:       record.URL,
+			Domain:    record.Domain,
+			SourceURL: record.SourceURL,
+			LastSeen:  record.LastSeen,
+			SeenCount: record.SeenCount,
+		})
+	}
+	return exported
+}
+
+func runExport(ctx context.Context) {
+	store, closeStore := openStore()
+	defer closeStore()
+
+	records, err := store.Records(ctx)
+	if err != nil {
+		fatal("Failed to load stored product URLs", err)
+	}
+	path := *outputPath
+	if !flagGiven("output") {
+		path = defaultExportPath
+	}
+	if err := exportJSON(path, exportRecords(records), *overwriteOutput); err != nil {
+		fatal("Failed to export product URLs", err)
+	}
+	slog.Info("Product URLs exported", "path", path, "urls", len(records))
+}
+
+// --- Export Per-Product Price History ---
+// One entry per product with its snapshots oldest first, optionally
+// limited to the -product URL.
+type productPriceHistory struct {
+	URL       string          `json:"url"`
+	Snapshots []PriceSnapshot `json:"snapshots"`
+}
+
+func groupPriceHistory(snapshots []PriceSnapshot) []productPriceHistory {
+	var history []productPriceHistory
+	for _, snapshot := range snapshots {
+		if len(history) == 0 || history[len(history)-1].URL != snapshot.URL {
+			history = append(history, productPriceHistory{URL: snapshot.URL})
+		}
+		last := &history[len(history)-1]
+		last.Snapshots = append(last.Snapshots, snapshot)
+	}
+	return history
+}
+
+func runHistory(ctx context.Context) {
+	store, closeStore := openStore()
+	defer closeStore()
+
+	snapshots, err := store.PriceHistory(ctx, *historyProduct)
+	if err != nil {
+		fatal("Failed to load price history", err)
+	}
+	path := *outputPath
+	if !flagGiven("output") {
+		path = defaultHistoryPath
+	}
+	history := groupPriceHistory(snapshots)
+	if err := exportJSON(path, history, *overwriteOutput); err != nil {
+		fatal("Failed to export price history", err)
+	}
+	slog.Info("Price history exported", "path", path, "products", len(history), "snapshots", len(snapshots))
+}
+
+// --- Serve Stored Product URLs over HTTP ---
+// GET /health, GET /domains and GET /urls, which takes optional domain
+// and since (RFC 3339) query parameters. Responses are JSON.
+func runServe(ctx context.Context) {
+	store, closeStore := openStore()
+	defer closeStore()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSONResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("GET /domains", func(w http.ResponseWriter, r *http.Request) {
+		domains, err := store.Domains(r.Context())
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, domains)
+	})
+	mux.HandleFunc("GET /urls", func(w http.ResponseWriter, r *http.Request) {
+		var since time.Time
+		if value := r.URL.Query().Get("since"); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid since %q: want RFC 3339", value))
+				return
+			}
+			since = parsed
+		}
+		records, err := store.Records(r.Context())
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err)
+			return
+		}
+		domain := r.URL.Query().Get("domain")
+		kept := records[:0]
+		for _, record := range records {
+			if (domain == "" || record.Domain == domain || urlHost(record.URL) == strings.ToLower(domain)) && !record.LastSeen.Before(since) {
+				kept = append(kept, record)
+			}
+		}
+		writeJSONResponse(w, http.StatusOK, exportRecords(kept))
+	})
+
+	server := &http.Server{Addr: *listenAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	slog.Info("Serving stored product URLs", "addr", *listenAddr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fatal("HTTP server failed", err)
+	}
+}
+
+func writeJSONResponse(w http.ResponseWriter, status int, v any) {
+	data, err := encodeJSON(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSONResponse(w, status, map[string]string{"error": err.Error()})
+}
+
+// --- Purge Redis Dedup Keys ---
+// Deletes the visited page keys of -visited-namespace and with -queue the
+// queue's enqueued set, so the next crawl visits every page again. Keys
+// older versions stored under the raw URL are only matched by a pattern
+// as broad as "http*", which on a shared Redis also hits other
+// applications' keys, so they are deleted only with -purge-legacy and
+// after confirmation. With -dry-run the keys are only counted.
+func runPurge(ctx context.Context) {
+	patterns := []string{*visitedNamespace + ":*"}
+	if *purgeLegacy {
+		if !*dryRun && !confirm(os.Stdin, os.Stderr, `Delete every Redis key starting with "http"? Type yes to continue: `) {
+			fatal("Purge aborted", errors.New("legacy key deletion not confirmed"))
+		}
+		patterns = append(patterns, "http*")
+	}
+
+	client, err := initRedis()
+	if err != nil {
+		fatal("Redis setup failed", err)
+	}
+	defer client.Close()
+
+	var batch []string
+	purged := 0
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if !*dryRun {
+			if err := client.Unlink(ctx, batch...).Err(); err != nil {
+				fatal("Failed to delete visited keys", err)
+			}
+		}
+		purged += len(batch)
+		batch = batch[:0]
+	}
+	for _, pattern := range patterns {
+		iter := client.Scan(ctx, 0, pattern, purgeBatch).Iterator()
+		for iter.Next(ctx) {
+			if batch = append(batch, iter.Val()); len(batch) >= purgeBatch {
+				flush()
+			}
+		}
+		if err := iter.Err(); err != nil {
+			fatal("Failed to scan visited keys", err)
+		}
+	}
+	if *queueName != "" {
+		batch = append(batch, newWorkQueue(client, *queueName).queued)
+	}
+	flush()
+
+	if *dryRun {
+		slog.Info("Dry run: would delete Redis dedup keys", "keys", purged)
+		return
+	}
+	slog.Info("Redis dedup keys deleted", "keys", purged)
+}
+
+// --- Ask for Confirmation ---
+// Only an answer of "yes" confirms.
+func confirm(in io.Reader, out io.Writer, prompt string) bool {
+	fmt.Fprint(out, prompt)
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	return strings.TrimSpace(answer) == "yes"
+}
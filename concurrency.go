@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// --- Page Load Concurrency ---
+// -concurrency bounds simultaneous page loads across all hosts and
+// -per-domain-concurrency those of any single host, so one site is never
+// hammered while others wait. A load takes its host slot before a global
+// one: workers queued on a busy host hold no global slot, so other hosts
+// keep loading and a crawl of one host only runs slower, never stalls.
+type loadLimiter struct {
+	global  *semaphore.Weighted // nil when unlimited
+	perHost int64
+	mu      sync.Mutex
+	hosts   map[string]*semaphore.Weighted
+}
+
+func newLoadLimiter(global, perHost int) *loadLimiter {
+	limiter := &loadLimiter{perHost: int64(perHost), hosts: make(map[string]*semaphore.Weighted)}
+	if global > 0 {
+		limiter.global = semaphore.NewWeighted(int64(global))
+	}
+	return limiter
+}
+
+// --- Acquire a Load Slot ---
+// The returned release must be called once the load is done. A nil
+// limiter never blocks.
+func (l *loadLimiter) Acquire(ctx context.Context, host string) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	l.mu.Lock()
+	hostSem, ok := l.hosts[host]
+	if !ok {
+		hostSem = semaphore.NewWeighted(l.perHost)
+		l.hosts[host] = hostSem
+	}
+	l.mu.Unlock()
+
+	if err := hostSem.Acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+	if l.global != nil {
+		if err := l.global.Acquire(ctx, 1); err != nil {
+			hostSem.Release(1)
+			return nil, err
+		}
+	}
+	return func() {
+		if l.global != nil {
+			l.global.Release(1)
+		}
+		hostSem.Release(1)
+	}, nil
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// --- Crawl Configuration File ---
+// Loaded from -config, as JSON or, for .yaml/.yml files, YAML with the same
+// keys. Seeds are crawled when none are given on the command line and
+// Output replaces the default -output path. Per-domain settings are keyed
+// by host; a key also applies to every host sharing its registrable
+// domain, so "amazon.com" covers "www.amazon.com". The top-level
+// crawlTimeout and scrollAttempts apply to domains that set neither;
+// allowDomains and denyDomains are merged with -allow-domains and
+// -deny-domains.
+//
+//	{"seeds": ["https://www.amazon.com/s?k=laptops"],
+//	 "output": "laptops.json",
+//	 "allowDomains": ["amazon.com", "*.media-amazon.com"],
+//	 "denyDomains": ["aax-us-east.amazon-adsystem.com"],
+//	 "crawlTimeout": "45s",
+//	 "proxies": ["http://10.0.0.1:3128", "socks5://10.0.0.2:1080"],
+//	 "domains": {"amazon.com": {
+//		"productPatterns": ["/dp/[A-Z0-9]{10}", "/gp/product/[A-Z0-9]{10}"],
+//		"qaCountSelector": "#askATFLink span",
+//		"waitSelector": "div.s-result-item",
+//		"waitTimeout": "10s",
+//		"scrollAttempts": 8,
+//		"maxPages": 200
+//	}}}
+type Config struct {
+	Seeds          []string                `json:"seeds"`
+	Output         string                  `json:"output"`
+	CrawlTimeout   string                  `json:"crawlTimeout"`
+	ScrollAttempts int                     `json:"scrollAttempts"`
+	Proxies        []string                `json:"proxies"`
+	AllowDomains   []string                `json:"allowDomains"`
+	DenyDomains    []string                `json:"denyDomains"`
+	Domains        map[string]DomainConfig `json:"domains"`
+
+	defaults DomainConfig // applies to hosts without an entry
+}
+
+// defaultNextPageSelector matches the pagination control most listings use.
+const defaultNextPageSelector = "a.next-page"
+
+// --- Per-Domain Settings ---
+type DomainConfig struct {
+	// ProductPatterns replace the built-in product URL regex. A match that
+	// starts with http(s):// is kept as an absolute URL; anything else is
+	// resolved against the page URL.
+	ProductPatterns []string `json:"productPatterns"`
+	QACountSelector string   `json:"qaCountSelector"`
+	// PriceSelector reads the public price; MemberPriceSelector reads the
+	// members-only price, which most pages only show after login.
+	PriceSelector       string `json:"priceSelector"`
+	MemberPriceSelector string `json:"memberPriceSelector"`
+	// Locale ("en-IN", "de-DE") tells how prices are written on the site;
+	// Currency (ISO 4217) overrides the currency detected from the price.
+	Locale   string `json:"locale"`
+	Currency string `json:"currency"`
+	// ShippingSelector reads shipping text such as "Free shipping" or
+	// "Free over $35"; without it JSON-LD shippingDetails are used.
+	ShippingSelector string `json:"shippingSelector"`
+	// TitleSelector, BrandSelector and GTINSelector read the fields that
+	// fingerprint a product across domains; without them og:title or the
+	// first <h1> and the page's JSON-LD are used.
+	TitleSelector string `json:"titleSelector"`
+	BrandSelector string `json:"brandSelector"`
+	GTINSelector  string `json:"gtinSelector"`
+	// SKUSelector reads the site's own product code; without it the
+	// JSON-LD or microdata sku is used.
+	SKUSelector string `json:"skuSelector"`
+	// ImageSelector picks the product's main <img>; without it og:image
+	// is used.
+	ImageSelector string `json:"imageSelector"`
+	// WaitSelector is waited for after the body is visible, for listings
+	// whose product grid arrives via XHR after the initial page load.
+	WaitSelector string `json:"waitSelector"`
+	// WaitTimeout bounds the WaitSelector wait, e.g. "10s"; it defaults to
+	// waitSelectorTimeout and must stay below the crawl timeout.
+	WaitTimeout string `json:"waitTimeout"`
+	// Headers are sent with every request to the domain, e.g.
+	// {"Accept-Language": "en-IN"} to get INR prices from Indian sites.
+	Headers map[string]string `json:"headers"`
+	// Device names a mobile device to emulate for this domain, e.g.
+	// "iPhone 11", or "desktop" to stay on desktop despite -device.
+	Device string `json:"device"`
+	// NextPageSelector finds the listing's next-page control, "a.next-page"
+	// by default. Pages that have one are paginated instead of scrolled.
+	NextPageSelector string `json:"nextPageSelector"`
+	// ListingStrategy fixes how listings load more products: "paginate",
+	// "pageurl", "loadmore", "scroll" or "none". Unset, pageParam or
+	// pageUrlTemplate imply "pageurl" and loadMoreSelector "loadmore";
+	// otherwise each listing page is probed.
+	ListingStrategy string `json:"listingStrategy"`
+	// PageParam names the query parameter holding the page number, e.g.
+	// "page"; PageURLTemplate is a URL with a {page} placeholder instead.
+	// LoadMoreSelector is a button that appends the next products.
+	// MaxListingPages caps the pages visited per listing (default 5).
+	PageParam        string `json:"pageParam"`
+	PageURLTemplate  string `json:"pageUrlTemplate"`
+	LoadMoreSelector string `json:"loadMoreSelector"`
+	MaxListingPages  int    `json:"maxListingPages"`
+	// Login, when set, logs in before the domain's first page is crawled.
+	Login *LoginConfig `json:"login"`
+	// CouponSelector narrows coupon matching to these elements; empty
+	// searches the whole page. CouponPattern replaces the built-in
+	// "use code XYZ" regex; its first capture group, if any, is the code.
+	CouponSelector string `json:"couponSelector"`
+	CouponPattern  string `json:"couponPattern"`
+	// ExcludeExtensions and ExcludePaths replace the built-in lists of
+	// asset extensions and non-product path prefixes; MinSlugLength
+	// replaces the minimum length of a product URL's last path segment.
+	ExcludeExtensions []string `json:"excludeExtensions"`
+	ExcludePaths      []string `json:"excludePaths"`
+	MinSlugLength     int      `json:"minSlugLength"`
+	// KeepParams names the query parameters that tell products apart, e.g.
+	// ["variant", "color"], or ["*"] for all; without it product URLs
+	// carry no query string. StripParams removes tracking parameters even
+	// when kept, "utm_*", "gclid" and the like by default. Both accept
+	// path.Match globs. Kept parameters are sorted, so "?a=1&b=2" and
+	// "?b=2&a=1" store as one URL.
+	KeepParams  []string `json:"keepParams"`
+	StripParams []string `json:"stripParams"`
+	// CategoryPatterns match the links -depth follows from a listing page,
+	// against their path and query; without them typical category, search
+	// and ?page= URLs are followed.
+	CategoryPatterns []string `json:"categoryPatterns"`
+	// CrawlTimeout bounds each page load, e.g. "45s" (30s by default);
+	// ScrollAttempts replaces the number of infinite-scroll passes.
+	CrawlTimeout   string `json:"crawlTimeout"`
+	ScrollAttempts int    `json:"scrollAttempts"`
+	// RequestsPerSecond overrides -rate for the domain; see politeness.go.
+	RequestsPerSecond float64 `json:"requestsPerSecond"`
+	// MaxPages and MaxURLs override -max-pages and -max-urls for the
+	// domain.
+	MaxPages int `json:"maxPages"`
+	MaxURLs  int `json:"maxURLs"`
+
+	patterns    []*regexp.Regexp
+	categories  []*regexp.Regexp
+	coupon      *regexp.Regexp
+	waitTimeout time.Duration
+	pageTimeout time.Duration
+}
+
+// --- Load Configuration ---
+// An empty path yields an empty config so every domain uses the defaults.
+func loadConfig(path string) (*Config, error) {
+	cfg := &Config{Domains: make(map[string]DomainConfig)}
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	// YAML is decoded generically and re-encoded, so both formats share
+	// the JSON field names.
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		var doc any
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("parse config %s: %w", path, err)
+		}
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, fmt.Errorf("parse config %s: %w", path, err)
+		}
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", path, err)
+	}
+
+	if cfg.defaults.pageTimeout, err = parsePageTimeout(cfg.CrawlTimeout); err != nil {
+		return nil, err
+	}
+	if cfg.ScrollAttempts < 0 {
+		return nil, fmt.Errorf("scrollAttempts %d must not be negative", cfg.ScrollAttempts)
+	}
+	cfg.defaults.ScrollAttempts = cfg.ScrollAttempts
+	for _, seed := range cfg.Seeds {
+		if strings.TrimSpace(seed) == "" {
+			return nil, errors.New("seeds must not contain empty URLs")
+		}
+	}
+
+	normalized := make(map[string]DomainConfig, len(cfg.Domains))
+	for host, domainCfg := range cfg.Domains {
+		for _, expr := range domainCfg.ProductPatterns {
+			pattern, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("domain %s: invalid product pattern %q: %w", host, expr, err)
+			}
+			domainCfg.patterns = append(domainCfg.patterns, pattern)
+		}
+		for _, expr := range domainCfg.CategoryPatterns {
+			pattern, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("domain %s: invalid category pattern %q: %w", host, expr, err)
+			}
+			domainCfg.categories = append(domainCfg.categories, pattern)
+		}
+		if domainCfg.Login != nil {
+			if err := domainCfg.Login.validate(); err != nil {
+				return nil, fmt.Errorf("domain %s: %w", host, err)
+			}
+		}
+		if _, err := lookupDevice(domainCfg.Device); err != nil {
+			return nil, fmt.Errorf("domain %s: %w", host, err)
+		}
+		domainCfg.pageTimeout = cfg.defaults.pageTimeout
+		if domainCfg.CrawlTimeout != "" {
+			if domainCfg.pageTimeout, err = parsePageTimeout(domainCfg.CrawlTimeout); err != nil {
+				return nil, fmt.Errorf("domain %s: %w", host, err)
+			}
+		}
+		if domainCfg.ScrollAttempts < 0 {
+			return nil, fmt.Errorf("domain %s: scrollAttempts %d must not be negative", host, domainCfg.ScrollAttempts)
+		}
+		if domainCfg.ScrollAttempts == 0 {
+			domainCfg.ScrollAttempts = cfg.ScrollAttempts
+		}
+		switch domainCfg.ListingStrategy {
+		case "", strategyPaginate, strategyScroll, strategyNone:
+		case strategyPageURL:
+			if domainCfg.PageParam == "" && domainCfg.PageURLTemplate == "" {
+				return nil, fmt.Errorf("domain %s: listingStrategy pageurl needs pageParam or pageUrlTemplate", host)
+			}
+		case strategyLoadMore:
+			if domainCfg.LoadMoreSelector == "" {
+				return nil, fmt.Errorf("domain %s: listingStrategy loadmore needs loadMoreSelector", host)
+			}
+		default:
+			return nil, fmt.Errorf("domain %s: listingStrategy %q must be paginate, pageurl, loadmore, scroll or none", host, domainCfg.ListingStrategy)
+		}
+		if domainCfg.PageURLTemplate != "" && !strings.Contains(domainCfg.PageURLTemplate, pagePlaceholder) {
+			return nil, fmt.Errorf("domain %s: pageUrlTemplate %q has no %s placeholder", host, domainCfg.PageURLTemplate, pagePlaceholder)
+		}
+		if domainCfg.MaxListingPages < 0 {
+			return nil, fmt.Errorf("domain %s: maxListingPages %d must not be negative", host, domainCfg.MaxListingPages)
+		}
+		if domainCfg.RequestsPerSecond < 0 {
+			return nil, fmt.Errorf("domain %s: requestsPerSecond %g must not be negative", host, domainCfg.RequestsPerSecond)
+		}
+		if domainCfg.MaxPages < 0 || domainCfg.MaxURLs < 0 {
+			return nil, fmt.Errorf("domain %s: maxPages and maxURLs must not be negative", host)
+		}
+		if domainCfg.WaitTimeout != "" {
+			timeout, err := time.ParseDuration(domainCfg.WaitTimeout)
+			if err != nil || timeout <= 0 || timeout >= domainCfg.crawlTimeout() {
+				return nil, fmt.Errorf("domain %s: waitTimeout %q must be a duration between 0 and %s", host, domainCfg.WaitTimeout, domainCfg.crawlTimeout())
+			}
+			domainCfg.waitTimeout = timeout
+		}
+		for _, param := range append(domainCfg.KeepParams, domainCfg.StripParams...) {
+			if err := checkParamPattern(param); err != nil {
+				return nil, fmt.Errorf("domain %s: invalid query parameter pattern %q: %w", host, param, err)
+			}
+		}
+		if domainCfg.CouponPattern != "" {
+			if domainCfg.coupon, err = regexp.Compile(domainCfg.CouponPattern); err != nil {
+				return nil, fmt.Errorf("domain %s: invalid coupon pattern %q: %w", host, domainCfg.CouponPattern, err)
+			}
+		}
+		normalized[strings.ToLower(host)] = domainCfg
+	}
+	cfg.Domains = normalized
+	return cfg, nil
+}
+
+// --- Settings for a Host ---
+// An exact host entry wins over one for the registrable domain. Settings
+// neither sets come from the host's site adapter.
+func (c *Config) forHost(host string) DomainConfig {
+	host = strings.ToLower(host)
+	if domainCfg, ok := c.Domains[host]; ok {
+		return domainCfg.withAdapter(host)
+	}
+	if domainCfg, ok := c.Domains[registrableDomain(host)]; ok {
+		return domainCfg.withAdapter(host)
+	}
+	return c.defaults.withAdapter(host)
+}
+
+// --- Parse a crawlTimeout Setting ---
+// Empty leaves the built-in crawlTimeout in place.
+func parsePageTimeout(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("crawlTimeout %q must be a positive duration", value)
+	}
+	return timeout, nil
+}
+
+// --- Page Load Timeout for a Domain ---
+func (d DomainConfig) crawlTimeout() time.Duration {
+	if d.pageTimeout == 0 {
+		return crawlTimeout
+	}
+	return d.pageTimeout
+}
+
+// --- Infinite-Scroll Passes for a Domain ---
+func (d DomainConfig) scrollAttempts() int {
+	if d.ScrollAttempts == 0 {
+		return scrollAttempts
+	}
+	return d.ScrollAttempts
+}
+
+// --- Wait Selector Timeout for a Domain ---
+func (d DomainConfig) waitSelectorTimeout() time.Duration {
+	if d.waitTimeout == 0 {
+		return waitSelectorTimeout
+	}
+	return d.waitTimeout
+}
+
+// --- Next-Page Selector for a Domain ---
+func (d DomainConfig) nextPageSelector() string {
+	if d.NextPageSelector == "" {
+		return defaultNextPageSelector
+	}
+	return d.NextPageSelector
+}
+
+// --- Coupon Code Pattern for a Domain ---
+// Falls back to the built-in couponPattern when none is configured.
+func (d DomainConfig) couponPattern() *regexp.Regexp {
+	if d.coupon == nil {
+		return couponPattern
+	}
+	return d.coupon
+}
+
+// --- Product URL Patterns for a Domain ---
+// Falls back to the built-in productURLPattern when none are configured.
+func (d DomainConfig) productPatterns() []*regexp.Regexp {
+	if len(d.patterns) == 0 {
+		return []*regexp.Regexp{productURLPattern}
+	}
+	return d.patterns
+}
+
+// --- Category Link Test for a Domain ---
+// Falls back to defaultCategoryPattern when none are configured.
+func (d DomainConfig) isCategoryLink(requestURI string) bool {
+	if len(d.categories) == 0 {
+		return defaultCategoryPattern.MatchString(requestURI)
+	}
+	for _, pattern := range d.categories {
+		if pattern.MatchString(requestURI) {
+			return true
+		}
+	}
+	return false
+}
+
+// --- Non-Product URL Rules for a Domain ---
+// Each falls back to its built-in default when not configured.
+func (d DomainConfig) excludeExtensions() []string {
+	if d.ExcludeExtensions == nil {
+		return defaultExcludeExtensions
+	}
+	return d.ExcludeExtensions
+}
+
+func (d DomainConfig) excludePaths() []string {
+	if d.ExcludePaths == nil {
+		return defaultExcludePaths
+	}
+	return d.ExcludePaths
+}
+
+func (d DomainConfig) minSlugLength() int {
+	if d.MinSlugLength == 0 {
+		return defaultMinSlugLength
+	}
+	return d.MinSlugLength
+}
+
+// --- Significant Query Parameters for a Domain ---
+// A parameter is kept when it matches KeepParams and not StripParams.
+func (d DomainConfig) keepsParam(name string) bool {
+	strip := d.StripParams
+	if strip == nil {
+		strip = defaultStripParams
+	}
+	return matchesParam(d.KeepParams, name) && !matchesParam(strip, name)
+}
+
+func checkParamPattern(pattern string) error {
+	_, err := path.Match(pattern, "")
+	return err
+}
+
+func matchesParam(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,735 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"github.com/chromedp/chromedp/device"
+)
+
+// --- Crawler ---
+// Crawler holds the dependencies a crawl needs so they can be swapped for
+// in-memory implementations when no Postgres or Redis is available.
+type Crawler struct {
+	store          Store
+	visited        VisitedSet
+	config         *Config
+	productDetails bool
+	throttle       *rateLimitThrottle
+	robots         *robotsPolicy // nil with -ignore-robots
+	loads          *loadLimiter
+	pacer          *politeness // -rate; nil never waits
+	proxies        *proxyPool  // -proxies; nil loads pages directly
+	browsers       *browserPool
+	dryRun         bool
+	seeds          *seedTracker
+	fresh          *freshURLs // product URLs first stored by this run
+	shadowDOM      bool
+	fetchMode      string
+	autoMinLinks   int
+	blockedDomains []string // -block-resources; nil loads every request
+	fingerprints   bool
+	stealth        bool
+	device         *device.Info // emulated by default; nil is the desktop browser
+	remoteWS       string       // DevTools endpoint of a shared browser; empty launches Chrome locally
+	stats          *crawlStats
+	progress       *progressTracker
+	sessions       *sessionCache
+	audit          *slog.Logger // lifecycle events; see audit.go
+	sink           ProductSink  // nil unless SINK is configured
+	trace          bool         // -trace: log a pageTrace per listing page
+	limits         *siteLimits  // -max-pages/-max-urls; nil is unlimited
+	maxDepth       int          // -depth: category links followed from a seed
+	maxHTMLBytes   int          // HTML size guard; 0 disables it
+	htmlOverflow   string       // overflowTruncate or overflowSkip
+	runID          string
+	dumpDir        string     // -dump-empty: where pages without product URLs are saved
+	queue          *workQueue // shared Redis frontier with -queue; nil crawls the seeds directly
+	scrollDelayMin time.Duration
+	scrollDelayMax time.Duration
+	since          time.Duration
+	seen           sync.Map // product URL -> first seed that surfaced it this run
+	claimed        sync.Map // page URLs claimed by a worker this run
+	canonicals     sync.Map // canonical product URL -> first URL that led to it
+
+	// Plain HTTP clients by proxy, created on first use; see httpClient.
+	httpMu      sync.Mutex
+	httpClients map[string]*http.Client
+	cookieJar   http.CookieJar
+}
+
+// errRedirectLoop marks a page whose redirects never settle on a final URL.
+var errRedirectLoop = errors.New("redirect loop")
+
+// --- Scrape Product Pages ---
+// job is a listing job: the seed itself, or with -depth a category page
+// reached from it. Category links on the page are followed down to
+// c.maxDepth.
+func (c *Crawler) scrapeWebsite(ctx context.Context, job queueJob, resultChan chan<- CrawlResult, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	url := job.URL
+	if job.Seed == "" {
+		job.Seed = url
+	}
+
+	if ctx.Err() != nil {
+		return
+	}
+	c.audit.Info("domain_started", "url", url)
+
+	// The page budget is only taken once the page is claimed, so pages
+	// already visited or claimed by another worker never use it up. The
+	// cheap check first keeps a capped domain from claiming pages it will
+	// not crawl.
+	if c.limits.PagesExhausted(urlHost(url)) {
+		c.audit.Info("page_skipped", "url", url, "reason", "domain_cap")
+		return
+	}
+	if c.progress.Stopped(urlHost(url)) {
+		c.audit.Info("page_skipped", "url", url, "reason", "no_progress")
+		return
+	}
+	if !c.claim(ctx, url) {
+		slog.Info("Skipping already crawled URL", "url", url)
+		c.audit.Info("page_skipped", "url", url, "reason", "already_visited")
+		return
+	}
+	if !c.limits.TakePage(urlHost(url)) {
+		c.audit.Info("page_skipped", "url", url, "reason", "domain_cap")
+		return
+	}
+
+	ctx, trace := c.startTrace(ctx, url)
+	defer trace.log()
+
+	host := urlHost(url)
+	htmlContent, finalURL, err := c.fetchPage(ctx, url, "", true)
+	if errors.Is(err, errRobotsDisallowed) {
+		slog.Info("Skipping page disallowed by robots.txt", "url", url)
+		c.audit.Info("page_skipped", "url", url, "reason", "robots")
+		return
+	}
+	if err != nil {
+		trace.record(func(t *pageTrace) { t.err = err.Error() })
+		slog.Error("Failed to load page", "url", url, "error", err)
+		c.stats.Error(host)
+		c.audit.Error("page_failed", "url", url, "error", err.Error())
+		return
+	}
+	c.stats.PageVisited(host)
+	c.audit.Info("page_loaded", "url", url, "final_url", finalURL)
+
+	// A redirect target is deduplicated under its own URL, so a page
+	// reachable from several URLs is only processed once.
+	requestedURL := ""
+	if finalURL != url {
+		slog.Info("Page redirected", "url", url, "final_url", finalURL)
+		if !c.claim(ctx, finalURL) {
+			slog.Info("Skipping already crawled redirect target", "url", url, "final_url", finalURL)
+			c.audit.Info("page_skipped", "url", url, "final_url", finalURL, "reason", "redirect_target_visited")
+			return
+		}
+		requestedURL = url
+	}
+
+	regionRestricted := false
+	if isRegionBlocked(htmlContent) {
+		htmlContent, regionRestricted = c.retryViaRegionProxies(ctx, url, htmlContent)
+	}
+
+	extracted := c.listingURLs(htmlContent, finalURL)
+	if trace != nil {
+		raw := rawMatchCount(htmlContent, c.config.forHost(urlHost(finalURL)))
+		trace.record(func(t *pageTrace) {
+			t.finalURL, t.htmlBytes, t.rawMatches, t.urls = finalURL, len(htmlContent), raw, len(extracted)
+		})
+	}
+	if len(extracted) == 0 && c.dumpDir != "" {
+		if path, err := dumpEmptyPage(c.dumpDir, finalURL, htmlContent); err != nil {
+			slog.Warn("Failed to dump empty page", "url", finalURL, "error", err)
+		} else {
+			slog.Info("No product URLs found, page dumped", "url", finalURL, "path", path)
+		}
+	}
+	productURLs, crossSeedURLs := c.filterSeen(extracted, job.Seed)
+	productURLs = c.dropRecent(ctx, productURLs)
+	productURLs = productURLs[:c.limits.TakeURLs(host, len(productURLs))]
+
+	newCount := c.storeProductURLs(ctx, append(productURLs, crossSeedURLs...), finalURL, job.Seed)
+	c.progress.Record(host, len(productURLs))
+	c.stats.URLsFound(host, len(extracted), newCount)
+
+	var products []Product
+	if c.productDetails && c.queue != nil {
+		// Any instance sharing the queue may fetch the details.
+		c.enqueueProducts(ctx, productURLs, job.Seed)
+	} else if c.productDetails {
+		products = c.fetchProductDetails(ctx, productURLs)
+		if c.dryRun {
+			slog.Info("Dry run: would store product details", "url", url, "products", len(products))
+		} else if err := c.store.SaveProducts(ctx, products); err != nil {
+			slog.Error("Failed to store product details", "url", url, "error", err)
+			c.stats.Error(host)
+			c.audit.Error("store_failed", "url", url, "error", err.Error())
+		}
+	}
+
+	c.follow(ctx, job, htmlContent, finalURL, resultChan, wg)
+
+	c.audit.Info("domain_finished", "url", url, "urls", len(productURLs), "products", len(products), "region_restricted", regionRestricted)
+	resultChan <- CrawlResult{
+		Domain:           url,
+		SourceURL:        finalURL,
+		RequestedURL:     requestedURL,
+		URLs:             productURLs,
+		Products:         products,
+		RegionRestricted: regionRestricted,
+	}
+}
+
+// --- Claim a Page URL for This Worker ---
+// The per-run map settles races between workers of this process without a
+// round trip; the visited set's atomic Claim settles them across processes.
+// A dry run only reads the visited set and never marks it.
+func (c *Crawler) claim(ctx context.Context, url string) bool {
+	if _, loaded := c.claimed.LoadOrStore(url, true); loaded {
+		return false
+	}
+	if c.dryRun {
+		if c.visited.IsVisited(ctx, url) {
+			return false
+		}
+		slog.Info("Dry run: would mark URL as visited", "url", url)
+		return true
+	}
+	return c.visited.Claim(ctx, url)
+}
+
+// --- Drop URLs Already Seen This Run ---
+// A cheap in-process filter in front of the store: URLs handled earlier in
+// the run are dropped from fresh. URLs first seen under a different seed
+// are returned in crossSeed so their seed attribution is still stored.
+func (c *Crawler) filterSeen(urls []string, seed string) (fresh, crossSeed []string) {
+	for _, url := range urls {
+		first, loaded := c.seen.LoadOrStore(url, seed)
+		switch {
+		case !loaded:
+			fresh = append(fresh, url)
+		case first.(string) != seed:
+			crossSeed = append(crossSeed, url)
+		default:
+			slog.Debug("URL already seen this run", "url", url)
+		}
+	}
+	return fresh, crossSeed
+}
+
+// --- Drop URLs Stored Recently ---
+// With -since, URLs whose LastSeen in storage falls within the window are
+// neither re-stored nor fetched again. Storage outlives Redis, so this
+// dedup holds even after the visited set is flushed. A failed lookup keeps
+// every URL.
+func (c *Crawler) dropRecent(ctx context.Context, urls []string) []string {
+	if c.since <= 0 || len(urls) == 0 {
+		return urls
+	}
+	recent, err := c.store.RecentURLs(ctx, urls, time.Now().UTC().Add(-c.since))
+	if err != nil {
+		slog.Warn("Failed to look up recently stored URLs, keeping all", "urls", len(urls), "error", err)
+		return urls
+	}
+	kept := make([]string, 0, len(urls))
+	for _, url := range urls {
+		if recent[url] {
+			slog.Debug("URL stored recently, skipping", "url", url, "since", c.since)
+			continue
+		}
+		kept = append(kept, url)
+	}
+	return kept
+}
+
+// --- URLs Not Yet in Storage ---
+// One batched lookup for the whole page. A failed lookup counts every URL
+// as new; Save reports the real error.
+func (c *Crawler) newURLs(ctx context.Context, urls []string) map[string]bool {
+	existing, err := c.store.ExistingURLs(ctx, urls)
+	if err != nil {
+		slog.Warn("Failed to look up stored URLs, counting all as new", "urls", len(urls), "error", err)
+	}
+	fresh := make(map[string]bool)
+	for _, url := range urls {
+		if !existing[url] {
+			fresh[url] = true
+		}
+	}
+	return fresh
+}
+
+// --- Load and Render a Page in Chrome ---
+// proxy, when non-empty, routes the browser through that proxy server and
+// scroll triggers lazy-loaded listings before the HTML is captured.
+// Cancelling ctx shuts the browser down and aborts the load. The returned
+// URL is where the page ended up after any redirects.
+func (c *Crawler) loadPage(ctx context.Context, url, proxy string, scroll bool) (string, string, error) {
+	host := urlHost(url)
+	if err := c.throttle.Wait(ctx, host); err != nil {
+		return "", "", err
+	}
+
+	cookies, err := c.sessionCookies(ctx, host)
+	if err != nil {
+		return "", "", err
+	}
+
+	browserCtx, setup, cancel := c.newBrowser(ctx, host, proxy)
+	defer cancel()
+	if len(cookies) > 0 {
+		setup = append(setup, network.SetCookies(cookies))
+	}
+	if c.blockedDomains != nil {
+		setup = append(setup, blockRequests(browserCtx, c.blockedDomains))
+	}
+
+	if trace := traceFrom(ctx); trace != nil {
+		trace.record(func(t *pageTrace) { t.fetch = fetchModeChrome })
+		var once sync.Once
+		chromedp.ListenTarget(browserCtx, func(ev any) {
+			if resp, ok := ev.(*network.EventResponseReceived); ok && resp.Type == network.ResourceTypeDocument {
+				once.Do(func() { trace.record(func(t *pageTrace) { t.status = int(resp.Response.Status) }) })
+			}
+		})
+	}
+	var status atomic.Int64 // of the page's own document response
+	if c.throttle != nil {
+		var once sync.Once
+		chromedp.ListenTarget(browserCtx, func(ev any) {
+			// The first document response is the page itself.
+			if resp, ok := ev.(*network.EventResponseReceived); ok && resp.Type == network.ResourceTypeDocument {
+				once.Do(func() {
+					status.Store(resp.Response.Status)
+					c.throttle.Observe(host, int(resp.Response.Status), cdpHeaders(resp.Response.Headers))
+				})
+			}
+		})
+	}
+
+	var finalURL string
+	err = chromedp.Run(browserCtx,
+		setup,
+		chromedp.Navigate(url),
+		chromedp.WaitVisible(`body`, chromedp.ByQuery),
+		chromedp.Location(&finalURL),
+	)
+	if status.Load() == http.StatusTooManyRequests {
+		return "", "", errTooManyRequests
+	}
+	if err != nil {
+		// Chrome gives up on redirect loops itself.
+		if strings.Contains(err.Error(), "ERR_TOO_MANY_REDIRECTS") {
+			return "", "", fmt.Errorf("%w: %s", errRedirectLoop, url)
+		}
+		return "", "", err
+	}
+	if domainCfg := c.config.forHost(host); domainCfg.WaitSelector != "" {
+		waitForSelector(browserCtx, url, domainCfg.WaitSelector, domainCfg.waitSelectorTimeout())
+	}
+
+	var htmlContent string
+	if err := chromedp.Run(browserCtx, chromedp.OuterHTML(`html`, &htmlContent)); err != nil {
+		return "", "", err
+	}
+	if scroll {
+		//
+		htmlContent = c.loadMoreListings(browserCtx, url, htmlContent)
+		//
+	}
+	if c.shadowDOM {
+		htmlContent += collectShadowLinks(browserCtx, url)
+	}
+	if htmlContent, err = c.limitHTML(url, htmlContent); err != nil {
+		return "", "", err
+	}
+	return htmlContent, finalURL, nil
+}
+
+// --- Extra Request Headers for a Host ---
+// Accept-Language defaults to en-US, or the host's fingerprint profile
+// language; headers from the domain config override both.
+const defaultAcceptLanguage = "en-US,en;q=0.9"
+
+func (c *Crawler) requestHeaders(host string) map[string]string {
+	headers := map[string]string{"Accept-Language": defaultAcceptLanguage}
+	if c.fingerprints && c.deviceFor(host) == nil {
+		headers["Accept-Language"] = fingerprintFor(host).AcceptLanguage
+	}
+	for name, value := range c.config.forHost(host).Headers {
+		headers[http.CanonicalHeaderKey(name)] = value
+	}
+	return headers
+}
+
+// --- Start a Browser Tab for a Host ---
+// The tab comes from the shared browser pool when there is one, otherwise
+// a browser is launched for it. The returned context is bounded by the
+// host's crawl timeout. setup must run before the first navigation so the
+// host's fingerprint profile applies to it.
+func (c *Crawler) newBrowser(ctx context.Context, host, proxy string) (context.Context, chromedp.Tasks, context.CancelFunc) {
+	opts := chromedp.DefaultExecAllocatorOptions[:]
+	if proxy != "" {
+		opts = append(opts, chromedp.ProxyServer(proxy))
+	}
+	var setup chromedp.Tasks
+	var acceptLanguage string
+	// A device brings its own user agent and viewport, which a desktop
+	// fingerprint profile would contradict.
+	if dev := c.deviceFor(host); dev != nil {
+		setup = chromedp.Tasks{chromedp.Emulate(dev)}
+		slog.Debug("Emulating device", "host", host, "device", dev.Name)
+	} else if c.fingerprints || c.stealth {
+		profile := fingerprintFor(host)
+		if !c.fingerprints {
+			profile = randomFingerprint()
+		}
+		opts = append(opts, profile.allocatorOptions()...)
+		setup = profile.emulate()
+		if c.stealth {
+			setup = append(setup, profile.stealth())
+		}
+		slog.Debug("Using fingerprint profile", "host", host, "profile", profile.Name)
+		acceptLanguage = profile.AcceptLanguage
+	}
+	if c.stealth {
+		opts = append(opts, stealthAllocatorOptions()...)
+	}
+	headers := make(network.Headers)
+	for name, value := range c.requestHeaders(host) {
+		headers[name] = value
+	}
+	// A random stealth profile's language replaces the default one.
+	if acceptLanguage != "" && !c.config.forHost(host).setsHeader("Accept-Language") {
+		headers["Accept-Language"] = acceptLanguage
+	}
+	setup = append(setup, network.SetExtraHTTPHeaders(headers))
+
+	// A remote browser is already running, so launch options such as the
+	// proxy cannot be applied to it.
+	if c.browsers != nil && c.remoteWS == "" {
+		tabCtx, cancelTab, err := c.browsers.Tab(ctx, proxy)
+		if err == nil {
+			tabCtx, cancelTimeout := context.WithTimeout(tabCtx, c.config.forHost(host).crawlTimeout())
+			return tabCtx, setup, func() {
+				cancelTimeout()
+				cancelTab()
+			}
+		}
+		slog.Warn("No pooled browser available, launching one for the page", "host", host, "error", err)
+	}
+	var allocCtx context.Context
+	var cancelAlloc context.CancelFunc
+	if c.remoteWS != "" {
+		if proxy != "" {
+			slog.Warn("Proxy is ignored when connected to a remote browser", "host", host, "proxy", proxy)
+		}
+		allocCtx, cancelAlloc = chromedp.NewRemoteAllocator(ctx, c.remoteWS)
+	} else {
+		allocCtx, cancelAlloc = chromedp.NewExecAllocator(ctx, opts...)
+	}
+	browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
+	browserCtx, cancelTimeout := context.WithTimeout(browserCtx, c.config.forHost(host).crawlTimeout())
+
+	return browserCtx, setup, func() {
+		cancelTimeout()
+		cancelBrowser()
+		cancelAlloc()
+	}
+}
+
+// --- Wait for a Configured Selector ---
+// Bounded by timeout so a selector that never appears leaves enough of the
+// crawl timeout to capture the page as it is.
+func waitForSelector(ctx context.Context, url, selector string, timeout time.Duration) {
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := chromedp.Run(waitCtx, chromedp.WaitVisible(selector, chromedp.ByQuery))
+	traceFrom(ctx).record(func(t *pageTrace) {
+		t.waitSelector = "found"
+		if err != nil {
+			t.waitSelector = "timeout"
+		}
+	})
+	if err != nil {
+		slog.Warn("Wait selector did not appear, using page as loaded", "url", url, "selector", selector, "error", err)
+	}
+}
+
+// --- Retry Region-Restricted Pages Through Regional Proxies ---
+// Returns the first unblocked HTML, or the original HTML and true when no
+// suitable proxy exists or every proxy is blocked as well.
+func (c *Crawler) retryViaRegionProxies(ctx context.Context, pageURL, htmlContent string) (string, bool) {
+	proxies := regionProxiesFor(urlHost(pageURL))
+	if len(proxies) == 0 {
+		slog.Warn("Page is region-restricted and no suitable proxy is configured", "url", pageURL)
+		return htmlContent, true
+	}
+
+	for _, proxy := range proxies {
+		if ctx.Err() != nil {
+			break
+		}
+		slog.Info("Retrying region-restricted page via proxy", "url", pageURL, "proxy", proxy)
+		retried, _, err := c.fetchPage(ctx, pageURL, proxy, true)
+		if err != nil {
+			slog.Warn("Proxy retry failed", "url", pageURL, "proxy", proxy, "error", err)
+			continue
+		}
+		if !isRegionBlocked(retried) {
+			return retried, false
+		}
+	}
+
+	slog.Warn("Page is region-restricted from every configured proxy", "url", pageURL)
+	return htmlContent, true
+}
+
+// --- Handle Infinite Scrolling ---
+// Each attempt scrolls to the bottom in uneven steps of part of a viewport,
+// sometimes backing up a little, then pauses for a random delay in
+// [scrollDelayMin, scrollDelayMax] so lazy listings can load. Steps per
+// attempt are capped so endlessly growing pages still finish.
+func (c *Crawler) performInfiniteScroll(ctx context.Context, attempts int) {
+	trace := traceFrom(ctx)
+	for i := 0; i < attempts; i++ {
+		trace.record(func(t *pageTrace) { t.scrolls++ })
+		for step := 0; step < maxScrollSteps; step++ {
+			var atBottom bool
+			err := chromedp.Run(ctx,
+				chromedp.Evaluate(scrollStepScript(), &atBottom),
+				chromedp.Sleep(randomDuration(scrollStepPauseMin, scrollStepPauseMax)),
+			)
+			if err != nil {
+				slog.Warn("Scrolling error", "error", err)
+				return
+			}
+			if atBottom {
+				break
+			}
+		}
+		if err := chromedp.Run(ctx, chromedp.Sleep(randomDuration(c.scrollDelayMin, c.scrollDelayMax))); err != nil {
+			slog.Warn("Scrolling error", "error", err)
+			return
+		}
+	}
+}
+
+// --- Randomized Scroll Step ---
+// Scrolls down 40-100% of the viewport, or about one time in six up by
+// 10-25%, and reports whether the bottom of the page has been reached.
+func scrollStepScript() string {
+	fraction := 0.4 + rand.Float64()*0.6
+	if rand.Intn(6) == 0 {
+		fraction = -(0.1 + rand.Float64()*0.15)
+	}
+	return fmt.Sprintf(`(() => {
+		window.scrollBy(0, Math.round(window.innerHeight * %.3f));
+		return window.innerHeight + window.scrollY >= document.body.scrollHeight - 2;
+	})()`, fraction)
+}
+
+// --- Random Duration in [min, max] ---
+func randomDuration(min, max time.Duration) time.Duration {
+	if max <= min {
+		return min
+	}
+	return min + time.Duration(rand.Int63n(int64(max-min)+1))
+}
+
+// --- Listing Strategies ---
+// A listing page either paginates through a next-page control, loads more
+// products as it is scrolled, or shows everything at once.
+const (
+	strategyPaginate = "paginate"
+	strategyScroll   = "scroll"
+	strategyNone     = "none"
+)
+
+// --- Load the Rest of a Listing ---
+// Uses the domain's listing strategy, or the one its pagination settings
+// imply, or probes the page once when it has none, and then pages,
+// scrolls or clicks "load more" accordingly; see pagination.go.
+func (c *Crawler) loadMoreListings(ctx context.Context, url, htmlContent string) string {
+	domainCfg := c.config.forHost(urlHost(url))
+	nextSelector := domainCfg.nextPageSelector()
+	strategy := domainCfg.ListingStrategy
+	if strategy == "" {
+		strategy = domainCfg.configuredPagination()
+	}
+	if strategy == "" {
+		strategy = c.detectStrategy(ctx, nextSelector)
+	}
+	slog.Info("Listing strategy chosen", "url", url, "strategy", strategy)
+	traceFrom(ctx).record(func(t *pageTrace) { t.strategy = strategy })
+
+	switch strategy {
+	case strategyPaginate:
+		return c.paginate(ctx, url, htmlContent, domainCfg.maxListingPages(), c.clickedPage(ctx, url, nextSelector))
+	case strategyPageURL:
+		return c.paginate(ctx, url, htmlContent, domainCfg.maxListingPages(), c.numberedPage(ctx, url, domainCfg))
+	case strategyLoadMore:
+		return c.clickLoadMore(ctx, url, htmlContent, domainCfg)
+	case strategyScroll:
+		c.performInfiniteScroll(ctx, domainCfg.scrollAttempts())
+		chromedp.Run(ctx, chromedp.OuterHTML(`html`, &htmlContent))
+	}
+	return htmlContent
+}
+
+// --- Collect a Listing Page's New Product URLs ---
+// Adds the page's product URLs that neither this run nor earlier pages of
+// the listing have seen to listed, for the no-progress check. Without a
+// progress tracker nothing is extracted.
+func (c *Crawler) newListingURLs(htmlContent, pageURL string, listed map[string]bool) map[string]bool {
+	if c.progress == nil {
+		return nil
+	}
+	if listed == nil {
+		listed = make(map[string]bool)
+	}
+	for _, productURL := range c.listingURLs(htmlContent, pageURL) {
+		if _, seen := c.seen.Load(productURL); !seen {
+			listed[productURL] = true
+		}
+	}
+	return listed
+}
+
+// --- Detect Listing Strategy ---
+// A visible next-page control means pagination, even when the page also
+// grows on scroll. Otherwise one jump to the bottom tells whether scrolling
+// loads more content.
+func (c *Crawler) detectStrategy(ctx context.Context, nextSelector string) string {
+	if nextPageExists(ctx, nextSelector) {
+		return strategyPaginate
+	}
+
+	var before, after int
+	err := chromedp.Run(ctx,
+		chromedp.Evaluate(`document.body.scrollHeight`, &before),
+		chromedp.Evaluate(`window.scrollTo(0, document.body.scrollHeight)`, nil),
+		chromedp.Sleep(randomDuration(c.scrollDelayMin, c.scrollDelayMax)),
+		chromedp.Evaluate(`document.body.scrollHeight`, &after),
+	)
+	if err != nil {
+		slog.Warn("Scroll probe failed, scrolling anyway", "error", err)
+		return strategyScroll
+	}
+	if after > before {
+		return strategyScroll
+	}
+	return strategyNone
+}
+
+// --- Check for a Next-Page Control ---
+func nextPageExists(ctx context.Context, selector string) bool {
+	var exists bool
+	script := fmt.Sprintf(`document.querySelector(%s) !== null`, jsString(selector))
+	if err := chromedp.Run(ctx, chromedp.Evaluate(script, &exists)); err != nil {
+		return false
+	}
+	return exists
+}
+
+// --- Handle Pagination ---
+func clickNextPage(ctx context.Context, selector string) bool {
+	if !nextPageExists(ctx, selector) {
+		return false
+	}
+
+	err := chromedp.Run(ctx,
+		chromedp.Evaluate(fmt.Sprintf(`document.querySelector(%s).click()`, jsString(selector)), nil),
+		chromedp.Sleep(pageLoadDelay),
+		chromedp.WaitVisible(`body`, chromedp.ByQuery),
+	)
+	return err == nil
+}
+
+// --- Quote a String for Embedding in JavaScript ---
+func jsString(s string) string {
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
+
+// --- Store Product URLs in Database ---
+// sourceURL is the crawled page the URLs were extracted from and seed the
+// seed URL whose crawl reached that page. Each record's Domain is the
+// host of its own URL, which can differ from the seed's. URLs new to
+// storage are also published to the product sink; the number of new URLs
+// is returned.
+func (c *Crawler) storeProductURLs(ctx context.Context, urls []string, sourceURL, seed string) int {
+	for _, url := range urls {
+		c.seeds.Add(url, seed)
+	}
+	fresh := c.newURLs(ctx, urls)
+	if c.dryRun {
+		for _, url := range urls {
+			slog.Info("Dry run: would store product URL", "url", url, "domain", urlHost(url), "source", sourceURL)
+		}
+		c.recordFresh(fresh)
+		return len(fresh)
+	}
+
+	records := make([]ProductURL, 0, len(urls))
+	for _, url := range urls {
+		records = append(records, ProductURL{Domain: urlHost(url), URL: url, SourceURL: sourceURL, Seed: seed})
+	}
+	if err := c.store.Save(ctx, records); err != nil {
+		slog.Error("Failed to store product URLs", "source", sourceURL, "error", err)
+		c.stats.Error(urlHost(sourceURL))
+		c.audit.Error("store_failed", "url", sourceURL, "error", err.Error())
+		return len(fresh)
+	}
+	c.recordFresh(fresh)
+	c.publishNew(ctx, records, fresh)
+	return len(fresh)
+}
+
+func (c *Crawler) recordFresh(fresh map[string]bool) {
+	for url := range fresh {
+		c.fresh.Add(url)
+	}
+}
+
+// --- Publish Newly Stored URLs to the Sink ---
+// A failed publish is logged and never fails the crawl.
+func (c *Crawler) publishNew(ctx context.Context, records []ProductURL, fresh map[string]bool) {
+	if c.sink == nil || len(fresh) == 0 {
+		return
+	}
+	now := time.Now().UTC()
+	events := make([]productEvent, 0, len(fresh))
+	for _, record := range records {
+		if fresh[record.URL] {
+			events = append(events, productEvent{URL: record.URL, Domain: record.Domain, SourceURL: record.SourceURL, Seed: record.Seed, RunID: c.runID, DiscoveredAt: now})
+		}
+	}
+
+	publishCtx, cancel := context.WithTimeout(ctx, sinkTimeout)
+	defer cancel()
+	if err := c.sink.Publish(publishCtx, events); err != nil {
+		slog.Warn("Failed to publish new products", "domain", records[0].Domain, "products", len(events), "error", err)
+		return
+	}
+	slog.Debug("Published new products", "domain", records[0].Domain, "products", len(events))
+}
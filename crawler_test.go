@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// newTestCrawler returns a crawler that fetches pages over plain HTTP and
+// keeps everything in memory, with every optional feature off.
+func newTestCrawler(t *testing.T) *Crawler {
+	t.Helper()
+	audit, _, err := openAuditLog("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Crawler{
+		store:     newMemoryStore(),
+		visited:   newMemoryVisitedSet(),
+		config:    &Config{},
+		seeds:     newSeedTracker(),
+		fresh:     newFreshURLs(),
+		fetchMode: fetchModeHTTP,
+		stats:     newCrawlStats(),
+		sessions:  newSessionCache(),
+		audit:     audit,
+	}
+}
+
+// setGlobal replaces a package-level setting for the rest of the test.
+func setGlobal[T any](t *testing.T, global *T, value T) {
+	t.Helper()
+	previous := *global
+	*global = value
+	t.Cleanup(func() { *global = previous })
+}
+
+// crawlPage scrapes one listing page, and whatever it follows, and returns
+// the results.
+func crawlPage(t *testing.T, c *Crawler, pageURL string) []CrawlResult {
+	t.Helper()
+	resultChan := make(chan CrawlResult, 16)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go c.scrapeWebsite(context.Background(), queueJob{URL: pageURL}, resultChan, &wg)
+	go func() {
+		wg.Wait()
+		close(resultChan)
+	}()
+	var results []CrawlResult
+	for result := range resultChan {
+		results = append(results, result)
+	}
+	return results
+}
+
+func TestFilterSeenDeduplicatesAcrossSeeds(t *testing.T) {
+	c := newTestCrawler(t)
+	seedA, seedB := "https://www.amazon.in/s?k=phone", "https://www.amazon.in/s?k=mobile"
+
+	fresh, crossSeed := c.filterSeen([]string{"https://www.amazon.in/dp/B0AAAAAAAA/", "https://www.amazon.in/dp/B0BBBBBBBB/"}, seedA)
+	if len(fresh) != 2 || len(crossSeed) != 0 {
+		t.Fatalf("first page: fresh %v, cross-seed %v; want both URLs fresh", fresh, crossSeed)
+	}
+
+	fresh, crossSeed = c.filterSeen([]string{"https://www.amazon.in/dp/B0AAAAAAAA/", "https://www.amazon.in/dp/B0CCCCCCCC/"}, seedB)
+	if len(fresh) != 1 || fresh[0] != "https://www.amazon.in/dp/B0CCCCCCCC/" {
+		t.Errorf("second seed: fresh = %v, want only the new URL", fresh)
+	}
+	if len(crossSeed) != 1 || crossSeed[0] != "https://www.amazon.in/dp/B0AAAAAAAA/" {
+		t.Errorf("second seed: cross-seed = %v, want the URL first seen under the other seed", crossSeed)
+	}
+
+	fresh, crossSeed = c.filterSeen([]string{"https://www.amazon.in/dp/B0BBBBBBBB/"}, seedA)
+	if len(fresh) != 0 || len(crossSeed) != 0 {
+		t.Errorf("same seed again: fresh %v, cross-seed %v; want the URL dropped", fresh, crossSeed)
+	}
+}
+
+func TestStoreProductURLsWithFakes(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCrawler(t)
+	store := c.store.(*memoryStore)
+	source := "https://www.amazon.in/s?k=phone"
+	seed := "https://www.amazon.in/"
+
+	if err := store.Save(ctx, []ProductURL{{URL: "https://www.amazon.in/dp/B0AAAAAAAA/", Domain: "www.amazon.in"}}); err != nil {
+		t.Fatal(err)
+	}
+	urls := []string{
+		"https://www.amazon.in/dp/B0AAAAAAAA/",
+		"https://www.amazon.in/dp/B0BBBBBBBB/",
+		"https://m.amazon.in/dp/B0CCCCCCCC/",
+	}
+	if got := c.storeProductURLs(ctx, urls, source, seed); got != 2 {
+		t.Errorf("storeProductURLs = %d new URLs, want 2", got)
+	}
+
+	records, err := store.Records(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]struct {
+		domain    string
+		seenCount int
+	}{
+		"https://www.amazon.in/dp/B0AAAAAAAA/": {"www.amazon.in", 2},
+		"https://www.amazon.in/dp/B0BBBBBBBB/": {"www.amazon.in", 1},
+		"https://m.amazon.in/dp/B0CCCCCCCC/":   {"m.amazon.in", 1},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("stored %d records, want %d", len(records), len(want))
+	}
+	for _, record := range records {
+		w := want[record.URL]
+		if record.Domain != w.domain || record.SeenCount != w.seenCount {
+			t.Errorf("%s: domain %q, seen %d; want %q, %d", record.URL, record.Domain, record.SeenCount, w.domain, w.seenCount)
+		}
+		if !store.seeds[record.URL][seed] {
+			t.Errorf("%s: seed not attributed", record.URL)
+		}
+	}
+}
+
+func TestConcurrentClaimsCrawlOnce(t *testing.T) {
+	requests := new(atomic.Int32)
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		fmt.Fprint(w, `<a href="/dp/B0AAAAAAAA/">Phone</a>`)
+	}))
+	defer site.Close()
+	pageURL := site.URL + "/phones"
+
+	// Two workers of one process and a second process sharing the visited
+	// set all race for the same page.
+	first := newTestCrawler(t)
+	crawlers := []*Crawler{first, first, newTestCrawlerSharing(t, first)}
+	resultChan := make(chan CrawlResult, 64)
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := range 48 {
+		wg.Add(1)
+		go func() {
+			<-start
+			crawlers[i%len(crawlers)].scrapeWebsite(context.Background(), queueJob{URL: pageURL}, resultChan, &wg)
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(resultChan)
+
+	if n := len(resultChan); n != 1 {
+		t.Errorf("got %d results, want a single crawl", n)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("page fetched %d times, want once", n)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chromedp/chromedp/device"
+)
+
+// --- Mobile Device Emulation ---
+// Some retailers serve a simpler product grid to phones. A device name from
+// chromedp's device list ("iPhone 11", "Pixel 5", "iPad Pro landscape")
+// makes the browser emulate that device's viewport, scale, user agent and
+// touch support. "desktop" or an empty name keeps the desktop browser.
+const desktopDevice = "desktop"
+
+// emulatedDevices maps normalized device names to chromedp devices.
+var emulatedDevices = func() map[string]device.Info {
+	devices := make(map[string]device.Info)
+	for d := device.BlackberryPlayBook; d <= device.MotoG4landscape; d++ {
+		info := d.Device()
+		devices[normalizeDeviceName(info.Name)] = info
+	}
+	return devices
+}()
+
+// --- Normalize Device Name ---
+// Case and spacing are ignored, so "iphone11" matches "iPhone 11".
+func normalizeDeviceName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), ""))
+}
+
+// --- Look Up a Device by Name ---
+// Returns nil for the desktop browser.
+func lookupDevice(name string) (*device.Info, error) {
+	if name == "" || strings.EqualFold(name, desktopDevice) {
+		return nil, nil
+	}
+	info, ok := emulatedDevices[normalizeDeviceName(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown device %q", name)
+	}
+	return &info, nil
+}
+
+// --- Device Emulated for a Host ---
+// The domain's configured device wins over -device.
+func (c *Crawler) deviceFor(host string) *device.Info {
+	if name := c.config.forHost(host).Device; name != "" {
+		info, _ := lookupDevice(name) // validated by loadConfig
+		return info
+	}
+	return c.device
+}
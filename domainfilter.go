@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// --- Domain Allow/Deny Filter ---
+// Entries match on registrable domain (eTLD+1), so "www.amazon.com" also
+// covers "images.amazon.com". A "*.example.com" entry matches only hosts
+// below example.com. Deny always wins; a non-empty allow list is a whitelist.
+type domainFilter struct {
+	allow []string
+	deny  []string
+}
+
+// --- Active Filter for Discovered URLs ---
+var domainRules = &domainFilter{}
+
+// --- Build Domain Filter ---
+// The flag lists and the config's allowDomains and denyDomains are merged.
+// An explicit allow list wins; otherwise the seed hosts are allowed so the
+// crawl stays on the target retailers.
+func buildDomainFilter(allowList, denyList string, config *Config, seeds []string) (*domainFilter, error) {
+	allow, err := parseDomainList(allowList)
+	if err != nil {
+		return nil, fmt.Errorf("-allow-domains: %w", err)
+	}
+	deny, err := parseDomainList(denyList)
+	if err != nil {
+		return nil, fmt.Errorf("-deny-domains: %w", err)
+	}
+	allow = append(allow, cleanDomains(config.AllowDomains)...)
+	deny = append(deny, cleanDomains(config.DenyDomains)...)
+
+	if len(allow) == 0 {
+		for _, seed := range seeds {
+			parsed, err := url.Parse(seed)
+			if err != nil || parsed.Host == "" {
+				slog.Warn("Cannot derive allowed host from seed", "seed", seed, "error", err)
+				continue
+			}
+			allow = append(allow, strings.ToLower(parsed.Hostname()))
+		}
+	}
+	return &domainFilter{allow: allow, deny: deny}, nil
+}
+
+// --- Parse Comma List or @file of Domains ---
+// A value starting with "@" names a file with one domain per line; blank
+// lines and lines starting with "#" are ignored.
+func parseDomainList(value string) ([]string, error) {
+	var raw []string
+	if path, ok := strings.CutPrefix(value, "@"); ok {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			raw = append(raw, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	} else {
+		raw = strings.Split(value, ",")
+	}
+
+	return cleanDomains(raw), nil
+}
+
+// --- Lowercase Domain Entries, Dropping Blanks and Comments ---
+func cleanDomains(raw []string) []string {
+	var domains []string
+	for _, entry := range raw {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry != "" && !strings.HasPrefix(entry, "#") {
+			domains = append(domains, entry)
+		}
+	}
+	return domains
+}
+
+// --- Check a URL Against the Filter ---
+// A nil filter allows every URL with a host.
+func (f *domainFilter) Allowed(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Hostname() == "" {
+		return false
+	}
+	if f == nil {
+		return true
+	}
+	host := strings.ToLower(parsed.Hostname())
+
+	for _, entry := range f.deny {
+		if matchDomain(entry, host) {
+			return false
+		}
+	}
+	if len(f.allow) == 0 {
+		return true
+	}
+	for _, entry := range f.allow {
+		if matchDomain(entry, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// --- Match One Entry Against a Host ---
+func matchDomain(entry, host string) bool {
+	if suffix, ok := strings.CutPrefix(entry, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return registrableDomain(entry) == registrableDomain(host)
+}
+
+// --- Registrable Domain (eTLD+1) of a Host ---
+// Hosts without one, such as "localhost" or bare suffixes, are returned as is.
+func registrableDomain(host string) string {
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
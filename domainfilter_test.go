@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildDomainFilterMergesConfigAndFlags(t *testing.T) {
+	seeds := []string{"https://www.amazon.in/s?k=phone"}
+	config := &Config{AllowDomains: []string{" Snapdeal.com ", "# staging", ""}, DenyDomains: []string{"ads.amazon.in"}}
+	tests := []struct {
+		name                string
+		allowFlag           string
+		config              *Config
+		wantAllow, wantDeny []string
+	}{
+		{"seed hosts by default", "", &Config{}, []string{"www.amazon.in"}, nil},
+		{"config only", "", config, []string{"snapdeal.com"}, []string{"ads.amazon.in"}},
+		{"flag and config", "myntra.com", config, []string{"myntra.com", "snapdeal.com"}, []string{"ads.amazon.in"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := buildDomainFilter(tt.allowFlag, "", tt.config, seeds)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(filter.allow, tt.wantAllow) || !reflect.DeepEqual(filter.deny, tt.wantDeny) {
+				t.Errorf("allow %q, deny %q; want %q, %q", filter.allow, filter.deny, tt.wantAllow, tt.wantDeny)
+			}
+		})
+	}
+
+	filter, err := buildDomainFilter("", "", config, seeds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for url, want := range map[string]bool{
+		"https://www.snapdeal.com/product/phone/123456": true,
+		"https://www.amazon.in/dp/B0AAAAAAAA/":          false,
+		"https://ads.amazon.in/click":                   false,
+	} {
+		if got := filter.Allowed(url); got != want {
+			t.Errorf("Allowed(%s) = %v, want %v", url, got, want)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// --- Cross-Domain Duplicate Products ---
+// Marketplaces list the same product under different URLs and domains.
+// Products are fingerprinted by GTIN when the page carries one, otherwise
+// by normalized brand and title; products with neither get no fingerprint
+// and are never grouped, so pages missing those fields are not lumped
+// together. -report-dupes writes the clusters of URLs that share one.
+
+// JSON-LD keys holding a GTIN, most specific first.
+var gtinKeys = []string{"gtin13", "gtin12", "gtin14", "gtin8", "gtin"}
+
+// --- Extract Title, Brand, GTIN and SKU ---
+// Configured selectors win; otherwise the title comes from og:title or the
+// first <h1> and brand, GTIN and SKU from the page's JSON-LD, the SKU also
+// from itemprop="sku" microdata.
+func extractIdentity(doc *goquery.Document, cfg DomainConfig, product *Product) {
+	product.Title = selectText(doc, cfg.TitleSelector)
+	if product.Title == "" {
+		product.Title = strings.TrimSpace(doc.Find(`meta[property="og:title"]`).First().AttrOr("content", ""))
+	}
+	if product.Title == "" {
+		product.Title = strings.TrimSpace(doc.Find("h1").First().Text())
+	}
+	product.Brand = selectText(doc, cfg.BrandSelector)
+	product.GTIN = normalizeGTIN(selectText(doc, cfg.GTINSelector))
+	product.SKU = selectText(doc, cfg.SKUSelector)
+	if product.SKU == "" {
+		item := doc.Find(`[itemprop="sku"]`).First()
+		product.SKU = strings.TrimSpace(item.AttrOr("content", item.Text()))
+	}
+	if product.Brand != "" && product.GTIN != "" && product.SKU != "" {
+		return
+	}
+
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		var data any
+		if json.Unmarshal([]byte(s.Text()), &data) != nil {
+			return true
+		}
+		if product.Brand == "" {
+			product.Brand = jsonName(findJSONKey(data, "brand"))
+		}
+		for _, key := range gtinKeys {
+			if product.GTIN != "" {
+				break
+			}
+			if value, ok := findJSONKey(data, key).(string); ok {
+				product.GTIN = normalizeGTIN(value)
+			}
+		}
+		if product.SKU == "" {
+			product.SKU = jsonScalar(findJSONKey(data, "sku"))
+		}
+		return product.Brand == "" || product.GTIN == "" || product.SKU == ""
+	})
+}
+
+// --- Name of a schema.org Brand ---
+// Accepts a bare string as well as {"name": ...} objects.
+func jsonName(data any) string {
+	switch v := data.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case map[string]any:
+		return jsonName(v["name"])
+	case []any:
+		if len(v) > 0 {
+			return jsonName(v[0])
+		}
+	}
+	return ""
+}
+
+// --- Text of a JSON-LD Scalar ---
+// SKUs are sometimes written as numbers.
+func jsonScalar(data any) string {
+	switch v := data.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+// --- Normalize a GTIN ---
+// Keeps the digits and pads them to GTIN-14, so the UPC-A and EAN-13 forms
+// of one code match. Anything that is not 8 to 14 digits is dropped.
+func normalizeGTIN(raw string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, raw)
+	if len(digits) < 8 || len(digits) > 14 {
+		return ""
+	}
+	return strings.Repeat("0", 14-len(digits)) + digits
+}
+
+// --- Content Fingerprint of a Product ---
+// Empty when the product has neither a GTIN nor both brand and title.
+func productFingerprint(product Product) string {
+	if product.GTIN != "" {
+		return "gtin:" + product.GTIN
+	}
+	brand, title := normalizeText(product.Brand), normalizeText(product.Title)
+	if brand == "" || title == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(brand + "\x00" + title))
+	return "text:" + hex.EncodeToString(sum[:8])
+}
+
+// --- Normalize Text for Fingerprinting ---
+// Lower-cases and keeps letters and digits, one space between words.
+func normalizeText(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	return strings.Join(words, " ")
+}
+
+// --- Cluster of Duplicate Products ---
+type dupeCluster struct {
+	Fingerprint string   `json:"fingerprint"`
+	Title       string   `json:"title,omitempty"`
+	Brand       string   `json:"brand,omitempty"`
+	GTIN        string   `json:"gtin,omitempty"`
+	Domains     []string `json:"domains"`
+	URLs        []string `json:"urls"`
+}
+
+// --- Group Products Believed to Be the Same ---
+// Only fingerprints shared by two or more URLs form a cluster. Clusters
+// and their URLs are sorted, largest cluster first.
+func findDuplicates(results []CrawlResult) []dupeCluster {
+	byFingerprint := make(map[string]*dupeCluster)
+	seen := make(map[string]bool)
+	for _, res := range results {
+		for _, product := range res.Products {
+			fingerprint := productFingerprint(product)
+			if fingerprint == "" || seen[fingerprint+" "+product.URL] {
+				continue
+			}
+			seen[fingerprint+" "+product.URL] = true
+			cluster := byFingerprint[fingerprint]
+			if cluster == nil {
+				cluster = &dupeCluster{Fingerprint: fingerprint, Title: product.Title, Brand: product.Brand, GTIN: product.GTIN}
+				byFingerprint[fingerprint] = cluster
+			}
+			cluster.URLs = append(cluster.URLs, product.URL)
+			if !slices.Contains(cluster.Domains, product.Domain) {
+				cluster.Domains = append(cluster.Domains, product.Domain)
+			}
+		}
+	}
+
+	clusters := []dupeCluster{}
+	for _, cluster := range byFingerprint {
+		if len(cluster.URLs) < 2 {
+			continue
+		}
+		sort.Strings(cluster.URLs)
+		sort.Strings(cluster.Domains)
+		clusters = append(clusters, *cluster)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if len(clusters[i].URLs) != len(clusters[j].URLs) {
+			return len(clusters[i].URLs) > len(clusters[j].URLs)
+		}
+		return clusters[i].Fingerprint < clusters[j].Fingerprint
+	})
+	return clusters
+}
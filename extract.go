@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log/slog"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// --- Regex Pattern for Product URLs ---
+// The optional scheme/host prefix lets absolute links keep their own host
+// so that off-domain URLs can be told apart from relative ones.
+var productURLPattern = regexp.MustCompile(`(https?://[a-zA-Z0-9.-]+)?/(dp|gp/product|product|item|shop|p)/[a-zA-Z0-9-_]+(/|\?|$)`)
+
+// --- Non-Product URL Defaults ---
+// Matches ending in these extensions are assets, matches under these path
+// prefixes are site chrome, and a final path segment shorter than
+// defaultMinSlugLength is too short to identify a product.
+var (
+	defaultExcludeExtensions = []string{
+		".css", ".js", ".json", ".xml", ".map",
+		".png", ".jpg", ".jpeg", ".gif", ".svg", ".webp", ".ico",
+		".woff", ".woff2", ".ttf", ".pdf",
+	}
+	defaultExcludePaths = []string{
+		"/help/", "/account/", "/login/", "/signin/", "/cart/", "/checkout/", "/customer-service/",
+	}
+)
+
+const defaultMinSlugLength = 3
+
+// defaultStripParams are tracking parameters dropped from product URLs
+// even when a domain keeps query parameters.
+var defaultStripParams = []string{
+	"utm_*", "gclid", "fbclid", "msclkid", "yclid", "igshid", "mc_cid", "mc_eid",
+	"ref", "ref_*", "tag", "spm", "_encoding", "pd_rd_*", "pf_rd_*",
+}
+
+// --- Extract Product URLs from Page ---
+// pageURL is the crawled page; relative matches are resolved against it,
+// so its path and query string never leak into product URLs. Every URL is
+// normalized (see normalizeURL) and keeps only the significant query
+// parameters, so tracking parameters never reach dedup or storage. Matches of
+// all patterns are merged in order of their position in the HTML, so the
+// result is deterministic and free of duplicates even when patterns
+// overlap. Extraction works on HTML alone and needs no browser, so saved
+// pages can be replayed through it.
+func extractProductURLs(htmlContent, pageURL string, cfg DomainConfig) []string {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		slog.Warn("Unparseable page URL, skipping extraction", "url", pageURL, "error", err)
+		return nil
+	}
+	patterns := cfg.productPatterns()
+	type located struct {
+		start, end int
+		pattern    *regexp.Regexp
+	}
+	var locations []located
+	for _, pattern := range patterns {
+		for _, loc := range pattern.FindAllStringIndex(htmlContent, -1) {
+			locations = append(locations, located{loc[0], loc[1], pattern})
+		}
+	}
+	sort.SliceStable(locations, func(i, j int) bool {
+		return locations[i].start < locations[j].start
+	})
+
+	uniqueURLs := make(map[string]bool)
+	var productURLs []string
+
+	for _, loc := range locations {
+		// The pattern ends on the "/" or the "?" that follows the slug;
+		// "/dp/X?th=1" and "/dp/X/" are the same product. Given a single
+		// URL, such as a sitemap <loc> or a link's href, it can also end on
+		// the end of the input; the "/" the pattern would have taken there
+		// is added, so "/dp/X" is that product too.
+		match := htmlContent[loc.start:loc.end]
+		if trimmed, ok := strings.CutSuffix(match, "?"); ok {
+			match = trimmed + "/"
+		} else if loc.end == len(htmlContent) && !strings.HasSuffix(match, "/") {
+			if m := loc.pattern.FindStringIndex(match + "/"); m != nil && m[0] == 0 && m[1] == len(match)+1 {
+				match += "/"
+			}
+		}
+		ref, err := url.Parse(html.UnescapeString(match))
+		if err != nil {
+			continue
+		}
+		// Absolute matches are resolved too, which cleans up "." and ".."
+		// segments in their paths.
+		resolved := base.ResolveReference(ref)
+		normalizeURL(resolved)
+		query := resolved.RawQuery
+		resolved.RawQuery = ""
+		if len(cfg.KeepParams) > 0 {
+			if query == "" {
+				query = followingQuery(htmlContent, loc.end)
+			}
+			resolved.RawQuery = significantQuery(query, cfg)
+		}
+		fullURL := resolved.String()
+		if !domainRules.Allowed(fullURL) {
+			slog.Debug("Off-domain URL filtered", "url", fullURL)
+			continue
+		}
+		if reason := nonProductReason(fullURL, cfg); reason != "" {
+			slog.Debug("Non-product URL filtered", "url", fullURL, "reason", reason)
+			continue
+		}
+		if !uniqueURLs[fullURL] {
+			uniqueURLs[fullURL] = true
+			productURLs = append(productURLs, fullURL)
+		}
+	}
+	return productURLs
+}
+
+// --- Normalize a Parsed URL in Place ---
+// Lowercases scheme and host and drops default ports and the fragment; the
+// path and query are left to the caller.
+func normalizeURL(u *url.URL) {
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if port := u.Port(); (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		u.Host = u.Hostname()
+	}
+	u.Fragment, u.RawFragment = "", ""
+}
+
+// --- Extract Product URLs from a Page's Links ---
+// Like extractProductURLs, but the href of every link is matched on its
+// own, so patterns see where a URL ends: "(/|\?|$)" then also takes
+// href="/dp/X", and "/dp/X#reviews" once the fragment is cut. URLs are
+// deduplicated in page order.
+func extractLinkedProductURLs(htmlContent, pageURL string, cfg DomainConfig) ([]string, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlContent))
+	if err != nil {
+		return nil, fmt.Errorf("parse listing page: %w", err)
+	}
+	seen := make(map[string]bool)
+	var productURLs []string
+	doc.Find("a[href]").Each(func(_ int, a *goquery.Selection) {
+		href, _, _ := strings.Cut(strings.TrimSpace(a.AttrOr("href", "")), "#")
+		for _, productURL := range extractProductURLs(href, pageURL, cfg) {
+			if !seen[productURL] {
+				seen[productURL] = true
+				productURLs = append(productURLs, productURL)
+			}
+		}
+	})
+	return productURLs, nil
+}
+
+// --- Query String Following a Match ---
+// The built-in pattern stops on the "?" that starts a query; a custom one
+// may stop right before it. The query runs to the end of the attribute
+// value and is HTML-unescaped, so "&amp;" separates parameters.
+func followingQuery(htmlContent string, end int) string {
+	start := end
+	if !strings.HasSuffix(htmlContent[:end], "?") {
+		if !strings.HasPrefix(htmlContent[end:], "?") {
+			return ""
+		}
+		start++
+	}
+	length := strings.IndexAny(htmlContent[start:], "\"'<> \t\r\n#")
+	if length < 0 {
+		length = len(htmlContent) - start
+	}
+	return html.UnescapeString(htmlContent[start : start+length])
+}
+
+// --- Reduce a Query to Its Significant Parameters ---
+// Keys and the values of repeated keys are sorted for a stable dedup key.
+func significantQuery(rawQuery string, cfg DomainConfig) string {
+	values, _ := url.ParseQuery(rawQuery) // keeps the parameters that parse
+	for name := range values {
+		if !cfg.keepsParam(name) {
+			delete(values, name)
+			continue
+		}
+		sort.Strings(values[name])
+	}
+	return values.Encode()
+}
+
+// --- Reject Obviously Non-Product URLs ---
+// Returns why rawURL is not a product page, or "" when it may be one.
+func nonProductReason(rawURL string, cfg DomainConfig) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "unparseable"
+	}
+	urlPath := strings.ToLower(parsed.Path)
+
+	for _, prefix := range cfg.excludePaths() {
+		if strings.HasPrefix(urlPath, strings.ToLower(prefix)) {
+			return "excluded path"
+		}
+	}
+	slug := path.Base(strings.TrimRight(urlPath, "/"))
+	for _, ext := range cfg.excludeExtensions() {
+		if strings.HasSuffix(slug, strings.ToLower(ext)) {
+			return "asset extension"
+		}
+	}
+	if len(slug) < cfg.minSlugLength() {
+		return "short slug"
+	}
+	return ""
+}
+
+// --- Host of a URL ---
+// Returns the lowercased host, or "" when rawURL cannot be parsed.
+func urlHost(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
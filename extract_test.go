@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExtractProductURLsMergesOverlappingPatterns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(path, []byte(`{"domains": {"shop.example.com": {"productPatterns": [
+		"/dp/[A-Z0-9]{10}/",
+		"/gp/product/[A-Z0-9]{10}/",
+		"https?://shop\\.example\\.com/(dp|gp/product)/[A-Z0-9]{10}/"
+	]}}}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.forHost("shop.example.com")
+	if len(cfg.productPatterns()) != 3 {
+		t.Fatalf("got %d product patterns, want 3", len(cfg.productPatterns()))
+	}
+
+	page := `<a href="/gp/product/B0BBBBBBBB/">Charger</a>
+		<a href="https://shop.example.com/dp/B0AAAAAAAA/">Phone</a>
+		<a href="/dp/B0AAAAAAAA/">Phone again</a>
+		<a href="https://shop.example.com/gp/product/B0CCCCCCCC/">Case</a>
+		<a href="/dp/B0DDDDDDDD/">Cable</a>
+		<a href="/gp/product/B0BBBBBBBB/">Charger again</a>`
+	want := []string{
+		"https://shop.example.com/gp/product/B0BBBBBBBB/",
+		"https://shop.example.com/dp/B0AAAAAAAA/",
+		"https://shop.example.com/gp/product/B0CCCCCCCC/",
+		"https://shop.example.com/dp/B0DDDDDDDD/",
+	}
+	for run := 1; run <= 3; run++ {
+		got := extractProductURLs(page, "https://shop.example.com/s?k=phone", cfg)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("run %d: extractProductURLs =\n%v\nwant\n%v", run, got, want)
+		}
+	}
+}
+
+func TestListingURLsFromSavedPages(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		pageURL string
+		config  *Config
+		allow   []string
+		want    []string
+	}{
+		{
+			name:    "amazon search",
+			fixture: "amazon-search.html",
+			pageURL: "https://www.amazon.in/s?k=iphone&page=2",
+			want: []string{
+				"https://www.amazon.in/dp/B0CHX1W1XY/",
+				"https://www.amazon.in/dp/B0CHX2F5QT/",
+				"https://www.amazon.in/gp/product/B09G9BL5CP/",
+				"https://www.amazon.in/dp/B0BDJ6ZMCC/",
+				"https://www.amazon.com/dp/B0CMTXKYKD/",
+			},
+		},
+		{
+			name:    "amazon search with allowed domains",
+			fixture: "amazon-search.html",
+			pageURL: "https://www.amazon.in/s?k=iphone&page=2",
+			allow:   []string{"amazon.in"},
+			want: []string{
+				"https://www.amazon.in/dp/B0CHX1W1XY/",
+				"https://www.amazon.in/dp/B0CHX2F5QT/",
+				"https://www.amazon.in/gp/product/B09G9BL5CP/",
+				"https://www.amazon.in/dp/B0BDJ6ZMCC/",
+			},
+		},
+		{
+			name:    "snapdeal listing",
+			fixture: "snapdeal-listing.html",
+			pageURL: "https://www.snapdeal.com/products/mobiles-mobile-phones?sort=plrty&q=Price:500,20000",
+			want: []string{
+				"https://www.snapdeal.com/product/samsung-galaxy-m14-5g-6gb/638219458543",
+				"https://www.snapdeal.com/product/redmi-12-5g-4gb/677361127342",
+				"https://www.snapdeal.com/product/nokia-105-single-sim/5764608212",
+			},
+		},
+		{
+			name:    "snapdeal listing keeping supc",
+			fixture: "snapdeal-listing.html",
+			pageURL: "https://www.snapdeal.com/products/mobiles-mobile-phones?sort=plrty",
+			config:  &Config{Domains: map[string]DomainConfig{"snapdeal.com": {KeepParams: []string{"supc", "utm_*"}}}},
+			want: []string{
+				"https://www.snapdeal.com/product/samsung-galaxy-m14-5g-6gb/638219458543",
+				"https://www.snapdeal.com/product/redmi-12-5g-4gb/677361127342?supc=SDL912345678",
+				"https://www.snapdeal.com/product/nokia-105-single-sim/5764608212",
+			},
+		},
+		{
+			name:    "myntra listing",
+			fixture: "myntra-listing.html",
+			pageURL: "https://www.myntra.com/men-shirts?p=1",
+			want: []string{
+				"https://www.myntra.com/shirts/roadster/roadster-men-navy-slim-fit-casual-shirt/11352730/buy",
+				"https://www.myntra.com/shirts/highlander/highlander-men-white-slim-fit-shirt/2155013/buy",
+				"https://www.myntra.com/shirts/here-now/here-and-now-men-olive-shirt/17428370/buy",
+				"https://www.myntra.com/shirts/wrogn/wrogn-men-black-slim-fit-shirt/13421598/buy",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Without allowed domains the filter is unset and lets every
+			// host through.
+			var filter *domainFilter
+			if tt.allow != nil {
+				filter = &domainFilter{allow: tt.allow}
+			}
+			setGlobal(t, &domainRules, filter)
+			c := newTestCrawler(t)
+			if tt.config != nil {
+				c.config = tt.config
+			}
+			got := c.listingURLs(readFixture(t, tt.fixture), tt.pageURL)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("listingURLs =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+// fixtureServer serves saved pages from testdata, each under the path it
+// is mapped to, so links resolve against a realistic page URL.
+func fixtureServer(t *testing.T, pages map[string]string) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	for path, fixture := range pages {
+		page := readFixture(t, fixture)
+		mux.HandleFunc(path, func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			io.WriteString(w, page)
+		})
+	}
+	site := httptest.NewServer(mux)
+	t.Cleanup(site.Close)
+	return site
+}
+
+// TestExtractionFromFixtureServer crawls saved listing pages over HTTP, as
+// a crawl with -fetch-mode http would. The fixture mixes relative links,
+// tracking parameters and anchors repeated for one product.
+func TestExtractionFromFixtureServer(t *testing.T) {
+	site := fixtureServer(t, map[string]string{"/listing-links.html": "listing-links.html"})
+	setGlobal(t, &domainRules, nil)
+	tests := []struct {
+		page string
+		want []string
+	}{
+		{"/listing-links.html", []string{"/product/wireless-earbuds-pro/", "/p/bluetooth-speaker-mini/", "/item/usb-c-charger-65w/", "/dp/B0EXAMPLE1/"}},
+		{"/listing-links.html?page=2&utm_source=mail", []string{"/product/wireless-earbuds-pro/", "/p/bluetooth-speaker-mini/", "/item/usb-c-charger-65w/", "/dp/B0EXAMPLE1/"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.page, func(t *testing.T) {
+			c := newTestCrawler(t)
+			results := crawlPage(t, c, site.URL+tt.page)
+			if len(results) != 1 {
+				t.Fatalf("got %d results, want 1", len(results))
+			}
+			var want []string
+			for _, path := range tt.want {
+				want = append(want, site.URL+path)
+			}
+			if got := results[0].URLs; !reflect.DeepEqual(got, want) {
+				t.Errorf("URLs =\n%q\nwant\n%q", got, want)
+			}
+
+			records, err := c.store.Records(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != len(want) {
+				t.Errorf("stored %d URLs, want %d", len(records), len(want))
+			}
+		})
+	}
+}
+
+func TestExtractProductURLsResolvesLinksAgainstThePage(t *testing.T) {
+	setGlobal(t, &domainRules, nil)
+	tests := []struct {
+		href, pageURL, want string
+	}{
+		{"/dp/B0AAAAAAAA/", "https://www.amazon.in/s?k=phone&page=2", "https://www.amazon.in/dp/B0AAAAAAAA/"},
+		{"/p/kettle-1l/", "https://shop.example.com/catalog/kitchen/?sort=price", "https://shop.example.com/p/kettle-1l/"},
+		{"https://m.shop.example.com/item/kettle-1l/", "https://shop.example.com/", "https://m.shop.example.com/item/kettle-1l/"},
+		{"HTTPS://Shop.Example.com:443/p/kettle-1l/?utm_source=mail", "https://shop.example.com/", "https://shop.example.com/p/kettle-1l/"},
+	}
+	for _, tt := range tests {
+		page := `<a href="` + tt.href + `">Kettle</a>`
+		got := extractProductURLs(page, tt.pageURL, DomainConfig{})
+		if len(got) != 1 || got[0] != tt.want {
+			t.Errorf("%s on %s = %q, want %s", tt.href, tt.pageURL, got, tt.want)
+		}
+	}
+}
+
+func TestCanonicalURL(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"https://www.amazon.in/dp/B0AAAAAAAA/", "https://www.amazon.in/dp/B0AAAAAAAA"},
+		{"HTTPS://WWW.Amazon.IN:443/dp/B0AAAAAAAA/#reviews", "https://www.amazon.in/dp/B0AAAAAAAA"},
+		{"http://shop.example.com:80/p/kettle?b=2&a=1", "http://shop.example.com/p/kettle?a=1&b=2"},
+		{"http://shop.example.com:8080/", "http://shop.example.com:8080/"},
+		{"  https://shop.example.com/p/kettle  ", "https://shop.example.com/p/kettle"},
+		{"/p/kettle", "/p/kettle"},
+	}
+	for _, tt := range tests {
+		if got := canonicalURL(tt.in); got != tt.want {
+			t.Errorf("canonicalURL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRegistrableDomain(t *testing.T) {
+	tests := []struct{ host, want string }{
+		{"www.amazon.in", "amazon.in"},
+		{"www.amazon.co.uk", "amazon.co.uk"},
+		{"m.snapdeal.com", "snapdeal.com"},
+		{"myntra.com", "myntra.com"},
+		{"localhost", "localhost"},
+		{"co.uk", "co.uk"},
+	}
+	for _, tt := range tests {
+		if got := registrableDomain(tt.host); got != tt.want {
+			t.Errorf("registrableDomain(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
+
+// fixedExtractor returns the same products for every page.
+type fixedExtractor []Product
+
+func (e fixedExtractor) Extract(string, string) ([]Product, error) { return e, nil }
+
+func TestRegisteredExtractorReadsItsHost(t *testing.T) {
+	registerExtractor("shop.example.com", fixedExtractor{
+		{URL: "https://shop.example.com/item/1", Title: "Kettle"},
+		{URL: "https://shop.example.com/item/2"},
+		{URL: "https://shop.example.com/item/1"},
+	})
+	t.Cleanup(func() { delete(siteExtractors, "shop.example.com") })
+	setGlobal(t, &domainRules, nil)
+	c := newTestCrawler(t)
+
+	page := `<a href="/dp/B0AAAAAAAA/">Ignored by the extractor</a>`
+	got := c.listingURLs(page, "https://shop.example.com/kitchen")
+	if want := []string{"https://shop.example.com/item/1", "https://shop.example.com/item/2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("listingURLs = %q, want %q", got, want)
+	}
+	if got := c.listingURLs(page, "https://other.example.org/kitchen"); len(got) != 1 {
+		t.Errorf("unregistered host: listingURLs = %q, want the pattern match", got)
+	}
+
+	product, ok := registeredProductDetails(page, "https://shop.example.com/item/1")
+	if !ok || product.Title != "Kettle" || product.Domain != "shop.example.com" {
+		t.Errorf("registeredProductDetails = %+v, %v; want the extractor's first product", product, ok)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// --- Per-Site Extractors ---
+// Sites that regexes and selectors cannot handle get a SiteExtractor
+// written in Go, registered from an init function in its own file:
+//
+//	func init() { registerExtractor("example.com", exampleExtractor{}) }
+//
+// On listing pages only the URLs of the returned products are used; on a
+// product page the first returned product replaces the adapter's details.
+// Like config entries, a registration for a registrable domain covers all
+// of its hosts. Hosts without one are read by their site adapter.
+type SiteExtractor interface {
+	Extract(htmlContent, pageURL string) ([]Product, error)
+}
+
+// siteExtractors is filled by init functions and only read afterwards.
+var siteExtractors = make(map[string]SiteExtractor)
+
+func registerExtractor(host string, extractor SiteExtractor) {
+	siteExtractors[strings.ToLower(host)] = extractor
+}
+
+// --- Registered Extractor for a Host ---
+// An exact host wins over its registrable domain; nil when neither is
+// registered.
+func registeredExtractor(host string) SiteExtractor {
+	host = strings.ToLower(host)
+	if extractor, ok := siteExtractors[host]; ok {
+		return extractor
+	}
+	return siteExtractors[registrableDomain(host)]
+}
+
+// --- Default Adapter-Based Extractor ---
+// Returns the product URLs the host's site adapter finds, by default the
+// product URL patterns matched against the page.
+type adapterExtractor struct {
+	adapter SiteAdapter
+	cfg     DomainConfig
+}
+
+func (e adapterExtractor) Extract(htmlContent, pageURL string) ([]Product, error) {
+	urls, err := e.adapter.ExtractURLs(htmlContent, pageURL, e.cfg)
+	if err != nil {
+		return nil, err
+	}
+	products := make([]Product, 0, len(urls))
+	for _, url := range urls {
+		products = append(products, Product{URL: url, Domain: urlHost(url)})
+	}
+	return products, nil
+}
+
+func (c *Crawler) extractorFor(host string) SiteExtractor {
+	if extractor := registeredExtractor(host); extractor != nil {
+		return extractor
+	}
+	return adapterExtractor{adapter: adapterFor(host), cfg: c.config.forHost(host)}
+}
+
+// --- Product URLs on a Listing Page ---
+// URLs from a registered extractor pass the same domain filter as pattern
+// matches and are deduplicated in order.
+func (c *Crawler) listingURLs(htmlContent, pageURL string) []string {
+	products, err := c.extractorFor(urlHost(pageURL)).Extract(htmlContent, pageURL)
+	if err != nil {
+		slog.Warn("Site extractor failed", "url", pageURL, "error", err)
+		return nil
+	}
+	seen := make(map[string]bool, len(products))
+	var urls []string
+	for _, product := range products {
+		if product.URL == "" || seen[product.URL] || !domainRules.Allowed(product.URL) {
+			continue
+		}
+		seen[product.URL] = true
+		urls = append(urls, product.URL)
+	}
+	return urls
+}
+
+// --- Product Details from a Registered Extractor ---
+// ok is false when the host has no registered extractor or it found no
+// product, so the site adapter reads the page.
+func registeredProductDetails(htmlContent, pageURL string) (product Product, ok bool) {
+	extractor := registeredExtractor(urlHost(pageURL))
+	if extractor == nil {
+		return product, false
+	}
+	products, err := extractor.Extract(htmlContent, pageURL)
+	if err != nil || len(products) == 0 {
+		slog.Warn("Site extractor found no product, using the site adapter", "url", pageURL, "error", err)
+		return product, false
+	}
+	product = products[0]
+	if product.URL == "" {
+		product.URL = canonicalURL(pageURL)
+	}
+	if product.Domain == "" {
+		product.Domain = urlHost(pageURL)
+	}
+	product.UpdatedAt = time.Now().UTC()
+	return product, true
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// --- Fetch Modes ---
+// chrome renders every page in headless Chrome. http issues a plain GET,
+// which is far cheaper for static pages. auto tries http first and falls
+// back to Chrome when the page yields nothing useful.
+const (
+	fetchModeChrome = "chrome"
+	fetchModeHTTP   = "http"
+	fetchModeAuto   = "auto"
+)
+
+// Pages larger than this are truncated when fetched over plain HTTP.
+const maxHTTPBodyBytes = 20 << 20
+
+// acceptEncoding lists the encodings decodeBody understands. Setting it
+// ourselves turns off net/http's transparent gzip handling, so deflate can
+// be offered as well.
+const acceptEncoding = "gzip, deflate"
+
+// maxRedirects matches net/http's default redirect limit.
+const maxRedirects = 10
+
+// --- Plain HTTP Retries ---
+// Timeouts, dropped connections and 5xx responses are retried up to
+// httpRetries times, after httpRetryBackoff and then twice as long each
+// time. A 429 is left to fetchPage, which waits out the host's pause.
+const (
+	httpRetries      = 2
+	httpRetryBackoff = time.Second
+)
+
+// errServerError marks a page the server answered with a 5xx status.
+var errServerError = errors.New("server error")
+
+// --- Validate -fetch-mode ---
+func parseFetchMode(mode string) (string, error) {
+	switch mode {
+	case fetchModeChrome, fetchModeHTTP, fetchModeAuto:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid -fetch-mode %q (want chrome, http or auto)", mode)
+}
+
+// --- Fetch a Page Using the Configured Mode ---
+// listing pages are scrolled when rendered, and in auto mode fall back to
+// Chrome when the plain response has fewer than -auto-min-links product
+// URLs, which suggests the listing is rendered by JavaScript. Other pages
+// only fall back when the plain request fails. Besides the HTML, the URL
+// the page was finally served from after redirects is returned. A page
+// answered with 429 is fetched again once the host's pause is over. Pages
+// robots.txt disallows fail with errRobotsDisallowed. Without an explicit
+// proxy each attempt takes one from the proxy pool. Each
+// attempt holds a load slot, taken after any pause so a paused host
+// blocks no other host.
+func (c *Crawler) fetchPage(ctx context.Context, pageURL, proxy string, listing bool) (string, string, error) {
+	host := urlHost(pageURL)
+	for attempt := 1; ; attempt++ {
+		if err := c.robots.Check(ctx, pageURL); err != nil {
+			return "", "", err
+		}
+		if err := c.throttle.Wait(ctx, host); err != nil {
+			return "", "", err
+		}
+		if err := c.pacer.Wait(ctx, host); err != nil {
+			return "", "", err
+		}
+		release, err := c.loads.Acquire(ctx, host)
+		if err != nil {
+			return "", "", err
+		}
+		attemptProxy := proxy
+		if attemptProxy == "" {
+			attemptProxy = c.proxies.Pick(host)
+		}
+		htmlContent, finalURL, err := c.fetchPageOnce(ctx, pageURL, attemptProxy, listing)
+		release()
+		if proxy == "" {
+			c.proxies.Report(attemptProxy, err)
+		}
+		if !errors.Is(err, errTooManyRequests) || attempt > tooManyRequestsRetries || ctx.Err() != nil {
+			return htmlContent, finalURL, err
+		}
+		slog.Info("Retrying page after 429", "url", pageURL, "attempt", attempt)
+	}
+}
+
+func (c *Crawler) fetchPageOnce(ctx context.Context, pageURL, proxy string, listing bool) (string, string, error) {
+	switch c.fetchMode {
+	case fetchModeHTTP:
+		return c.fetchHTTP(ctx, pageURL, proxy)
+	case fetchModeAuto:
+		htmlContent, finalURL, err := c.fetchHTTP(ctx, pageURL, proxy)
+		switch {
+		case errors.Is(err, errTooManyRequests):
+			return "", "", err
+		case err != nil:
+			slog.Debug("Plain HTTP fetch failed, rendering in Chrome", "url", pageURL, "error", err)
+		case !listing:
+			return htmlContent, finalURL, nil
+		case len(c.listingURLs(htmlContent, finalURL)) >= max(c.autoMinLinks, 1):
+			return htmlContent, finalURL, nil
+		default:
+			slog.Debug("Too few product URLs in plain HTML, rendering in Chrome", "url", pageURL, "min", c.autoMinLinks)
+		}
+	}
+	return c.loadPage(ctx, pageURL, proxy, listing)
+}
+
+// --- Fetch a Page Without a Browser ---
+// Transient failures are retried; see httpRetries.
+func (c *Crawler) fetchHTTP(ctx context.Context, pageURL, proxy string) (string, string, error) {
+	for attempt := 0; ; attempt++ {
+		htmlContent, finalURL, err := c.fetchHTTPOnce(ctx, pageURL, proxy)
+		if err == nil || attempt >= httpRetries || ctx.Err() != nil || !retryableHTTPError(err) {
+			return htmlContent, finalURL, err
+		}
+		delay := httpRetryBackoff << attempt
+		slog.Debug("Retrying plain HTTP fetch", "url", pageURL, "attempt", attempt+1, "wait", delay, "error", err)
+		sleepCtx(ctx, delay)
+	}
+}
+
+// retryableHTTPError reports 5xx responses, timeouts and connections that
+// were refused or dropped. Every *url.Error is a net.Error, so that alone
+// would also retry redirect loops, certificate failures and malformed
+// responses, which fail the same way every time.
+func retryableHTTPError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, errServerError) ||
+		errors.As(err, &netErr) && netErr.Timeout() ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED)
+}
+
+func (c *Crawler) fetchHTTPOnce(ctx context.Context, pageURL, proxy string) (string, string, error) {
+	host := urlHost(pageURL)
+	if err := c.throttle.Wait(ctx, host); err != nil {
+		return "", "", err
+	}
+	cookies, err := c.sessionCookies(ctx, host)
+	if err != nil {
+		return "", "", err
+	}
+	client, err := c.httpClient(proxy)
+	if err != nil {
+		return "", "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.config.forHost(host).crawlTimeout())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	addSessionCookies(req, cookies)
+	if dev := c.deviceFor(host); dev != nil {
+		req.Header.Set("User-Agent", dev.UserAgent)
+	} else if c.fingerprints {
+		req.Header.Set("User-Agent", fingerprintFor(host).UserAgent)
+	} else if c.stealth {
+		req.Header.Set("User-Agent", randomFingerprint().UserAgent)
+	}
+	for name, value := range c.requestHeaders(host) {
+		req.Header.Set(name, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	c.throttle.Observe(host, resp.StatusCode, resp.Header)
+	traceFrom(ctx).record(func(t *pageTrace) { t.fetch, t.status = fetchModeHTTP, resp.StatusCode })
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return "", "", errTooManyRequests
+	}
+	if resp.StatusCode >= 500 {
+		return "", "", fmt.Errorf("unexpected status %s: %w", resp.Status, errServerError)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	decoded, err := decodeBody(resp)
+	if err != nil {
+		return "", "", err
+	}
+	defer decoded.Close()
+	// The limit applies to the decoded size, so a small compressed body
+	// cannot expand without bound.
+	body, err := io.ReadAll(io.LimitReader(decoded, maxHTTPBodyBytes))
+	if err != nil {
+		return "", "", fmt.Errorf("read body: %w", err)
+	}
+	htmlContent, err := c.limitHTML(pageURL, string(body))
+	if err != nil {
+		return "", "", err
+	}
+	return htmlContent, resp.Request.URL.String(), nil
+}
+
+// --- Plain HTTP Client for a Proxy ---
+// Clients are kept per proxy so connections are reused across pages, and
+// share one cookie jar so cookies a site sets, such as its session or
+// region choice, are sent back on later requests.
+func (c *Crawler) httpClient(proxy string) (*http.Client, error) {
+	c.httpMu.Lock()
+	defer c.httpMu.Unlock()
+	if client, ok := c.httpClients[proxy]; ok {
+		return client, nil
+	}
+	if c.httpClients == nil {
+		c.httpClients = make(map[string]*http.Client)
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return nil, err
+		}
+		c.cookieJar = jar
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy != "" {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %q: %w", proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	client := &http.Client{Transport: transport, Jar: c.cookieJar, CheckRedirect: checkRedirect}
+	c.httpClients[proxy] = client
+	return client, nil
+}
+
+// --- Decode a Compressed Response Body ---
+// Handles gzip and deflate per Content-Encoding. Deflate is meant to be
+// zlib-wrapped, but some servers send raw DEFLATE, so that is accepted too.
+// Other encodings are an error rather than garbage fed to extraction.
+func decodeBody(resp *http.Response) (io.ReadCloser, error) {
+	switch encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+		return io.NopCloser(resp.Body), nil
+	case "gzip", "x-gzip":
+		reader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("decode gzip body: %w", err)
+		}
+		return reader, nil
+	case "deflate":
+		buffered := bufio.NewReader(resp.Body)
+		// A zlib stream starts with a header whose first byte has
+		// compression method 8 in its low nibble.
+		if header, err := buffered.Peek(2); err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			reader, err := zlib.NewReader(buffered)
+			if err != nil {
+				return nil, fmt.Errorf("decode deflate body: %w", err)
+			}
+			return reader, nil
+		}
+		return flate.NewReader(buffered), nil
+	default:
+		return nil, fmt.Errorf("unsupported Content-Encoding %q", encoding)
+	}
+}
+
+// --- Follow Redirects Unless They Loop ---
+// A chain that returns to a URL it already visited is a loop; otherwise
+// the usual limit of maxRedirects hops applies.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	for _, prev := range via {
+		if prev.URL.String() == req.URL.String() {
+			return fmt.Errorf("%w: %s", errRedirectLoop, req.URL)
+		}
+	}
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// redirectSite serves a 301 then 302 chain ending on a listing page, a
+// redirect loop and a product page reached through a redirect.
+func redirectSite(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	loopRequests := new(atomic.Int32)
+	mux := http.NewServeMux()
+	mux.Handle("/old-phones", http.RedirectHandler("/phones-moved", http.StatusMovedPermanently))
+	mux.Handle("/phones-moved", http.RedirectHandler("/phones", http.StatusFound))
+	mux.HandleFunc("/phones", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<a href="/dp/B0AAAAAAAA/">Phone</a><a href="/dp/B0BBBBBBBB/">Phone</a>`)
+	})
+	mux.HandleFunc("/loop-a", func(w http.ResponseWriter, r *http.Request) {
+		loopRequests.Add(1)
+		http.Redirect(w, r, "/loop-b", http.StatusFound)
+	})
+	mux.Handle("/loop-b", http.RedirectHandler("/loop-a", http.StatusFound))
+	mux.Handle("/dp/B0OLDOLDOL/", http.RedirectHandler("/dp/B0AAAAAAAA/", http.StatusMovedPermanently))
+	mux.HandleFunc("/dp/B0AAAAAAAA/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><title>Phone</title></head><body><h1>Phone</h1></body></html>`)
+	})
+	site := httptest.NewServer(mux)
+	t.Cleanup(site.Close)
+	return site, loopRequests
+}
+
+func TestFetchHTTPFollowsRedirectChain(t *testing.T) {
+	site, loopRequests := redirectSite(t)
+	c := newTestCrawler(t)
+
+	htmlContent, finalURL, err := c.fetchHTTP(context.Background(), site.URL+"/old-phones", "")
+	if err != nil {
+		t.Fatalf("fetchHTTP: %v", err)
+	}
+	if finalURL != site.URL+"/phones" {
+		t.Errorf("final URL = %q, want the end of the chain", finalURL)
+	}
+	if len(extractProductURLs(htmlContent, finalURL, DomainConfig{})) != 2 {
+		t.Errorf("html = %q, want the listing page", htmlContent)
+	}
+
+	_, _, err = c.fetchHTTP(context.Background(), site.URL+"/loop-a", "")
+	if !errors.Is(err, errRedirectLoop) {
+		t.Fatalf("redirect loop: err = %v, want errRedirectLoop", err)
+	}
+	if loopRequests.Load() != 1 {
+		t.Errorf("loop entered %d times, want 1 with no retries", loopRequests.Load())
+	}
+}
+
+func TestRedirectedPagesRecordFinalURL(t *testing.T) {
+	site, _ := redirectSite(t)
+	c := newTestCrawler(t)
+	requested := site.URL + "/old-phones"
+
+	results := crawlPage(t, c, requested)
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+	if results[0].SourceURL != site.URL+"/phones" || results[0].RequestedURL != requested {
+		t.Errorf("source %q, requested %q; want the final and the requested URL", results[0].SourceURL, results[0].RequestedURL)
+	}
+	for _, url := range []string{requested, site.URL + "/phones"} {
+		if !c.visited.IsVisited(context.Background(), url) {
+			t.Errorf("%s not marked visited", url)
+		}
+	}
+	if again := crawlPage(t, newTestCrawlerSharing(t, c), site.URL+"/phones-moved"); len(again) != 0 {
+		t.Errorf("redirect target crawled again under another URL: %+v", again)
+	}
+
+	products := c.fetchProductDetails(context.Background(), []string{site.URL + "/dp/B0OLDOLDOL/"})
+	want := []string{canonicalURL(site.URL + "/dp/B0AAAAAAAA/"), site.URL + "/dp/B0OLDOLDOL/"}
+	if len(products) != 1 || !reflect.DeepEqual([]string{products[0].URL, products[0].RequestedURL}, want) {
+		t.Errorf("products = %+v, want URL and RequestedURL %v", products, want)
+	}
+}
+
+// newTestCrawlerSharing returns a test crawler sharing c's visited set, as
+// a second worker would.
+func newTestCrawlerSharing(t *testing.T, c *Crawler) *Crawler {
+	t.Helper()
+	other := newTestCrawler(t)
+	other.visited = c.visited
+	return other
+}
+
+func TestRetryableHTTPError(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hang-up", func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	})
+	mux.HandleFunc("/garbled", func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Write([]byte("not an HTTP response\r\n\r\n"))
+			conn.Close()
+		}
+	})
+	mux.Handle("/loop", http.RedirectHandler("/loop", http.StatusFound))
+	site := httptest.NewServer(mux)
+	defer site.Close()
+	tlsSite := httptest.NewTLSServer(mux)
+	defer tlsSite.Close()
+	closed := httptest.NewServer(mux)
+	closed.Close()
+
+	get := func(url string, timeout time.Duration) error {
+		resp, err := (&http.Client{Timeout: timeout}).Get(url)
+		if err == nil {
+			resp.Body.Close()
+			return errors.New("request succeeded")
+		}
+		return err
+	}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"server error", fmt.Errorf("%w: 503", errServerError), true},
+		{"connection dropped", get(site.URL+"/hang-up", 5*time.Second), true},
+		{"timeout", get(site.URL+"/slow", 50*time.Millisecond), true},
+		{"connection refused", get(closed.URL, 5*time.Second), true},
+		{"untrusted certificate", get(tlsSite.URL, 5*time.Second), false},
+		{"malformed response", get(site.URL+"/garbled", 5*time.Second), false},
+		{"too many redirects", get(site.URL+"/loop", 5*time.Second), false},
+		{"redirect loop", fmt.Errorf("%w: %s", errRedirectLoop, site.URL), false},
+	}
+	for _, tt := range tests {
+		if got := retryableHTTPError(tt.err); got != tt.want {
+			t.Errorf("%s: retryableHTTPError(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"hash/fnv"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/chromedp"
+)
+
+// --- Browser Fingerprint Profiles ---
+// Each profile is a self-consistent identity: the user agent, platform,
+// languages, viewport and timezone all describe the same plausible machine.
+// Mixing attributes from different profiles is itself a bot signal, so a
+// domain is always served exactly one profile.
+type fingerprintProfile struct {
+	Name           string
+	UserAgent      string
+	Platform       string
+	AcceptLanguage string
+	Locale         string
+	Width, Height  int64
+	Timezone       string
+}
+
+var fingerprintProfiles = []fingerprintProfile{
+	{
+		Name:           "windows-chrome-us",
+		UserAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Safari/537.36",
+		Platform:       "Win32",
+		AcceptLanguage: "en-US,en;q=0.9",
+		Locale:         "en-US",
+		Width:          1920,
+		Height:         1080,
+		Timezone:       "America/New_York",
+	},
+	{
+		Name:           "mac-chrome-us",
+		UserAgent:      "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Safari/537.36",
+		Platform:       "MacIntel",
+		AcceptLanguage: "en-US,en;q=0.9",
+		Locale:         "en-US",
+		Width:          1440,
+		Height:         900,
+		Timezone:       "America/Los_Angeles",
+	},
+	{
+		Name:           "windows-chrome-in",
+		UserAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Safari/537.36",
+		Platform:       "Win32",
+		AcceptLanguage: "en-IN,en-GB;q=0.9,en;q=0.8,hi;q=0.7",
+		Locale:         "en-IN",
+		Width:          1366,
+		Height:         768,
+		Timezone:       "Asia/Kolkata",
+	},
+	{
+		Name:           "linux-chrome-gb",
+		UserAgent:      "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/133.0.0.0 Safari/537.36",
+		Platform:       "Linux x86_64",
+		AcceptLanguage: "en-GB,en;q=0.9",
+		Locale:         "en-GB",
+		Width:          1600,
+		Height:         900,
+		Timezone:       "Europe/London",
+	},
+}
+
+// --- Profile Assigned to a Host ---
+// Hosts sharing a registrable domain hash to the same profile, so a site
+// sees one identity for the whole session and across runs.
+func fingerprintFor(host string) fingerprintProfile {
+	h := fnv.New32a()
+	h.Write([]byte(registrableDomain(host)))
+	return fingerprintProfiles[h.Sum32()%uint32(len(fingerprintProfiles))]
+}
+
+// --- Browser Launch Options for a Profile ---
+func (p fingerprintProfile) allocatorOptions() []chromedp.ExecAllocatorOption {
+	return []chromedp.ExecAllocatorOption{
+		chromedp.UserAgent(p.UserAgent),
+		chromedp.WindowSize(int(p.Width), int(p.Height)),
+	}
+}
+
+// --- Emulation Actions for a Profile ---
+// Run before navigation so the first request already carries the profile.
+func (p fingerprintProfile) emulate() chromedp.Tasks {
+	return chromedp.Tasks{
+		emulation.SetUserAgentOverride(p.UserAgent).
+			WithAcceptLanguage(p.AcceptLanguage).
+			WithPlatform(p.Platform),
+		emulation.SetLocaleOverride().WithLocale(p.Locale),
+		emulation.SetTimezoneOverride(p.Timezone),
+		emulation.SetDeviceMetricsOverride(p.Width, p.Height, 1, false),
+	}
+}
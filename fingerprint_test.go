@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/network"
+)
+
+func TestFingerprintForIsStablePerDomain(t *testing.T) {
+	for _, hosts := range [][]string{
+		{"www.amazon.in", "m.amazon.in", "AMAZON.IN"},
+		{"www.myntra.com", "myntra.com"},
+	} {
+		want := fingerprintFor(hosts[0])
+		for _, host := range hosts {
+			for range 3 {
+				if got := fingerprintFor(host); got != want {
+					t.Errorf("fingerprintFor(%q) = %s, want %s like %s", host, got.Name, want.Name, hosts[0])
+				}
+			}
+		}
+	}
+}
+
+func TestFingerprintProfilesAreSelfConsistent(t *testing.T) {
+	platformUA := map[string]string{"Win32": "Windows NT", "MacIntel": "Macintosh", "Linux x86_64": "X11; Linux"}
+	for _, profile := range fingerprintProfiles {
+		if want, ok := platformUA[profile.Platform]; !ok || !strings.Contains(profile.UserAgent, want) {
+			t.Errorf("%s: platform %q does not match user agent %q", profile.Name, profile.Platform, profile.UserAgent)
+		}
+		if !strings.HasPrefix(profile.AcceptLanguage, profile.Locale+",") {
+			t.Errorf("%s: Accept-Language %q does not lead with locale %s", profile.Name, profile.AcceptLanguage, profile.Locale)
+		}
+	}
+}
+
+// The session a browser tab is set up with carries every attribute of the
+// host's profile and nothing from another.
+func TestBrowserSessionMatchesFingerprintProfile(t *testing.T) {
+	const host = "www.snapdeal.com"
+	c := newTestCrawler(t)
+	c.fingerprints = true
+	profile := fingerprintFor(host)
+
+	_, setup, cancel := c.newBrowser(context.Background(), host, "")
+	defer cancel()
+
+	var seen []string
+	for _, action := range setup {
+		switch a := action.(type) {
+		case *emulation.SetUserAgentOverrideParams:
+			seen = append(seen, "user agent")
+			if a.UserAgent != profile.UserAgent || a.Platform != profile.Platform || a.AcceptLanguage != profile.AcceptLanguage {
+				t.Errorf("user agent override = %q/%q/%q, want profile %s", a.UserAgent, a.Platform, a.AcceptLanguage, profile.Name)
+			}
+		case *emulation.SetLocaleOverrideParams:
+			seen = append(seen, "locale")
+			if a.Locale != profile.Locale {
+				t.Errorf("locale = %q, want %q", a.Locale, profile.Locale)
+			}
+		case *emulation.SetTimezoneOverrideParams:
+			seen = append(seen, "timezone")
+			if a.TimezoneID != profile.Timezone {
+				t.Errorf("timezone = %q, want %q", a.TimezoneID, profile.Timezone)
+			}
+		case *emulation.SetDeviceMetricsOverrideParams:
+			seen = append(seen, "viewport")
+			if a.Width != profile.Width || a.Height != profile.Height {
+				t.Errorf("viewport = %dx%d, want %dx%d", a.Width, a.Height, profile.Width, profile.Height)
+			}
+		case *network.SetExtraHTTPHeadersParams:
+			seen = append(seen, "headers")
+			if got := a.Headers["Accept-Language"]; got != profile.AcceptLanguage {
+				t.Errorf("Accept-Language header = %v, want %q", got, profile.AcceptLanguage)
+			}
+		}
+	}
+	if want := "user agent, locale, timezone, viewport, headers"; strings.Join(seen, ", ") != want {
+		t.Errorf("session set up %s, want %s", strings.Join(seen, ", "), want)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// --- Recursive Crawl Frontier ---
+// With -depth N, category and listing links found on a listing page are
+// crawled as listing pages themselves, breadth first, down to N links
+// from the seed; products buried behind category trees are reached that
+// way. Depth 0 crawls the seed pages only. -max-pages bounds how many
+// pages of a domain the recursion visits.
+//
+// The frontier lives in the Redis work queue (see queue.go): the shared
+// one with -queue, otherwise a private queue for this run. Without Redis
+// it is held in process. Links followed from a page keep the seed of the
+// page, so URLs found deep in a category tree are attributed to it.
+
+// frontierQueuePrefix names the private Redis frontier of a run.
+const frontierQueuePrefix = "frontier:"
+
+// maxFollowLinks caps the links followed from one page, so a page
+// listing every facet of a category cannot flood the frontier.
+const maxFollowLinks = 50
+
+// defaultCategoryPattern matches the paths of typical category, search
+// and paginated listing URLs.
+var defaultCategoryPattern = regexp.MustCompile(`(?i)/(c|b|s|cat|category|categories|collections?|browse|departments?|search)(/|\?|$)|[?&]page=\d+`)
+
+// --- Category Links on a Listing Page ---
+// Returns the page's links that match the domain's category patterns,
+// stay on the page's registrable domain, pass the domain filter and are
+// not product URLs, in page order and without fragments.
+func extractCategoryLinks(htmlContent, pageURL string, cfg DomainConfig) []string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlContent))
+	if err != nil {
+		slog.Warn("Failed to parse page for category links", "url", pageURL, "error", err)
+		return nil
+	}
+	site := registrableDomain(urlHost(pageURL))
+	seen := map[string]bool{pageURL: true}
+	var links []string
+	doc.Find("a[href]").EachWithBreak(func(_ int, a *goquery.Selection) bool {
+		parsed, err := url.Parse(resolveURL(pageURL, strings.TrimSpace(a.AttrOr("href", ""))))
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return true
+		}
+		parsed.Fragment = ""
+		link := parsed.String()
+		if seen[link] || registrableDomain(strings.ToLower(parsed.Hostname())) != site {
+			return true
+		}
+		seen[link] = true
+		if !cfg.isCategoryLink(parsed.RequestURI()) || isProductLink(link, cfg) || !domainRules.Allowed(link) {
+			return true
+		}
+		if reason := nonProductReason(link, cfg); reason != "" && reason != "short slug" {
+			return true
+		}
+		links = append(links, link)
+		return len(links) < maxFollowLinks
+	})
+	return links
+}
+
+// --- Whether a Link Is a Product Page ---
+func isProductLink(link string, cfg DomainConfig) bool {
+	for _, pattern := range cfg.productPatterns() {
+		if pattern.MatchString(link) {
+			return true
+		}
+	}
+	return false
+}
+
+// --- Follow a Page's Category Links ---
+// Children of job go onto the Redis frontier when there is one, and are
+// otherwise crawled right away by this process, reporting to resultChan.
+func (c *Crawler) follow(ctx context.Context, job queueJob, htmlContent, pageURL string, resultChan chan<- CrawlResult, wg *sync.WaitGroup) {
+	if job.Depth >= c.maxDepth || ctx.Err() != nil {
+		return
+	}
+	links := extractCategoryLinks(htmlContent, pageURL, c.config.forHost(urlHost(pageURL)))
+	if len(links) == 0 {
+		return
+	}
+	slog.Debug("Following category links", "url", pageURL, "links", len(links), "depth", job.Depth+1)
+
+	children := make([]queueJob, 0, len(links))
+	for _, link := range links {
+		children = append(children, queueJob{Kind: jobListing, URL: link, Seed: job.Seed, Depth: job.Depth + 1})
+	}
+	if c.queue != nil {
+		if err := c.queue.Push(ctx, children...); err != nil {
+			slog.Error("Failed to enqueue category links", "url", pageURL, "error", err)
+		}
+		return
+	}
+	for _, child := range children {
+		wg.Add(1)
+		go c.scrapeWebsite(ctx, child, resultChan, wg)
+	}
+}
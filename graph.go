@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// --- Link Graph Export ---
+// -export-graph writes the crawl as a graph of pages and products: an edge
+// runs from each crawled page to every product URL found on it, and from a
+// seed to the page it redirected to. It is built from the in-memory results
+// only. A .graphml path gets GraphML (Gephi, yEd); anything else gets
+// JSON {"nodes": [...], "edges": [...]}.
+const (
+	nodePage    = "page"
+	nodeProduct = "product"
+
+	edgeLinks    = "links"
+	edgeRedirect = "redirect"
+)
+
+type graphNode struct {
+	ID     string `json:"id"`
+	Kind   string `json:"kind"`
+	Domain string `json:"domain"`
+}
+
+type graphEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Kind   string `json:"kind"`
+}
+
+type linkGraph struct {
+	Nodes []graphNode `json:"nodes"`
+	Edges []graphEdge `json:"edges"`
+}
+
+// --- Build the Graph from Crawl Results ---
+// Nodes are deduplicated by canonical URL; a URL that is both a crawled
+// page and a product stays a page. Nodes and edges are sorted so repeated
+// exports of the same crawl are identical.
+func buildLinkGraph(results []CrawlResult) linkGraph {
+	nodes := make(map[string]graphNode)
+	edges := make(map[graphEdge]bool)
+	addNode := func(rawURL, kind string) string {
+		id := canonicalURL(rawURL)
+		if existing, ok := nodes[id]; !ok || existing.Kind == nodeProduct {
+			nodes[id] = graphNode{ID: id, Kind: kind, Domain: urlHost(id)}
+		}
+		return id
+	}
+
+	for _, res := range results {
+		page := res.SourceURL
+		if page == "" {
+			page = res.Domain
+		}
+		pageID := addNode(page, nodePage)
+		if res.RequestedURL != "" {
+			edges[graphEdge{Source: addNode(res.RequestedURL, nodePage), Target: pageID, Kind: edgeRedirect}] = true
+		}
+		for _, productURL := range res.URLs {
+			productID := canonicalURL(productURL)
+			if _, ok := nodes[productID]; !ok {
+				addNode(productURL, nodeProduct)
+			}
+			edges[graphEdge{Source: pageID, Target: productID, Kind: edgeLinks}] = true
+		}
+	}
+
+	graph := linkGraph{Nodes: make([]graphNode, 0, len(nodes)), Edges: make([]graphEdge, 0, len(edges))}
+	for _, node := range nodes {
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	for edge := range edges {
+		graph.Edges = append(graph.Edges, edge)
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool { return graph.Nodes[i].ID < graph.Nodes[j].ID })
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		a, b := graph.Edges[i], graph.Edges[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		return a.Target < b.Target
+	})
+	return graph
+}
+
+// --- Canonical Form of a URL ---
+// Lowercases scheme and host, drops default ports, fragments and trailing
+// slashes, and sorts query parameters. Unparseable URLs are kept as is.
+func canonicalURL(rawURL string) string {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || parsed.Host == "" {
+		return rawURL
+	}
+	normalizeURL(parsed)
+	if len(parsed.Path) > 1 {
+		parsed.Path = strings.TrimRight(parsed.Path, "/")
+	}
+	parsed.RawQuery = parsed.Query().Encode()
+	return parsed.String()
+}
+
+// --- Write the Graph ---
+func writeLinkGraph(results []CrawlResult, path string, overwrite bool) error {
+	graph := buildLinkGraph(results)
+	if !strings.EqualFold(filepath.Ext(path), ".graphml") {
+		return writeJSONFile(path, graph, overwrite)
+	}
+
+	data, err := graph.GraphML()
+	if err != nil {
+		return err
+	}
+	return writeOutputFile(path, data, overwrite)
+}
+
+// --- GraphML Encoding ---
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLDocument struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   struct {
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLNode `xml:"node"`
+		Edges       []graphMLEdge `xml:"edge"`
+	} `xml:"graph"`
+}
+
+func (g linkGraph) GraphML() ([]byte, error) {
+	doc := graphMLDocument{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "kind", For: "node", Name: "kind", Type: "string"},
+			{ID: "domain", For: "node", Name: "domain", Type: "string"},
+			{ID: "edgekind", For: "edge", Name: "kind", Type: "string"},
+		},
+	}
+	doc.Graph.EdgeDefault = "directed"
+	for _, node := range g.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{ID: node.ID, Data: []graphMLData{
+			{Key: "kind", Value: node.Kind},
+			{Key: "domain", Value: node.Domain},
+		}})
+	}
+	for _, edge := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{Source: edge.Source, Target: edge.Target, Data: []graphMLData{
+			{Key: "edgekind", Value: edge.Kind},
+		}})
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encode GraphML: %w", err)
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// --- HTML Size Guard ---
+// Listings scrolled or paginated many times can capture enormous HTML,
+// and with many workers the copies add up. -max-html-bytes bounds the
+// HTML kept per page; -html-overflow decides whether a larger page is
+// truncated (at the end of its last complete tag, so no partial URL is
+// matched) or skipped.
+const (
+	overflowTruncate = "truncate"
+	overflowSkip     = "skip"
+)
+
+// errHTMLTooLarge marks a page skipped by the size guard.
+var errHTMLTooLarge = errors.New("HTML exceeds -max-html-bytes")
+
+// --- Validate -html-overflow ---
+func parseHTMLOverflow(policy string) (string, error) {
+	switch policy {
+	case overflowTruncate, overflowSkip:
+		return policy, nil
+	}
+	return "", fmt.Errorf("invalid -html-overflow %q (want truncate or skip)", policy)
+}
+
+// --- Apply the Size Guard to a Page ---
+func (c *Crawler) limitHTML(pageURL, htmlContent string) (string, error) {
+	if c.maxHTMLBytes <= 0 || len(htmlContent) <= c.maxHTMLBytes {
+		return htmlContent, nil
+	}
+	if c.htmlOverflow == overflowSkip {
+		return "", fmt.Errorf("%w: %d bytes", errHTMLTooLarge, len(htmlContent))
+	}
+	truncated := truncateHTML(htmlContent, c.maxHTMLBytes)
+	slog.Warn("HTML exceeds -max-html-bytes, truncated", "url", pageURL, "bytes", len(htmlContent), "kept", len(truncated))
+	return truncated, nil
+}
+
+// --- Truncate at an Element Boundary ---
+// Cuts after the last ">" within limit bytes.
+func truncateHTML(htmlContent string, limit int) string {
+	cut := strings.LastIndexByte(htmlContent[:limit], '>')
+	return htmlContent[:cut+1]
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// --- Product Image Extraction ---
+// Lazy-loaded images keep a placeholder in src until scrolled into view,
+// so the real URL is read from the lazy-loading attributes first. Without
+// an imageSelector the page's og:image is used.
+
+// Attributes lazy-loading libraries keep the real image URL in, in order
+// of preference.
+var lazyImageAttrs = []string{"data-src", "data-original", "data-lazy-src"}
+
+// --- Extract the Product Image URL ---
+func extractImageURL(doc *goquery.Document, cfg DomainConfig, pageURL string) string {
+	if cfg.ImageSelector == "" {
+		content, _ := doc.Find(`meta[property="og:image"]`).First().Attr("content")
+		return resolveURL(pageURL, content)
+	}
+	img := doc.Find(cfg.ImageSelector).First()
+	if img.Length() == 0 {
+		return ""
+	}
+	return resolveURL(pageURL, imageSource(img))
+}
+
+// --- Real Source of an <img> ---
+func imageSource(img *goquery.Selection) string {
+	for _, attr := range lazyImageAttrs {
+		if src := strings.TrimSpace(img.AttrOr(attr, "")); src != "" {
+			return src
+		}
+	}
+	for _, attr := range []string{"data-srcset", "srcset"} {
+		if src := largestSrcsetCandidate(img.AttrOr(attr, "")); src != "" {
+			return src
+		}
+	}
+	// A data: URI in src is always a placeholder.
+	if src := strings.TrimSpace(img.AttrOr("src", "")); !strings.HasPrefix(src, "data:") {
+		return src
+	}
+	return ""
+}
+
+// --- Largest Candidate of a srcset ---
+// Candidates look like "a.jpg 320w, b.jpg 640w" or "a.jpg 1x, b.jpg 2x";
+// the one with the highest descriptor wins.
+func largestSrcsetCandidate(srcset string) string {
+	best, bestSize := "", -1.0
+	for _, candidate := range strings.Split(srcset, ",") {
+		fields := strings.Fields(candidate)
+		if len(fields) == 0 {
+			continue
+		}
+		size := 1.0
+		if len(fields) > 1 {
+			descriptor := strings.TrimRight(fields[1], "wx")
+			if parsed, err := strconv.ParseFloat(descriptor, 64); err == nil {
+				size = parsed
+			}
+		}
+		if size > bestSize {
+			best, bestSize = fields[0], size
+		}
+	}
+	return best
+}
+
+// --- Resolve a Possibly Relative URL Against the Page ---
+func resolveURL(pageURL, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return ref
+	}
+	resolved, err := base.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return resolved.String()
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+)
+
+// --- Per-Domain Crawl Caps ---
+// -max-pages and -max-urls bound how much of one site a run samples; the
+// maxPages and maxURLs config entries override them per domain, and 0
+// means unlimited. Pages count listing pages, pagination clicks and
+// product detail pages; URLs count new product URLs kept. Counts are per
+// registrable domain, so www.example.com and m.example.com share a budget.
+// A domain that hits its cap stops being crawled while others continue.
+type siteLimits struct {
+	mu       sync.Mutex
+	config   *Config
+	maxPages int
+	maxURLs  int
+	pages    map[string]int
+	urls     map[string]int
+	capped   map[string]bool // domains whose cap has been logged
+}
+
+func newSiteLimits(config *Config, maxPages, maxURLs int) *siteLimits {
+	return &siteLimits{
+		config:   config,
+		maxPages: maxPages,
+		maxURLs:  maxURLs,
+		pages:    make(map[string]int),
+		urls:     make(map[string]int),
+		capped:   make(map[string]bool),
+	}
+}
+
+// --- Reserve a Page ---
+// Reports whether another page of host may be crawled. A nil
+// siteLimits allows everything.
+func (l *siteLimits) TakePage(host string) bool {
+	if l == nil {
+		return true
+	}
+	return l.take(l.pages, host, 1, l.pageLimit(host), "pages") == 1
+}
+
+// --- Give Back a Reserved Page ---
+// For a page taken with TakePage that then failed to load or repeated an
+// earlier one, so it does not count against host's cap.
+func (l *siteLimits) ReturnPage(host string) {
+	if l == nil || l.pageLimit(host) <= 0 {
+		return
+	}
+	domain := registrableDomain(host)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.pages[domain] > 0 {
+		l.pages[domain]--
+	}
+}
+
+// --- Page Budget Used Up ---
+// Reports, without reserving anything, whether host has no page left.
+func (l *siteLimits) PagesExhausted(host string) bool {
+	if l == nil {
+		return false
+	}
+	limit := l.pageLimit(host)
+	if limit <= 0 {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.pages[registrableDomain(host)] >= limit
+}
+
+func (l *siteLimits) pageLimit(host string) int {
+	if configured := l.config.forHost(host).MaxPages; configured != 0 {
+		return configured
+	}
+	return l.maxPages
+}
+
+// --- Reserve Product URLs ---
+// Returns how many of n new product URLs of host may be kept.
+func (l *siteLimits) TakeURLs(host string, n int) int {
+	if l == nil {
+		return n
+	}
+	limit := l.maxURLs
+	if configured := l.config.forHost(host).MaxURLs; configured != 0 {
+		limit = configured
+	}
+	return l.take(l.urls, host, n, limit, "urls")
+}
+
+func (l *siteLimits) take(counts map[string]int, host string, n, limit int, kind string) int {
+	if limit <= 0 || n == 0 {
+		return n
+	}
+	domain := registrableDomain(host)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	granted := min(n, limit-counts[domain])
+	if granted < n && !l.capped[domain+kind] {
+		l.capped[domain+kind] = true
+		slog.Warn("Domain cap reached, skipping the rest of the domain", "domain", domain, "cap", kind, "limit", limit)
+	}
+	if granted <= 0 {
+		return 0
+	}
+	counts[domain] += granted
+	return granted
+}
@@ -2,283 +2,616 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
-	"math/rand"
+	"io"
+	"log/slog"
 	"os"
-	"regexp"
+	"os/signal"
 	//"strconv"
-	"sync"
+	"syscall"
 	"time"
 
-	"github.com/chromedp/chromedp"
 	"github.com/joho/godotenv"
 	"github.com/redis/go-redis/v9"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
 )
 
 // --- Constants ---
 const (
-	redisExpiry     = 24 * time.Hour
-	crawlTimeout    = 30 * time.Second
-	scrollAttempts  = 5
-	pageLoadDelay   = 2 * time.Second
+	redisExpiry         = 24 * time.Hour
+	crawlTimeout        = 30 * time.Second // per page; the config file can override it
+	waitSelectorTimeout = 15 * time.Second
+	scrollAttempts      = 5 // the config file can override it
+	maxScrollSteps      = 5 // per attempt, bounds total scroll on endless pages
+	pageLoadDelay       = 2 * time.Second
+	maxPaginationPages  = 5 // listing pages visited per seed when paginating
+
+	// Bloom filter sizing for -bloom: about 1.2 MB for a million URLs at a
+	// 1% false-positive rate. False positives only cost an extra Redis call.
+	bloomCapacity      = 1_000_000
+	bloomFalsePositive = 0.01
+
+	scrollStepPauseMin = 100 * time.Millisecond
+	scrollStepPauseMax = 300 * time.Millisecond
 )
 
-// --- Global Variables ---
+// --- Command-Line Flags ---
 var (
-	db          *gorm.DB
-	redisClient *redis.Client
+	auditLogPath     = flag.String("audit-log", "", "Append crawl lifecycle events as JSON lines to this file")
+	allowDomains     = flag.String("allow-domains", "", "Comma-separated domains (or @file) discovered URLs may point to, added to the config's allowDomains; defaults to the seed hosts")
+	visitedNamespace = flag.String("visited-namespace", "visited", "Redis key prefix of the visited page marks; crawls with different namespaces do not share them")
+	bloomVisited     = flag.Bool("bloom", false, "Keep an in-process Bloom filter of visited URLs so most unvisited URLs skip the Redis lookup")
+	bloomPath        = flag.String("bloom-file", "", "Load the -bloom filter from this file at startup and save it back at exit")
+	concurrency      = flag.Int("concurrency", 0, "Most pages loaded at once across all domains (0 is unlimited)")
+	hostConcurrency  = flag.Int("per-domain-concurrency", 1, "Most pages of a single host loaded at once")
+	browserCount     = flag.Int("browsers", 0, "Keep this many Chrome instances (per proxy) running and open each page as a tab in one of them (0 launches a browser per page)")
+	cpuProfilePath   = flag.String("cpuprofile", "", "Write a CPU profile of the crawl to this file")
+	memProfilePath   = flag.String("memprofile", "", "Write a heap profile, taken when the crawl finishes, to this file")
+	configPath       = flag.String("config", "", "Path of the JSON or YAML (.yaml/.yml) crawl config with seeds, output path and per-domain settings")
+	deviceName       = flag.String("device", "", "Emulate this mobile device (e.g. \"iPhone 11\", \"Pixel 5\") in Chrome; per-domain config can override it")
+	domainsFromDB    = flag.Bool("domains-from-db", false, "Re-crawl the domains already in storage instead of the given or built-in seeds")
+	dumpEmptyDir     = flag.String("dump-empty", "", "Save the HTML of listing pages that yield no product URLs into this directory")
+	dryRun           = flag.Bool("dry-run", false, "Crawl and extract normally without connecting to the database or Redis")
+	graphPath        = flag.String("export-graph", "", "Write the page->product link graph to this file: GraphML for .graphml, otherwise JSON {nodes, edges}")
+	fingerprints     = flag.Bool("fingerprints", false, "Present one consistent browser fingerprint profile (UA, platform, languages, viewport, timezone) per domain")
+	stealth          = flag.Bool("stealth", false, "Hide headless Chrome: random realistic user agents (fixed per domain with -fingerprints), navigator.webdriver masking and other evasions")
+	fetchMode        = flag.String("fetch-mode", fetchModeChrome, "How pages are fetched: chrome, http (plain GET) or auto (http, falling back to chrome)")
+	autoMinLinks     = flag.Int("auto-min-links", 1, "With -fetch-mode auto, render a listing in Chrome when its plain HTML has fewer product URLs than this")
+	blockResources   = flag.Bool("block-resources", false, "Abort image, font and media requests and requests to analytics/ad hosts while rendering pages")
+	blockDomainList  = flag.String("block-domains", "", "Comma-separated extra hosts whose requests -block-resources aborts")
+	crawlDepth       = flag.Int("depth", 0, "Follow category links from each seed this many levels deep (0 crawls the seed pages only)")
+	denyDomains      = flag.String("deny-domains", "", "Comma-separated domains (or @file) discovered URLs must never point to, added to the config's denyDomains; overrides -allow-domains")
+	maxRuntime       = flag.Duration("max-runtime", 0, "Stop the whole crawl after this long and save partial results (0 disables)")
+	maxPages         = flag.Int("max-pages", 0, "Stop crawling a domain after this many pages, counting listings, pagination and product pages (0 is unlimited)")
+	maxURLs          = flag.Int("max-urls", 0, "Stop crawling a domain after keeping this many new product URLs from it (0 is unlimited)")
+	maxDuration      = flag.Duration("max-duration", 0, "Alias of -max-runtime; the shorter wins when both are set")
+	ignoreRobots     = flag.Bool("ignore-robots", false, "Fetch pages even when the site's robots.txt disallows them, and ignore its Crawl-delay")
+	kafkaBrokers     = flag.String("kafka-brokers", "", "Comma-separated Kafka brokers to publish newly found product URLs to (overrides KAFKA_BROKERS)")
+	kafkaTopic       = flag.String("kafka-topic", "", "Kafka topic for newly found product URLs (overrides KAFKA_TOPIC)")
+	maxHTMLBytes     = flag.Int("max-html-bytes", 0, "Largest captured HTML kept per page, in bytes (0 is unlimited); see -html-overflow")
+	htmlOverflow     = flag.String("html-overflow", overflowTruncate, "What to do with a page over -max-html-bytes: truncate it at a tag boundary or skip it")
+	logLevel         = flag.String("log-level", "info", "Log level: debug, info, warn or error")
+	logFormat        = flag.String("log-format", "text", "Log format: text or json")
+	sortOutput       = flag.Bool("sort", false, "Sort domains and URLs in the JSON output")
+	newURLsPath      = flag.String("new-urls", "", "Write a JSON map of each host to the product URLs that were not in storage before this run (e.g. new_urls.json)")
+	outputPath       = flag.String("output", "output.json", "Path of the JSON results file")
+	dupesReportPath  = flag.String("report-dupes", "", "Write clusters of product URLs believed to be the same product (by GTIN, else brand and title) to this JSON file; needs product details")
+	seedReportPath   = flag.String("seed-report", "", "Write a JSON map of each product URL to the seeds that surfaced it")
+	outputDir        = flag.String("output-dir", "", "Write one <host>.json per domain into this directory instead of a single -output file")
+	s3Bucket         = flag.String("s3-bucket", "", "Also upload the JSON results to this S3 bucket (credentials from the AWS environment/IAM)")
+	s3Prefix         = flag.String("s3-prefix", "", "Key prefix for -s3-bucket uploads; objects go to <prefix>/<run-id>-<timestamp>/<file>")
+	s3Endpoint       = flag.String("s3-endpoint", "", "Endpoint URL of an S3-compatible store (MinIO, R2, ...) for -s3-bucket")
+	s3SkipLocal      = flag.Bool("s3-skip-local", false, "With -s3-bucket, upload the results without writing them locally")
+	requestRate      = flag.Float64("rate", 0, "Most requests per second to any one site, shared by all workers (0 is unlimited); config requestsPerSecond overrides it")
+	requestBurst     = flag.Int("rate-burst", 1, "Requests to a site that may go out at once before -rate pacing applies")
+	rateLimitHeaders = flag.Bool("ratelimit-headers", false, "Slow down per host according to X-RateLimit-Remaining/Reset response headers")
+	priceHistory     = flag.Bool("price-history", false, "Record every detected product price change, and a price snapshot of every crawled product page, in a price history")
+	productDetails   = flag.Bool("product-details", false, "Visit each discovered product page and extract its details")
+	crawlPhase       = flag.String("phase", phaseAll, "Crawl phase: discover (store URLs only), fetch (refresh details of stored URLs) or all")
+	noRedis          = flag.Bool("no-redis", false, "Track visited URLs in memory instead of Redis (also the default when REDIS_ADDR is empty)")
+	pprofAddr        = flag.String("pprof-addr", "", "Serve net/http/pprof on this address (e.g. localhost:6060) while the crawler runs")
+	overwriteOutput  = flag.Bool("overwrite", false, "Replace the results file if it already exists")
+	queueName        = flag.String("queue", "", "Share a Redis work queue under this name with other crawler instances instead of crawling the seeds alone")
+	queueWorkers     = flag.Int("queue-workers", 3, "Concurrent jobs this instance takes from the -queue")
+	queueIdle        = flag.Duration("queue-idle", 30*time.Second, "Stop once the -queue has been empty for this long")
+	remoteWS         = flag.String("remote-ws", "", "DevTools WebSocket URL of a running Chrome/browserless instance to use instead of launching Chrome")
+	maxReconnects    = flag.Int("reconnect-attempts", 5, "Times to try reconnecting to Redis or Postgres after a dropped connection before giving up")
+	regionMarkerList = flag.String("region-markers", defaultRegionMarkers, "Comma-separated phrases that mark a page as region-restricted")
+	proxyFlag        = flag.String("proxies", "", "Comma-separated proxies to rotate page loads through (default $PROXIES, then the config's proxies)")
+	proxySticky      = flag.Bool("proxy-sticky", false, "Keep each domain on one proxy until that proxy is dropped for failing")
+	regionProxyList  = flag.String("region-proxies", "", "Comma-separated region=proxy entries used to retry region-restricted pages")
+	scrollDelayMin   = flag.Duration("scroll-delay-min", 2*time.Second, "Shortest pause after each infinite-scroll pass")
+	scrollDelayMax   = flag.Duration("scroll-delay-max", 4*time.Second, "Longest pause after each infinite-scroll pass")
+	signAlg          = flag.String("sign", "", "Sign output files with hmac or ed25519, writing a detached <file>.sig")
+	signKeyPath      = flag.String("sign-key", "", "HMAC key file, or PEM Ed25519 key (private to sign, public suffices for -verify)")
+	stopAfterEmpty   = flag.Int("stop-after-empty", 0, "Stop crawling a host after this many consecutive listing pages yield no new product URL (0 disables)")
+	storeBackend     = flag.String("store", "", "Storage backend: postgres, sqlite, mongo, stdout or none for file output only (defaults to $STORAGE, then postgres)")
+	tracePages       = flag.Bool("trace", false, "Log a structured \"Page trace\" per listing page: final URL, status, scrolls, HTML size, raw and kept match counts")
+	summaryPath      = flag.String("summary", "", "Also write the end-of-run crawl summary as JSON to this file")
+	shadowDOM        = flag.Bool("shadow-dom", false, "Also collect links rendered inside open shadow roots")
+	sinceWindow      = flag.Duration("since", 0, "Skip product URLs that storage last saw within this long (e.g. 72h), even if Redis forgot them (0 disables)")
+	sitemapMode      = flag.Bool("sitemap", false, "Seed from each site's sitemaps (robots.txt Sitemap lines or /sitemap.xml) instead of rendering listing pages")
+	siteRegionList   = flag.String("site-regions", "", "Comma-separated host=region entries naming the regions each site serves")
+	webhookURL       = flag.String("webhook", "", "URL to POST a JSON run summary to when the crawl finishes")
+	verifyPath       = flag.String("verify", "", "Verify <file> against <file>.sig using -sign and -sign-key, then exit")
+	urlListDir       = flag.String("url-list-dir", "", "Directory to write sorted urls-<domain>.txt lists into (disabled when empty)")
 )
 
-// --- Regex Pattern for Product URLs ---
-var productURLPattern = regexp.MustCompile(`/(dp|gp/product|product|item|shop|p)/[a-zA-Z0-9-_]+(/|\?|$)`)
-
 // --- Database Model ---
 type ProductURL struct {
-	ID     uint   `gorm:"primaryKey"`
-	Domain string `gorm:"index"`
-	URL    string `gorm:"unique"`
+	ID        uint      `gorm:"primaryKey"`
+	Domain    string    `gorm:"index"`
+	URL       string    `gorm:"unique"`
+	SourceURL string    // Page the URL was discovered on
+	Seed      string    `gorm:"-"`         // Seed whose crawl surfaced the URL; recorded in product_url_seeds
+	LastSeen  time.Time `gorm:"index"`     // Last crawl that found the URL; stale URLs fall behind
+	SeenCount int       `gorm:"default:1"` // Number of times the URL has been found
+}
+
+// --- Seed Attribution Model ---
+// One row per (URL, seed) pair, so a URL stored once can still list every
+// seed that surfaced it.
+type ProductURLSeed struct {
+	ID   uint   `gorm:"primaryKey"`
+	URL  string `gorm:"uniqueIndex:idx_url_seed"`
+	Seed string `gorm:"uniqueIndex:idx_url_seed"`
 }
 
 // --- Crawl Result Struct ---
 type CrawlResult struct {
-	Domain string   `json:"domain"`
-	URLs   []string `json:"urls"`
+	Domain           string    `json:"domain"`
+	SourceURL        string    `json:"source_url"`
+	RequestedURL     string    `json:"requested_url,omitempty"` // Seed URL when it redirected to SourceURL
+	URLs             []string  `json:"urls"`
+	Products         []Product `json:"products,omitempty"`
+	RegionRestricted bool      `json:"region_restricted,omitempty"`
+}
+
+// --- Configure Structured Logging ---
+func initLogger(level, format string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid -log-level %q", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	var handler slog.Handler
+	switch format {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("invalid -log-format %q", format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
 }
 
 // --- Load Environment Variables ---
+// A missing .env is not fatal; the variables may come from the environment.
 func loadEnv() {
-	err := godotenv.Load(".env")
-	if err != nil {
-		log.Fatal("Error loading .env file")
+	if err := godotenv.Load(".env"); err != nil {
+		slog.Warn("No .env file loaded", "error", err)
 	}
 }
 
-// --- Initialize PostgreSQL Connection ---
-func initDB() {
-	loadEnv() // Load env variables
+// --- Whether a Flag Was Given on the Command Line ---
+func flagGiven(name string) bool {
+	given := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			given = true
+		}
+	})
+	return given
+}
 
-	dbHost := os.Getenv("DB_HOST")
-	dbUser := os.Getenv("DB_USER")
-	dbPassword := os.Getenv("DB_PASSWORD")
-	dbName := os.Getenv("DB_NAME")
-	dbPort := os.Getenv("DB_PORT")
+// --- Exit on Unrecoverable Startup Errors ---
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// --- Main Function ---
+func main() {
+	command, args := splitCommand(os.Args[1:])
+	flag.Usage = usage
+	flag.CommandLine.Parse(args)
+	runID, startedAt := newRunID(), time.Now().UTC()
 
-	if dbHost == "" || dbUser == "" || dbPassword == "" || dbName == "" || dbPort == "" {
-		log.Fatal("Database credentials are missing in .env file")
+	if err := initLogger(*logLevel, *logFormat); err != nil {
+		fatal("Invalid logging flags", err)
+	}
+	loadEnv()
+	applyEnvFlags()
+
+	if command != commandCrawl {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		switch command {
+		case commandExport:
+			runExport(ctx)
+		case commandServe:
+			runServe(ctx)
+		case commandPurge:
+			runPurge(ctx)
+		case commandHistory:
+			runHistory(ctx)
+		}
+		return
 	}
 
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
-		dbHost, dbUser, dbPassword, dbName, dbPort)
+	if *verifyPath != "" {
+		verifier, err := loadSigner(*signAlg, *signKeyPath)
+		if err != nil {
+			fatal("Invalid signing key", err)
+		}
+		if err := verifier.VerifyFile(*verifyPath); err != nil {
+			fatal("Signature verification failed", err)
+		}
+		slog.Info("Signature verified", "path", *verifyPath)
+		return
+	}
 
-	var err error
-	db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
-	if err != nil {
-		log.Fatalf("Database connection failed: %v", err)
+	if *scrollDelayMin < 0 || *scrollDelayMax < *scrollDelayMin {
+		fatal("Invalid scroll delays", fmt.Errorf("need 0 <= -scroll-delay-min (%s) <= -scroll-delay-max (%s)", *scrollDelayMin, *scrollDelayMax))
 	}
 
-	// Configure connection pooling
-	sqlDB, err := db.DB()
+	mode, err := parseFetchMode(*fetchMode)
 	if err != nil {
-		log.Fatalf("Failed to configure DB connection pool: %v", err)
+		fatal("Invalid fetch mode", err)
 	}
-	sqlDB.SetMaxOpenConns(20) // Max 20 concurrent connections
-	sqlDB.SetMaxIdleConns(10) // Keep 10 idle connections
-	sqlDB.SetConnMaxLifetime(30 * time.Minute)
-
-	// Auto-create table
-	db.AutoMigrate(&ProductURL{})
-	log.Println("Database initialized successfully")
-}
-
-// --- Initialize Redis Client ---
-func initRedis() {
-	redisAddr := os.Getenv("REDIS_ADDR")
-	if redisAddr == "" {
-		log.Fatal("REDIS_ADDR is missing in .env file")
+	if *crawlDepth < 0 {
+		fatal("Invalid -depth", fmt.Errorf("need -depth (%d) >= 0", *crawlDepth))
 	}
-
-	redisClient = redis.NewClient(&redis.Options{Addr: redisAddr})
-	_, err := redisClient.Ping(context.Background()).Result()
+	if *stopAfterEmpty < 0 {
+		fatal("Invalid -stop-after-empty", fmt.Errorf("need -stop-after-empty (%d) >= 0", *stopAfterEmpty))
+	}
+	if *sinceWindow < 0 {
+		fatal("Invalid -since", fmt.Errorf("need -since (%s) >= 0", *sinceWindow))
+	}
+	if *visitedNamespace == "" {
+		fatal("Invalid -visited-namespace", errors.New("-visited-namespace must not be empty"))
+	}
+	if *requestRate < 0 || *requestBurst < 1 {
+		fatal("Invalid rate", fmt.Errorf("need -rate (%g) >= 0 and -rate-burst (%d) >= 1", *requestRate, *requestBurst))
+	}
+	if *browserCount < 0 {
+		fatal("Invalid -browsers", fmt.Errorf("need -browsers (%d) >= 0", *browserCount))
+	}
+	if *concurrency < 0 || *hostConcurrency < 1 {
+		fatal("Invalid concurrency", fmt.Errorf("need -concurrency (%d) >= 0 and -per-domain-concurrency (%d) >= 1", *concurrency, *hostConcurrency))
+	}
+	overflow, err := parseHTMLOverflow(*htmlOverflow)
 	if err != nil {
-		log.Fatalf("Failed to connect to Redis: %v", err)
+		fatal("Invalid HTML overflow policy", err)
 	}
-
-	log.Println("Redis connected successfully")
-}
-
-// --- Check if URL is Already Visited (Redis) ---
-func isURLVisited(url string) bool {
-	exists, err := redisClient.Exists(context.Background(), url).Result()
+	phase, err := parsePhase(*crawlPhase)
 	if err != nil {
-		log.Printf("Redis error: %v", err)
-		return false
+		fatal("Invalid phase", err)
+	}
+	if *queueName != "" && (*dryRun || *queueWorkers < 1) {
+		fatal("Invalid queue flags", errors.New("-queue needs Redis, so no -dry-run, and -queue-workers of at least 1"))
+	}
+	if *sitemapMode && (*queueName != "" || phase == phaseFetch) {
+		fatal("Invalid sitemap flags", errors.New("-sitemap cannot be combined with -queue or -phase fetch"))
+	}
+	emulated, err := lookupDevice(*deviceName)
+	if err != nil {
+		fatal("Invalid device", err)
+	}
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		fatal("Invalid config", err)
+	}
+	if config.Output != "" && !flagGiven("output") {
+		*outputPath = config.Output
 	}
-	return exists > 0
-}
 
-// --- Mark URL as Visited (Redis) ---
-func markURLVisited(url string) {
-	if err := redisClient.Set(context.Background(), url, 1, redisExpiry).Err(); err != nil {
-		log.Printf("Failed to mark URL as visited: %v", err)
+	// Seed URLs come from the command line, falling back to the config file
+	// and then the defaults; -domains-from-db replaces them with the stored
+	// domains further down.
+	seedArgs := flag.Args()
+	if *domainsFromDB && len(seedArgs) > 0 {
+		fatal("Invalid seeds", errors.New("seed URLs cannot be combined with -domains-from-db"))
+	}
+	if len(seedArgs) == 0 {
+		seedArgs = config.Seeds
+	}
+	if len(seedArgs) == 0 {
+		seedArgs = defaultSeeds
+	}
+	domains, err := normalizeSeeds(seedArgs)
+	if err != nil {
+		fatal("Invalid seeds", err)
 	}
-}
 
-// --- Handle Infinite Scrolling ---
-func performInfiniteScroll(ctx context.Context) {
-	for i := 0; i < scrollAttempts; i++ {
-		err := chromedp.Run(ctx,
-			chromedp.Evaluate(`window.scrollBy(0, document.body.scrollHeight)`, nil),
-			chromedp.Sleep(time.Duration(rand.Intn(3)+2)*time.Second), // Random delay to mimic human behavior
-		)
-		if err != nil {
-			log.Printf("Scrolling error: %v", err)
-			return
+	if *signAlg != "" {
+		if outputSigner, err = loadSigner(*signAlg, *signKeyPath); err != nil {
+			fatal("Invalid signing key", err)
 		}
 	}
-}
+	if *s3Bucket != "" {
+		if resultUploader, err = newS3Uploader(context.Background(), *s3Bucket, *s3Prefix, *s3Endpoint, runID, startedAt, *s3SkipLocal); err != nil {
+			fatal("S3 setup failed", err)
+		}
+	} else if *s3SkipLocal {
+		fatal("Invalid S3 flags", errors.New("-s3-skip-local requires -s3-bucket"))
+	}
 
-// --- Handle Pagination ---
-func clickNextPage(ctx context.Context) bool {
-	var nextExists bool
-	err := chromedp.Run(ctx,
-		chromedp.Evaluate(`document.querySelector('a.next-page') !== null`, &nextExists),
-	)
-	if err != nil || !nextExists {
-		return false
-	}
-
-	err = chromedp.Run(ctx,
-		chromedp.Evaluate(`document.querySelector('a.next-page').click()`, nil),
-		chromedp.Sleep(pageLoadDelay),
-	)
-	return err == nil
-}
+	// Set when a backend stays unreachable. The run then ends like an
+	// interrupted one and exits non-zero once the deferred closes below
+	// have flushed the store.
+	var exitErr error
+	defer func() {
+		if exitErr != nil {
+			fatal("Crawl stopped", exitErr)
+		}
+	}()
 
-// --- Store Product URLs in Database ---
-func storeProductURLs(urls []string, domain string) {
-	for _, url := range urls {
-		// Ensure uniqueness before inserting into the database
-		var count int64
-		db.Model(&ProductURL{}).Where("url = ?", url).Count(&count)
+	audit, auditFile, err := openAuditLog(*auditLogPath, runID)
+	if err != nil {
+		fatal("Audit log setup failed", err)
+	}
+	defer auditFile.Close()
+
+	// A dry run never connects to the database or Redis, so it cannot
+	// migrate tables or read visited state left by earlier runs.
+	var store Store = newMemoryStore()
+	var visited VisitedSet = newMemoryVisitedSet()
+	var sink ProductSink
+	var queue *workQueue
+	var robotsCache *redis.Client // shares robots.txt between runs; nil without Redis
+	var redisVisited *redisVisitedSet
+	privateFrontier := false
+	if *dryRun {
+		slog.Info("Dry run: using in-memory storage; the database and Redis are not contacted")
+	} else {
+		if store, err = initStore(*storeBackend, runID); err != nil {
+			fatal("Storage setup failed", err)
+		}
+		if closer, ok := store.(io.Closer); ok {
+			defer closer.Close()
+		}
+		if sink, err = initSink(*kafkaBrokers, *kafkaTopic); err != nil {
+			fatal("Product sink setup failed", err)
+		}
+		if closer, ok := sink.(io.Closer); ok {
+			defer closer.Close()
+		}
 
-		if count == 0 { // Insert only if URL doesn't exist
-			db.Create(&ProductURL{Domain: domain, URL: url})
-			log.Printf("Stored product URL: %s", url)
+		// Without Redis the in-memory visited set only dedups within this
+		// run, and features built on Redis are unavailable.
+		if *noRedis || os.Getenv("REDIS_ADDR") == "" {
+			slog.Warn("Redis disabled: visited URLs are tracked in memory for this run only")
+			if *queueName != "" {
+				fatal("Invalid queue flags", errors.New("-queue requires Redis"))
+			}
+			if *bloomVisited {
+				slog.Warn("-bloom is ignored without Redis")
+			}
 		} else {
-			log.Printf("Duplicate URL skipped: %s", url)
+			redisClient, err := initRedis()
+			if err != nil {
+				fatal("Redis setup failed", err)
+			}
+			robotsCache = redisClient
+			redisVisited = newRedisVisitedSet(redisClient, *visitedNamespace)
+			visited = redisVisited
+			if *queueName != "" {
+				queue = newWorkQueue(redisClient, *queueName)
+			} else if *crawlDepth > 0 {
+				// The -depth frontier lives in Redis even without -queue.
+				queue = newWorkQueue(redisClient, frontierQueuePrefix+runID)
+				privateFrontier = true
+			}
+			if *bloomVisited {
+				if visited, err = newBloomVisitedSet(redisVisited, *bloomPath); err != nil {
+					fatal("Bloom filter setup failed", err)
+				}
+			}
 		}
 	}
-}
-
+	crawler := &Crawler{
+		store:          store,
+		visited:        visited,
+		config:         config,
+		productDetails: *productDetails,
+		dryRun:         *dryRun,
+		seeds:          newSeedTracker(),
+		fresh:          newFreshURLs(),
+		shadowDOM:      *shadowDOM,
+		fetchMode:      mode,
+		autoMinLinks:   *autoMinLinks,
+		fingerprints:   *fingerprints,
+		stealth:        *stealth,
+		device:         emulated,
+		remoteWS:       *remoteWS,
+		stats:          newCrawlStats(),
+		sessions:       newSessionCache(),
+		audit:          audit,
+		sink:           sink,
+		trace:          *tracePages,
+		limits:         newSiteLimits(config, *maxPages, *maxURLs),
+		maxDepth:       *crawlDepth,
+		progress:       newProgressTracker(*stopAfterEmpty),
+		maxHTMLBytes:   *maxHTMLBytes,
+		htmlOverflow:   overflow,
+		since:          *sinceWindow,
+		runID:          runID,
+		dumpDir:        *dumpEmptyDir,
+		queue:          queue,
+		scrollDelayMin: *scrollDelayMin,
+		scrollDelayMax: *scrollDelayMax,
+	}
+	if phase == phaseDiscover && crawler.productDetails {
+		slog.Info("Discovery phase: product details are left to the fetch phase")
+		crawler.productDetails = false
+	}
+	crawler.throttle = newRateLimitThrottle(*rateLimitHeaders)
+	if *ignoreRobots {
+		slog.Warn("robots.txt is ignored")
+	} else {
+		crawler.robots = newRobotsPolicy(robotsCache)
+	}
+	crawler.loads = newLoadLimiter(*concurrency, *hostConcurrency)
+	crawler.browsers = newBrowserPool(*browserCount, *stealth)
+	if *blockResources {
+		crawler.blockedDomains = parseBlockedDomains(*blockDomainList)
+	}
+	crawler.pacer = newPoliteness(config, *requestRate, *requestBurst)
+	if crawler.proxies, err = newProxyPool(proxyList(*proxyFlag, config), *proxySticky); err != nil {
+		fatal("Invalid proxies", err)
+	}
+	if crawler.proxies != nil && *remoteWS != "" && mode != fetchModeHTTP {
+		fatal("Invalid proxies", errors.New("proxies cannot be applied to a -remote-ws browser"))
+	}
 
-// --- Extract Product URLs from Page ---
-func extractProductURLs(htmlContent, baseURL string) []string {
-	matches := productURLPattern.FindAllString(htmlContent, -1)
-	uniqueURLs := make(map[string]bool)
-	var productURLs []string
+	// Cancelled on SIGINT/SIGTERM so in-flight crawls, database and Redis
+	// calls stop; partial results are still saved below.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// Cancelled with an errUnreachable cause when Redis stays down past
+	// -reconnect-attempts.
+	ctx, abort := context.WithCancelCause(ctx)
+	defer abort(nil)
+	if redisVisited != nil {
+		redisVisited.abort = abort
+	}
 
-	for _, match := range matches {
-		fullURL := baseURL + match
-		if !uniqueURLs[fullURL] {
-			uniqueURLs[fullURL] = true
-			productURLs = append(productURLs, fullURL)
+	// Re-crawling the stored domains refreshes LastSeen on URLs that are
+	// still listed, so the ones that disappeared fall behind.
+	if *domainsFromDB {
+		stored, err := store.Domains(ctx)
+		if err != nil {
+			fatal("Failed to load stored domains", err)
 		}
+		if domains, err = normalizeSeeds(seedsFromDomains(stored)); err != nil {
+			fatal("Invalid stored domains", err)
+		}
+		slog.Info("Crawling stored domains", "seeds", len(domains))
 	}
-	return productURLs
-}
 
-// --- Scrape Product Pages ---
-func scrapeWebsite(url string, resultChan chan<- CrawlResult, wg *sync.WaitGroup) {
-	defer wg.Done()
-
-	if isURLVisited(url) {
-		log.Printf("Skipping already crawled URL: %s", url)
-		return
+	if domainRules, err = buildDomainFilter(*allowDomains, *denyDomains, config, domains); err != nil {
+		fatal("Invalid domain lists", err)
 	}
-	markURLVisited(url)
-
-	opts := chromedp.DefaultExecAllocatorOptions[:]
-	allocCtx, cancel := chromedp.NewExecAllocator(context.Background(), opts...)
-	defer cancel()
+	regionMarkers = parseRegionMarkers(*regionMarkerList)
+	regionProxies = parseRegionMap(*regionProxyList)
+	siteRegions = parseRegionMap(*siteRegionList)
 
-	ctx, cancel := chromedp.NewContext(allocCtx)
-	defer cancel()
+	audit.Info("run_started", "seeds", len(domains), "phase", phase, "dry_run", *dryRun, "fetch_mode", mode)
 
-	ctx, cancel = context.WithTimeout(ctx, crawlTimeout)
-	defer cancel()
+	// -max-runtime caps the whole run. Page loads derive their crawlTimeout
+	// from this context, so whichever deadline comes first applies.
+	if *maxDuration > 0 && (*maxRuntime == 0 || *maxDuration < *maxRuntime) {
+		*maxRuntime = *maxDuration
+	}
+	if *maxRuntime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *maxRuntime)
+		defer cancel()
+	}
 
-	var htmlContent string
-	err := chromedp.Run(ctx,
-		chromedp.Navigate(url),
-		chromedp.WaitVisible(`body`, chromedp.ByQuery),
-		chromedp.OuterHTML(`html`, &htmlContent),
-	)
+	profiling, err := startProfiling(*pprofAddr, *cpuProfilePath, *memProfilePath)
 	if err != nil {
-		log.Printf("Failed to load page: %s | Error: %v", url, err)
-		return
+		fatal("Profiling setup failed", err)
 	}
-	//
-	log.Printf("Performing infinite scroll on: %s", url)
-	performInfiniteScroll(ctx)
-	chromedp.Run(ctx, chromedp.OuterHTML(`html`, &htmlContent))
-	//
-
-	productURLs := extractProductURLs(htmlContent, url)
 
-	//
-	storeProductURLs(productURLs, url)
-	//
-
-	for _, url := range productURLs {
-		db.Create(&ProductURL{Domain: url, URL: url})
+	var results []CrawlResult
+	if phase == phaseFetch {
+		if results, err = crawler.fetchStored(ctx); err != nil {
+			fatal("Fetch phase failed", err)
+		}
+	} else if *sitemapMode {
+		results = crawler.crawlSitemaps(ctx, domains)
+	} else if privateFrontier {
+		// No other instance feeds a private frontier, so it is done as
+		// soon as it drains.
+		results = crawler.crawlQueue(ctx, domains, *queueWorkers, 0)
+	} else if queue != nil {
+		results = crawler.crawlQueue(ctx, domains, *queueWorkers, *queueIdle)
+	} else {
+		results = crawler.crawlSeeds(ctx, domains)
 	}
 
-	resultChan <- CrawlResult{Domain: url, URLs: productURLs}
-}
+	crawler.browsers.Close()
 
-// --- Save Results to JSON File ---
-func saveResults(results []CrawlResult) {
-	file, err := os.Create("output.json")
-	if err != nil {
-		log.Fatalf("Failed to create output file: %v", err)
+	interrupted := ctx.Err() != nil
+	timeLimited := errors.Is(ctx.Err(), context.DeadlineExceeded)
+	if timeLimited {
+		slog.Warn("Maximum runtime reached, saving partial results", "max_runtime", *maxRuntime)
+	}
+	if cause := context.Cause(ctx); errors.Is(cause, errUnreachable) {
+		slog.Error("Redis unreachable, saving partial results", "error", cause)
+		exitErr = cause
 	}
-	defer file.Close()
-
-	jsonData, _ := json.MarshalIndent(results, "", "  ")
-	file.Write(jsonData)
-	log.Println("Crawling complete. Results saved in output.json")
-}
 
-// --- Main Function ---
-func main() {
-	initDB()
-	initRedis()
+	// The profiles cover the crawl, not the writing of its results.
+	profiling.Stop()
 
-	domains := []string{
-		"https://www.amazon.com/s?k=iphone",
-		"https://www.snapdeal.com/search?keyword=mobile",
-		"https://www.myntra.com/mobiles",
+	if *sortOutput {
+		sortResults(results)
+	}
+	if *outputDir != "" {
+		if err := saveResultsPerDomain(results, *outputDir, *overwriteOutput); err != nil {
+			fatal("Failed to save results", err)
+		}
+	} else if err := saveResults(results, *outputPath, *overwriteOutput); err != nil {
+		fatal("Failed to save results", err)
 	}
 
-	var results []CrawlResult
-	resultChan := make(chan CrawlResult, len(domains))
-	var wg sync.WaitGroup
+	if *urlListDir != "" {
+		if err := writeURLLists(results, *urlListDir); err != nil {
+			fatal("Failed to write URL lists", err)
+		}
+	}
+	if *graphPath != "" {
+		if err := writeLinkGraph(results, *graphPath, *overwriteOutput); err != nil {
+			fatal("Failed to export link graph", err)
+		}
+		slog.Info("Link graph exported", "path", *graphPath)
+	}
+	if *seedReportPath != "" {
+		if err := writeJSONFile(*seedReportPath, crawler.seeds.Report(), *overwriteOutput); err != nil {
+			fatal("Failed to write seed report", err)
+		}
+		slog.Info("Seed report saved", "path", *seedReportPath)
+	}
+	if *dupesReportPath != "" {
+		clusters := findDuplicates(results)
+		if err := exportJSON(*dupesReportPath, clusters, *overwriteOutput); err != nil {
+			fatal("Failed to write duplicate report", err)
+		}
+		slog.Info("Duplicate report saved", "path", *dupesReportPath, "clusters", len(clusters))
+	}
+	if *newURLsPath != "" {
+		if err := exportJSON(*newURLsPath, crawler.fresh.Report(), *overwriteOutput); err != nil {
+			fatal("Failed to write new URL report", err)
+		}
+		slog.Info("New URL report saved", "path", *newURLsPath)
+	}
 
-	for _, domain := range domains {
-		wg.Add(1)
-		go scrapeWebsite(domain, resultChan, &wg)
+	if *dryRun {
+		urls := 0
+		for _, res := range results {
+			urls += len(res.URLs)
+		}
+		slog.Info("Dry run complete: nothing was written to the database or Redis", "pages", len(results), "urls", urls)
 	}
 
-	wg.Wait()
-	close(resultChan)
+	if bloom, ok := visited.(*bloomVisitedSet); ok && *bloomPath != "" {
+		if err := bloom.filter.SaveFile(*bloomPath); err != nil {
+			slog.Error("Failed to save Bloom filter", "path", *bloomPath, "error", err)
+		} else {
+			slog.Info("Bloom filter saved", "path", *bloomPath)
+		}
+	}
 
-	for res := range resultChan {
-		results = append(results, res)
+	summary := crawler.stats.Summary()
+	summary.TimeLimited = timeLimited
+	// stderr, like the logs: with -store stdout, stdout carries the
+	// JSON-lines records and must stay parseable.
+	summary.Print(os.Stderr)
+	audit.Info("run_finished", "interrupted", interrupted, "time_limited", timeLimited, "pages", summary.Total.Pages,
+		"urls", summary.Total.Found, "errors", summary.Total.Errors, "elapsed", summary.Elapsed.String())
+	if *summaryPath != "" {
+		if err := writeJSONFile(*summaryPath, summary, *overwriteOutput); err != nil {
+			fatal("Failed to write summary", err)
+		}
+		slog.Info("Summary saved", "path", *summaryPath)
 	}
 
-	saveResults(results)
+	if *webhookURL != "" {
+		output := *outputPath
+		if *outputDir != "" {
+			output = *outputDir
+		}
+		payload := newWebhookPayload(runID, startedAt, summary, results, output)
+		payload.Interrupted = interrupted
+		payload.TimeLimited = timeLimited
+		payload.DryRun = *dryRun
+		notifyWebhook(*webhookURL, payload)
+	}
 }
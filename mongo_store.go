@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// --- MongoDB Store ---
+// Each product is one document keyed by its URL, so re-saving a URL
+// upserts the existing document instead of adding another:
+//
+//	{_id: <url>, source: {domain, page, seeds: [...]}, details: {...}, first_seen, last_seen, seen_count}
+//
+// The first save sets source.domain and source.page; later saves only add
+// their seed, so attribution accumulates without overwriting.
+type mongoStore struct {
+	client       *mongo.Client
+	collection   *mongo.Collection
+	priceHistory bool   // append every price change to the price_history array
+	runID        string // labels price_snapshots entries; empty records none
+}
+
+// --- Connect to MongoDB ---
+func newMongoStore(priceHistory bool, runID string) (*mongoStore, error) {
+	uri := os.Getenv("MONGO_URI")
+	if uri == "" {
+		return nil, fmt.Errorf("MONGO_URI is missing in .env file")
+	}
+	dbName := os.Getenv("MONGO_DB")
+	if dbName == "" {
+		dbName = "crawler"
+	}
+	collName := os.Getenv("MONGO_COLLECTION")
+	if collName == "" {
+		collName = "product_urls"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		return nil, fmt.Errorf("connect to MongoDB: %w", err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		client.Disconnect(context.Background())
+		return nil, fmt.Errorf("ping MongoDB: %w", err)
+	}
+
+	collection := client.Database(dbName).Collection(collName)
+	_, err = collection.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "source.domain", Value: 1}}})
+	if err != nil {
+		client.Disconnect(context.Background())
+		return nil, fmt.Errorf("create MongoDB index: %w", err)
+	}
+
+	slog.Info("MongoDB connected successfully", "database", dbName, "collection", collName)
+	return &mongoStore{client: client, collection: collection, priceHistory: priceHistory, runID: runID}, nil
+}
+
+func (s *mongoStore) Save(ctx context.Context, urls []ProductURL) error {
+	if len(urls) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	models := make([]mongo.WriteModel, 0, len(urls))
+	for _, record := range urls {
+		update := bson.M{
+			"$set":         bson.M{"last_seen": now},
+			"$inc":         bson.M{"seen_count": 1},
+			"$setOnInsert": bson.M{"source.domain": record.Domain, "source.page": record.SourceURL, "first_seen": now},
+		}
+		if record.Seed != "" {
+			update["$addToSet"] = bson.M{"source.seeds": record.Seed}
+		}
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": record.URL}).
+			SetUpdate(update).
+			SetUpsert(true))
+	}
+
+	res, err := s.collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return fmt.Errorf("upsert product documents: %w", err)
+	}
+	slog.Debug("Upserted product documents", "inserted", res.UpsertedCount, "updated", res.ModifiedCount)
+	return nil
+}
+
+// SaveProducts stores extracted fields under the nested details document.
+// A price differing from the stored details.price sets price_changed_at
+// and, with price history enabled, is appended to price_history; every
+// save then also appends a snapshot to price_snapshots.
+func (s *mongoStore) SaveProducts(ctx context.Context, products []Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	models := make([]mongo.WriteModel, 0, len(products))
+	for _, product := range products {
+		var stored struct {
+			Details *Product `bson:"details"`
+		}
+		err := s.collection.FindOne(ctx, bson.M{"_id": product.URL},
+			options.FindOne().SetProjection(bson.M{"details": 1})).Decode(&stored)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return fmt.Errorf("load stored price for %s: %w", product.URL, err)
+		}
+
+		update := bson.M{"$setOnInsert": bson.M{"first_seen": now}}
+		push := bson.M{}
+		if stored.Details != nil {
+			if samePrice(stored.Details.Price, product.Price) {
+				product.PriceChangedAt = stored.Details.PriceChangedAt
+			} else {
+				product.PriceChangedAt = &now
+				slog.Info("Price changed", "url", product.URL, "old", stored.Details.Price, "new", product.Price)
+				if s.priceHistory {
+					push["price_history"] = PriceChange{URL: product.URL, OldPrice: stored.Details.Price, NewPrice: product.Price, ChangedAt: now}
+				}
+			}
+		}
+		if s.priceHistory && s.runID != "" {
+			push["price_snapshots"] = newPriceSnapshot(product, s.runID, now)
+		}
+		if len(push) > 0 {
+			update["$push"] = push
+		}
+		update["$set"] = bson.M{"source.domain": product.Domain, "details": product, "last_seen": now}
+
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": product.URL}).
+			SetUpdate(update).
+			SetUpsert(true))
+	}
+
+	if _, err := s.collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false)); err != nil {
+		return fmt.Errorf("upsert product details: %w", err)
+	}
+	return nil
+}
+
+func (s *mongoStore) URLs(ctx context.Context) ([]string, error) {
+	ids, err := s.collection.Distinct(ctx, "_id", bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	urls := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if url, ok := id.(string); ok {
+			urls = append(urls, url)
+		}
+	}
+	return urls, nil
+}
+
+func (s *mongoStore) Domains(ctx context.Context) ([]string, error) {
+	values, err := s.collection.Distinct(ctx, "source.domain", bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	domains := make([]string, 0, len(values))
+	for _, value := range values {
+		if domain, ok := value.(string); ok && domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	return domains, nil
+}
+
+func (s *mongoStore) Records(ctx context.Context) ([]ProductURL, error) {
+	cursor, err := s.collection.Find(ctx, bson.M{}, options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetProjection(bson.M{"source.domain": 1, "source.page": 1, "last_seen": 1, "seen_count": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []struct {
+		URL    string `bson:"_id"`
+		Source struct {
+			Domain string `bson:"domain"`
+			Page   string `bson:"page"`
+		} `bson:"source"`
+		LastSeen  time.Time `bson:"last_seen"`
+		SeenCount int       `bson:"seen_count"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	records := make([]ProductURL, 0, len(docs))
+	for _, doc := range docs {
+		records = append(records, ProductURL{URL: doc.URL, Domain: doc.Source.Domain, SourceURL: doc.Source.Page, LastSeen: doc.LastSeen, SeenCount: doc.SeenCount})
+	}
+	return records, nil
+}
+
+func (s *mongoStore) PriceHistory(ctx context.Context, url string) ([]PriceSnapshot, error) {
+	filter := bson.M{"price_snapshots.0": bson.M{"$exists": true}}
+	if url != "" {
+		filter["_id"] = url
+	}
+	cursor, err := s.collection.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetProjection(bson.M{"price_snapshots": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []struct {
+		URL       string          `bson:"_id"`
+		Snapshots []PriceSnapshot `bson:"price_snapshots"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	var snapshots []PriceSnapshot
+	for _, doc := range docs {
+		for _, snapshot := range doc.Snapshots {
+			snapshot.URL = doc.URL
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	return snapshots, nil
+}
+
+func (s *mongoStore) RecentURLs(ctx context.Context, urls []string, since time.Time) (map[string]bool, error) {
+	if len(urls) == 0 {
+		return nil, nil
+	}
+	ids, err := s.collection.Distinct(ctx, "_id", bson.M{"_id": bson.M{"$in": urls}, "last_seen": bson.M{"$gte": since}})
+	if err != nil {
+		return nil, err
+	}
+	recent := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if url, ok := id.(string); ok {
+			recent[url] = true
+		}
+	}
+	return recent, nil
+}
+
+func (s *mongoStore) ExistingURLs(ctx context.Context, urls []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	for start := 0; start < len(urls); start += storeBatchSize {
+		chunk := urls[start:min(start+storeBatchSize, len(urls))]
+		ids, err := s.collection.Distinct(ctx, "_id", bson.M{"_id": bson.M{"$in": chunk}})
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if url, ok := id.(string); ok {
+				existing[url] = true
+			}
+		}
+	}
+	return existing, nil
+}
+
+func (s *mongoStore) Exists(ctx context.Context, url string) (bool, error) {
+	count, err := s.collection.CountDocuments(ctx, bson.M{"_id": url}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// --- Disconnect from MongoDB ---
+func (s *mongoStore) Close() error {
+	return s.client.Disconnect(context.Background())
+}
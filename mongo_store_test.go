@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// mongoUpdates returns the update statements of the last update command
+// the mock deployment received.
+func mongoUpdates(mt *mtest.T) []bson.M {
+	mt.Helper()
+	var updates []bson.M
+	for _, started := range mt.GetAllStartedEvents() {
+		if started.CommandName != "update" {
+			continue
+		}
+		var command struct {
+			Updates []bson.M `bson:"updates"`
+		}
+		if err := bson.Unmarshal(started.Command, &command); err != nil {
+			mt.Fatalf("decode update command: %v", err)
+		}
+		updates = command.Updates
+	}
+	if updates == nil {
+		mt.Fatal("no update command sent")
+	}
+	return updates
+}
+
+func TestMongoStoreSaveUpsertsByURL(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("save", func(mt *mtest.T) {
+		store := &mongoStore{client: mt.Client, collection: mt.Coll}
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}, bson.E{Key: "nModified", Value: 0}))
+
+		err := store.Save(context.Background(), []ProductURL{
+			{URL: "https://www.amazon.in/dp/B0AAAAAAAA/", Domain: "www.amazon.in", SourceURL: "https://www.amazon.in/s?k=phone", Seed: "https://www.amazon.in/"},
+			{URL: "https://www.amazon.in/dp/B0BBBBBBBB/", Domain: "www.amazon.in", SourceURL: "https://www.amazon.in/s?k=phone"},
+		})
+		if err != nil {
+			mt.Fatalf("Save: %v", err)
+		}
+
+		updates := mongoUpdates(mt)
+		if len(updates) != 2 {
+			mt.Fatalf("got %d update statements, want 2", len(updates))
+		}
+		first := updates[0]
+		if q := first["q"].(bson.M); q["_id"] != "https://www.amazon.in/dp/B0AAAAAAAA/" {
+			mt.Errorf("filter = %v, want the URL as _id", q)
+		}
+		if first["upsert"] != true {
+			mt.Errorf("upsert = %v, want true", first["upsert"])
+		}
+		u := first["u"].(bson.M)
+		onInsert := u["$setOnInsert"].(bson.M)
+		if onInsert["source.domain"] != "www.amazon.in" || onInsert["source.page"] != "https://www.amazon.in/s?k=phone" {
+			mt.Errorf("$setOnInsert = %v, want the domain and source page", onInsert)
+		}
+		if inc := u["$inc"].(bson.M); inc["seen_count"] != int32(1) {
+			mt.Errorf("$inc = %v, want seen_count 1", inc)
+		}
+		if seeds := u["$addToSet"].(bson.M); seeds["source.seeds"] != "https://www.amazon.in/" {
+			mt.Errorf("$addToSet = %v, want the seed", seeds)
+		}
+		if _, ok := updates[1]["u"].(bson.M)["$addToSet"]; ok {
+			mt.Error("record without a seed adds one")
+		}
+	})
+}
+
+func TestMongoStoreSaveProductsNestsDetails(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	url := "https://www.myntra.com/shirts/brand/slim-shirt/123456/buy"
+	price, stored := 799.0, 999.0
+	ns := mtest.TestDb + ".product_urls"
+
+	mt.Run("new product", func(mt *mtest.T) {
+		store := &mongoStore{client: mt.Client, collection: mt.Coll, priceHistory: true}
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, ns, mtest.FirstBatch),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+		)
+		err := store.SaveProducts(context.Background(), []Product{{URL: url, Domain: "www.myntra.com", Title: "Slim Shirt", Price: &price}})
+		if err != nil {
+			mt.Fatalf("SaveProducts: %v", err)
+		}
+		u := mongoUpdates(mt)[0]["u"].(bson.M)
+		set := u["$set"].(bson.M)
+		details := set["details"].(bson.M)
+		if details["title"] != "Slim Shirt" || details["price"] != price {
+			mt.Errorf("details = %v, want the nested product fields", details)
+		}
+		if _, ok := details["price_changed_at"]; ok {
+			mt.Error("first save of a product records a price change")
+		}
+		if _, ok := u["$push"]; ok {
+			mt.Errorf("$push = %v, want no price history for a new product", u["$push"])
+		}
+	})
+
+	mt.Run("price change", func(mt *mtest.T) {
+		store := &mongoStore{client: mt.Client, collection: mt.Coll, priceHistory: true}
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: url},
+				{Key: "details", Value: bson.D{{Key: "price", Value: stored}}},
+			}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+		)
+		err := store.SaveProducts(context.Background(), []Product{{URL: url, Domain: "www.myntra.com", Price: &price}})
+		if err != nil {
+			mt.Fatalf("SaveProducts: %v", err)
+		}
+		u := mongoUpdates(mt)[0]["u"].(bson.M)
+		if _, ok := u["$set"].(bson.M)["details"].(bson.M)["price_changed_at"]; !ok {
+			mt.Error("price change not timestamped")
+		}
+		change, ok := u["$push"].(bson.M)["price_history"].(bson.M)
+		if !ok || change["old_price"] != stored || change["new_price"] != price {
+			mt.Errorf("price_history entry = %v, want %v -> %v", change, stored, price)
+		}
+	})
+}
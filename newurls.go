@@ -0,0 +1,46 @@
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+// --- URLs New Since the Last Run ---
+// -new-urls reports, per host, the product URLs that were not in storage
+// before this run. A URL counts once it has been saved; it is recorded in
+// a run-wide set, so a URL two workers both found missing from storage
+// before either saved it is still reported once.
+type freshURLs struct {
+	mu    sync.Mutex
+	hosts map[string]map[string]bool
+}
+
+func newFreshURLs() *freshURLs {
+	return &freshURLs{hosts: make(map[string]map[string]bool)}
+}
+
+func (f *freshURLs) Add(url string) {
+	host := urlHost(url)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.hosts[host] == nil {
+		f.hosts[host] = make(map[string]bool)
+	}
+	f.hosts[host][url] = true
+}
+
+// --- New URLs per Host, Sorted ---
+func (f *freshURLs) Report() map[string][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	report := make(map[string][]string, len(f.hosts))
+	for host, set := range f.hosts {
+		urls := make([]string, 0, len(set))
+		for url := range set {
+			urls = append(urls, url)
+		}
+		sort.Strings(urls)
+		report[host] = urls
+	}
+	return report
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// --- Save Results to JSON File ---
+func saveResults(results []CrawlResult, path string, overwrite bool) error {
+	if err := exportJSON(path, results, overwrite); err != nil {
+		return err
+	}
+	slog.Info("Crawling complete. Results saved", "path", path)
+	return nil
+}
+
+// --- Save Results to One JSON File per Domain ---
+// Results are grouped by sanitized host so every retailer lands in its own
+// <host>.json inside dir.
+func saveResultsPerDomain(results []CrawlResult, dir string, overwrite bool) error {
+	byHost := make(map[string][]CrawlResult)
+	for _, res := range results {
+		host := resultHost(res.Domain)
+		byHost[host] = append(byHost[host], res)
+	}
+
+	for host, hostResults := range byHost {
+		path := filepath.Join(dir, host+".json")
+		if err := exportJSON(path, hostResults, overwrite); err != nil {
+			return err
+		}
+		slog.Info("Domain results saved", "host", host, "path", path)
+	}
+	return nil
+}
+
+// --- Write Results Locally and/or to S3 ---
+// Without -s3-bucket this is writeJSONFile; with it the same bytes are also
+// uploaded, and -s3-skip-local drops the local copy.
+func exportJSON(path string, v any, overwrite bool) error {
+	if resultUploader == nil || !resultUploader.skipLocal {
+		if err := writeJSONFile(path, v, overwrite); err != nil {
+			return err
+		}
+	}
+	if resultUploader == nil {
+		return nil
+	}
+	jsonData, err := encodeJSON(v)
+	if err != nil {
+		return err
+	}
+	return resultUploader.Upload(path, jsonData)
+}
+
+// --- Write Value as Indented JSON ---
+func writeJSONFile(path string, v any, overwrite bool) error {
+	jsonData, err := encodeJSON(v)
+	if err != nil {
+		return err
+	}
+	return writeOutputFile(path, jsonData, overwrite)
+}
+
+// --- Write an Output File ---
+// An existing file at path is only replaced when overwrite is set, so a
+// previous crawl is never clobbered by accident. With -sign a detached
+// signature is written next to it.
+func writeOutputFile(path string, data []byte, overwrite bool) error {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("create output directory: %w", err)
+		}
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !overwrite {
+		flags |= os.O_EXCL
+	}
+	file, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("output file %s already exists (use -overwrite to replace it)", path)
+		}
+		return fmt.Errorf("create output file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		return fmt.Errorf("write output file: %w", err)
+	}
+	return outputSigner.SignFile(path, data)
+}
+
+// --- Encode Value as Indented JSON ---
+func encodeJSON(v any) ([]byte, error) {
+	jsonData, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encode results: %w", err)
+	}
+	return jsonData, nil
+}
+
+// --- Sort Results for Stable Output ---
+func sortResults(results []CrawlResult) {
+	for i := range results {
+		sort.Strings(results[i].URLs)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Domain < results[j].Domain
+	})
+}
+
+// --- Sanitize Host for Use in File Names ---
+func sanitizeHost(host string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		}
+		return '_'
+	}, host)
+}
+
+// --- Sanitized Host of a Result Domain ---
+func resultHost(domain string) string {
+	host := domain
+	if parsed, err := url.Parse(domain); err == nil && parsed.Host != "" {
+		host = parsed.Hostname()
+	}
+	return sanitizeHost(host)
+}
+
+// --- Write Sorted URL Lists per Domain ---
+// Each host gets one urls-<host>.txt with a sorted, de-duplicated URL per
+// line, so consecutive runs over the same catalog produce identical files.
+func writeURLLists(results []CrawlResult, dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	byHost := make(map[string]map[string]bool)
+	for _, res := range results {
+		host := resultHost(res.Domain)
+		if byHost[host] == nil {
+			byHost[host] = make(map[string]bool)
+		}
+		for _, u := range res.URLs {
+			byHost[host][u] = true
+		}
+	}
+
+	for host, set := range byHost {
+		urls := make([]string, 0, len(set))
+		for u := range set {
+			urls = append(urls, u)
+		}
+		sort.Strings(urls)
+
+		var b strings.Builder
+		for _, u := range urls {
+			b.WriteString(u)
+			b.WriteByte('\n')
+		}
+		path := filepath.Join(dir, "urls-"+host+".txt")
+		if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+			return err
+		}
+		if err := outputSigner.SignFile(path, []byte(b.String())); err != nil {
+			return err
+		}
+		slog.Info("Wrote URL list", "path", path, "urls", len(urls))
+	}
+	return nil
+}
+
+// --- Dump a Page That Yielded No Product URLs ---
+// The captured HTML is written to <dir>/<hash of URL>.html, prefixed with a
+// comment naming the URL, so selector and pattern failures can be debugged
+// from the exact markup the crawler saw.
+func dumpEmptyPage(dir, pageURL, htmlContent string) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(pageURL))
+	path := filepath.Join(dir, hex.EncodeToString(sum[:8])+".html")
+	content := fmt.Sprintf("<!-- %s -->\n%s", strings.ReplaceAll(pageURL, "--", "%2D%2D"), htmlContent)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
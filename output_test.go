@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteURLListsIsStableAcrossRuns(t *testing.T) {
+	// The same catalog, surfaced in a different order and with duplicates.
+	runs := [][]CrawlResult{
+		{
+			{Domain: "https://www.amazon.in/s?k=phone", URLs: []string{
+				"https://www.amazon.in/dp/B0CCCCCCCC/",
+				"https://www.amazon.in/dp/B0AAAAAAAA/",
+			}},
+			{Domain: "https://www.snapdeal.com/products/mobiles", URLs: []string{
+				"https://www.snapdeal.com/product/phone/123456",
+			}},
+			{Domain: "https://www.amazon.in/s?k=laptop", URLs: []string{
+				"https://www.amazon.in/dp/B0BBBBBBBB/",
+			}},
+		},
+		{
+			{Domain: "https://www.snapdeal.com/products/mobiles", URLs: []string{
+				"https://www.snapdeal.com/product/phone/123456",
+				"https://www.snapdeal.com/product/phone/123456",
+			}},
+			{Domain: "https://www.amazon.in/s?k=laptop", URLs: []string{
+				"https://www.amazon.in/dp/B0BBBBBBBB/",
+				"https://www.amazon.in/dp/B0AAAAAAAA/",
+			}},
+			{Domain: "https://www.amazon.in/s?k=phone", URLs: []string{
+				"https://www.amazon.in/dp/B0CCCCCCCC/",
+			}},
+		},
+	}
+
+	dirs := make([]string, len(runs))
+	for i, results := range runs {
+		dirs[i] = t.TempDir()
+		if err := writeURLLists(results, dirs[i]); err != nil {
+			t.Fatalf("run %d: writeURLLists: %v", i+1, err)
+		}
+	}
+
+	want := map[string]string{
+		"urls-www.amazon.in.txt": "https://www.amazon.in/dp/B0AAAAAAAA/\n" +
+			"https://www.amazon.in/dp/B0BBBBBBBB/\n" +
+			"https://www.amazon.in/dp/B0CCCCCCCC/\n",
+		"urls-www.snapdeal.com.txt": "https://www.snapdeal.com/product/phone/123456\n",
+	}
+	for name, content := range want {
+		first, err := os.ReadFile(filepath.Join(dirs[0], name))
+		if err != nil {
+			t.Fatalf("read first run: %v", err)
+		}
+		second, err := os.ReadFile(filepath.Join(dirs[1], name))
+		if err != nil {
+			t.Fatalf("read second run: %v", err)
+		}
+		if !bytes.Equal(first, second) {
+			t.Errorf("%s differs between runs:\n%s\nvs\n%s", name, first, second)
+		}
+		if string(first) != content {
+			t.Errorf("%s = %q, want %q", name, first, content)
+		}
+	}
+	if entries, _ := os.ReadDir(dirs[0]); len(entries) != len(want) {
+		t.Errorf("wrote %d files, want %d", len(entries), len(want))
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+)
+
+// --- Pagination Strategies ---
+// Besides clicking the next-page control (strategyPaginate), a listing can
+// be paged by URL, loading the page number given by the domain's pageParam
+// or pageUrlTemplate (strategyPageURL), or grown in place by clicking its
+// loadMoreSelector button (strategyLoadMore). Every strategy visits at most
+// maxListingPages pages (maxPaginationPages by default) and stops on a
+// loop: a page that shows no product URL, or exactly the product URLs of
+// an earlier page, as sites do that serve their last page for any higher
+// number.
+const (
+	strategyPageURL  = "pageurl"
+	strategyLoadMore = "loadmore"
+)
+
+// pagePlaceholder is replaced with the page number in pageUrlTemplate.
+const pagePlaceholder = "{page}"
+
+// --- Listing Pages for a Domain ---
+func (d DomainConfig) maxListingPages() int {
+	if d.MaxListingPages == 0 {
+		return maxPaginationPages
+	}
+	return d.MaxListingPages
+}
+
+// --- Strategy Implied by the Pagination Settings ---
+// "" when the domain configures neither page URLs nor a load-more button.
+func (d DomainConfig) configuredPagination() string {
+	switch {
+	case d.PageParam != "" || d.PageURLTemplate != "":
+		return strategyPageURL
+	case d.LoadMoreSelector != "":
+		return strategyLoadMore
+	}
+	return ""
+}
+
+// --- URL of a Listing Page ---
+// pageParam sets the query parameter on the listing URL and keeps the rest
+// of its query; pageUrlTemplate is resolved against the listing URL, so
+// "?page={page}" and "/mobiles/page/{page}" both work.
+func (d DomainConfig) listingPageURL(listingURL string, page int) string {
+	number := strconv.Itoa(page)
+	if d.PageURLTemplate != "" {
+		return resolveURL(listingURL, strings.ReplaceAll(d.PageURLTemplate, pagePlaceholder, number))
+	}
+	parsed, err := url.Parse(listingURL)
+	if err != nil {
+		return ""
+	}
+	query := parsed.Query()
+	query.Set(d.PageParam, number)
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// --- Page Through a Listing ---
+// next loads the given page, numbered from 1 for the page already
+// loaded, and returns its HTML; false ends the listing. The HTML of every
+// page is concatenated, since product links are extracted from the
+// combined markup. Each page is reserved from the domain's page budget
+// before it loads and given back when it fails or repeats.
+func (c *Crawler) paginate(ctx context.Context, listingURL, htmlContent string, maxPages int, next func(page int) (string, bool)) string {
+	pages := []string{htmlContent}
+	size := len(htmlContent)
+	host := urlHost(listingURL)
+	listed := c.newListingURLs(htmlContent, listingURL, nil)
+	loops := make(map[string]bool)
+	c.pageRepeats(loops, htmlContent, listingURL)
+	for page := 2; page <= maxPages && c.limits.TakePage(host); page++ {
+		if c.pacer.Wait(ctx, host) != nil {
+			c.limits.ReturnPage(host)
+			break
+		}
+		pageHTML, ok := next(page)
+		if !ok {
+			c.limits.ReturnPage(host)
+			break
+		}
+		if c.pageRepeats(loops, pageHTML, listingURL) {
+			slog.Info("Listing page repeats an earlier one, stopping pagination", "url", listingURL, "page", page)
+			c.limits.ReturnPage(host)
+			break
+		}
+		pages = append(pages, pageHTML)
+		if c.progress != nil {
+			before := len(listed)
+			listed = c.newListingURLs(pageHTML, listingURL, listed)
+			if c.progress.Record(host, len(listed)-before) {
+				break
+			}
+		}
+		if size += len(pageHTML); c.maxHTMLBytes > 0 && size >= c.maxHTMLBytes {
+			slog.Debug("Listing reached -max-html-bytes, stopping pagination", "url", listingURL, "pages", len(pages))
+			break
+		}
+	}
+	slog.Debug("Paginated listing", "url", listingURL, "pages", len(pages))
+	traceFrom(ctx).record(func(t *pageTrace) { t.pages = len(pages) })
+	return strings.Join(pages, "\n")
+}
+
+// --- Loop Detection ---
+// Fingerprints the page's product URLs, order aside, and reports whether
+// the page is empty or an earlier page had the same ones.
+func (c *Crawler) pageRepeats(seen map[string]bool, htmlContent, listingURL string) bool {
+	urls := c.listingURLs(htmlContent, listingURL)
+	if len(urls) == 0 {
+		return true
+	}
+	sort.Strings(urls)
+	sum := sha256.Sum256([]byte(strings.Join(urls, "\n")))
+	fingerprint := hex.EncodeToString(sum[:])
+	if seen[fingerprint] {
+		return true
+	}
+	seen[fingerprint] = true
+	return false
+}
+
+// --- Next Page by Clicking ---
+func (c *Crawler) clickedPage(ctx context.Context, listingURL, nextSelector string) func(page int) (string, bool) {
+	return func(page int) (string, bool) {
+		if !clickNextPage(ctx, nextSelector) {
+			return "", false
+		}
+		var pageHTML string
+		if err := chromedp.Run(ctx, chromedp.OuterHTML(`html`, &pageHTML)); err != nil {
+			slog.Warn("Pagination error", "url", listingURL, "page", page, "error", err)
+			return "", false
+		}
+		return pageHTML, true
+	}
+}
+
+// --- Next Page by URL ---
+// Loads the page in the listing's tab, subject to robots.txt like any
+// other page.
+func (c *Crawler) numberedPage(ctx context.Context, listingURL string, cfg DomainConfig) func(page int) (string, bool) {
+	return func(page int) (string, bool) {
+		pageURL := cfg.listingPageURL(listingURL, page)
+		if pageURL == "" {
+			return "", false
+		}
+		if err := c.robots.Check(ctx, pageURL); err != nil {
+			slog.Info("Stopping pagination at a page robots.txt disallows", "url", pageURL, "error", err)
+			return "", false
+		}
+		var pageHTML string
+		err := chromedp.Run(ctx,
+			chromedp.Navigate(pageURL),
+			chromedp.WaitVisible(`body`, chromedp.ByQuery),
+			chromedp.OuterHTML(`html`, &pageHTML),
+		)
+		if err != nil {
+			slog.Warn("Pagination error", "url", pageURL, "page", page, "error", err)
+			return "", false
+		}
+		return pageHTML, true
+	}
+}
+
+// --- Grow a Listing with Its Load-More Button ---
+// Clicks until the button disappears, maxPages clicks are used up or a
+// click adds no product URL, and returns the grown page. Like pages, a
+// click that fails or adds nothing gives back its page budget.
+func (c *Crawler) clickLoadMore(ctx context.Context, listingURL, htmlContent string, cfg DomainConfig) string {
+	host := urlHost(listingURL)
+	count := len(c.listingURLs(htmlContent, listingURL))
+	clicks := 0
+	for page := 2; page <= cfg.maxListingPages() && c.limits.TakePage(host); page++ {
+		if c.pacer.Wait(ctx, host) != nil || !clickNextPage(ctx, cfg.LoadMoreSelector) {
+			c.limits.ReturnPage(host)
+			break
+		}
+		var grown string
+		if err := chromedp.Run(ctx, chromedp.OuterHTML(`html`, &grown)); err != nil {
+			slog.Warn("Load-more error", "url", listingURL, "clicks", clicks+1, "error", err)
+			c.limits.ReturnPage(host)
+			break
+		}
+		clicks++
+		after := len(c.listingURLs(grown, listingURL))
+		htmlContent = grown
+		if after <= count {
+			slog.Info("Load-more click added no products, stopping", "url", listingURL, "clicks", clicks)
+			c.limits.ReturnPage(host)
+			break
+		}
+		count = after
+		if c.maxHTMLBytes > 0 && len(htmlContent) >= c.maxHTMLBytes {
+			break
+		}
+	}
+	slog.Debug("Loaded more listings", "url", listingURL, "clicks", clicks, "products", count)
+	traceFrom(ctx).record(func(t *pageTrace) { t.pages = clicks + 1 })
+	return htmlContent
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// Pages that fail or repeat an earlier one give their page budget back.
+func TestPaginateSpendsBudgetOnLoadedPagesOnly(t *testing.T) {
+	listing := "https://www.snapdeal.com/products/mobiles"
+	page := func(ids ...string) string {
+		var html string
+		for _, id := range ids {
+			html += `<a href="/product/phone-` + id + `/6382194585` + id + `">Phone</a>`
+		}
+		return html
+	}
+	pages := map[int]string{2: page("21", "22"), 3: page("21", "22")}
+	tests := []struct {
+		name string
+		next func(page int) (string, bool)
+		want int
+	}{
+		{"last page repeats", func(n int) (string, bool) { return pages[n], true }, 1},
+		{"next page fails", func(n int) (string, bool) { return pages[n], n < 3 }, 1},
+		{"first page fails", func(int) (string, bool) { return "", false }, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCrawler(t)
+			c.limits = newSiteLimits(c.config, 10, 0)
+			c.paginate(context.Background(), listing, page("11", "12"), 5, tt.next)
+			if got := c.limits.pages["snapdeal.com"]; got != tt.want {
+				t.Errorf("spent %d pages of the budget, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+)
+
+// --- Crawl Phases ---
+// Discovery (finding and storing product URLs) and fetching (refreshing
+// product details for stored URLs) can be scheduled independently, e.g.
+// discover weekly and fetch daily; both share the URL store. "all" runs
+// them together as a single crawl.
+const (
+	phaseAll      = "all"
+	phaseDiscover = "discover"
+	phaseFetch    = "fetch"
+)
+
+// --- Validate -phase ---
+func parsePhase(phase string) (string, error) {
+	switch phase {
+	case phaseAll, phaseDiscover, phaseFetch:
+		return phase, nil
+	}
+	return "", fmt.Errorf("invalid -phase %q (want all, discover or fetch)", phase)
+}
+
+// --- Crawl Seed Pages Concurrently ---
+// With -depth, pages reached from the seeds join the same wait group, so
+// results are collected until the whole frontier is done.
+func (c *Crawler) crawlSeeds(ctx context.Context, seeds []string) []CrawlResult {
+	resultChan := make(chan CrawlResult, len(seeds))
+	var wg sync.WaitGroup
+
+	for _, seed := range seeds {
+		wg.Add(1)
+		go c.scrapeWebsite(ctx, queueJob{Kind: jobListing, URL: seed, Seed: seed}, resultChan, &wg)
+	}
+	go func() {
+		wg.Wait()
+		close(resultChan)
+	}()
+
+	var results []CrawlResult
+	for res := range resultChan {
+		results = append(results, res)
+	}
+	return results
+}
+
+// --- Refresh Product Details for Stored URLs ---
+// Hosts are fetched concurrently, each host's URLs in order, with one
+// result per host.
+func (c *Crawler) fetchStored(ctx context.Context) ([]CrawlResult, error) {
+	urls, err := c.store.URLs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list stored URLs: %w", err)
+	}
+	byHost := make(map[string][]string)
+	for _, url := range urls {
+		host := urlHost(url)
+		byHost[host] = append(byHost[host], url)
+	}
+	slog.Info("Fetching details for stored product URLs", "urls", len(urls), "hosts", len(byHost))
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var results []CrawlResult
+	for host, hostURLs := range byHost {
+		sort.Strings(hostURLs)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			products := c.fetchProductDetails(ctx, hostURLs)
+			if c.dryRun {
+				slog.Info("Dry run: would store product details", "host", host, "products", len(products))
+			} else if err := c.store.SaveProducts(ctx, products); err != nil {
+				slog.Error("Failed to store product details", "host", host, "error", err)
+				c.stats.Error(host)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			results = append(results, CrawlResult{Domain: host, URLs: hostURLs, Products: products})
+		}()
+	}
+	wg.Wait()
+	return results, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDiscoverOnceFetchTwice(t *testing.T) {
+	ctx := context.Background()
+	fetches := new(atomic.Int32)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/phones", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<a href="/dp/B0AAAAAAAA/">Phone</a><a href="/dp/B0BBBBBBBB/">Phone</a>`)
+	})
+	mux.HandleFunc("/dp/", func(w http.ResponseWriter, r *http.Request) {
+		// Each fetch round fetches both products and raises the price by 10.
+		round := fetches.Add(1)
+		fmt.Fprintf(w, `<h1>Phone</h1><span class="price">$%d.00</span>`, 90+10*((round+1)/2))
+	})
+	site := httptest.NewServer(mux)
+	defer site.Close()
+	store := newSQLiteStore(t)
+	config := &Config{Domains: map[string]DomainConfig{urlHost(site.URL): {PriceSelector: ".price"}}}
+
+	// Each phase is a run of its own, sharing nothing but the store.
+	newPhase := func() *Crawler {
+		c := newTestCrawler(t)
+		c.store, c.config = store, config
+		return c
+	}
+	storedURLs := func() []string {
+		urls, err := store.URLs(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return urls
+	}
+
+	newPhase().crawlSeeds(ctx, []string{site.URL + "/phones"})
+	discovered := storedURLs()
+	want := []string{site.URL + "/dp/B0AAAAAAAA/", site.URL + "/dp/B0BBBBBBBB/"}
+	if !reflect.DeepEqual(discovered, want) {
+		t.Fatalf("discovered %v, want %v", discovered, want)
+	}
+	if fetches.Load() != 0 {
+		t.Fatalf("discovery fetched %d product pages, want none", fetches.Load())
+	}
+
+	for round, price := range []float64{100, 110} {
+		results, err := newPhase().fetchStored(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || len(results[0].Products) != 2 {
+			t.Fatalf("fetch %d: results %+v, want both products of the one host", round+1, results)
+		}
+		if urls := storedURLs(); !reflect.DeepEqual(urls, discovered) {
+			t.Errorf("fetch %d: stored URLs changed to %v", round+1, urls)
+		}
+
+		var products []Product
+		if err := store.db.Order("url").Find(&products).Error; err != nil {
+			t.Fatal(err)
+		}
+		if len(products) != 2 {
+			t.Fatalf("fetch %d: %d stored products, want 2", round+1, len(products))
+		}
+		for _, product := range products {
+			if product.Price == nil || *product.Price != price {
+				t.Errorf("fetch %d: %s price = %v, want %v", round+1, product.URL, deref(product.Price), price)
+			}
+			if changed := product.PriceChangedAt != nil; changed != (round > 0) {
+				t.Errorf("fetch %d: %s price change recorded = %v", round+1, product.URL, changed)
+			}
+		}
+	}
+
+	records, err := store.Records(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range records {
+		if record.SeenCount != 1 {
+			t.Errorf("%s seen %d times, want fetches to leave discovery state alone", record.URL, record.SeenCount)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// --- Per-Domain Politeness Rate Limit ---
+// -rate caps requests per second to any one site, however many workers
+// crawl it; the requestsPerSecond config entry overrides it per domain
+// and 0 means unlimited. Each registrable domain has a token bucket
+// holding up to -rate-burst requests, so www. and m. hosts of a site share
+// one budget; the bucket takes the rate configured for the first host
+// that used it. Every page load and pagination click takes a token.
+type politeness struct {
+	mu      sync.Mutex
+	config  *Config
+	rate    float64
+	burst   int
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	rate   float64 // tokens per second
+	tokens float64 // negative when requests are queued on the bucket
+	last   time.Time
+}
+
+func newPoliteness(config *Config, rate float64, burst int) *politeness {
+	return &politeness{config: config, rate: rate, burst: burst, buckets: make(map[string]*tokenBucket)}
+}
+
+// --- Wait for a Request Slot ---
+// Reserves the next token of host's domain and sleeps until it is due, so
+// concurrent callers are spaced 1/rate apart. A nil limiter never waits.
+func (p *politeness) Wait(ctx context.Context, host string) error {
+	if p == nil {
+		return nil
+	}
+	domain := registrableDomain(host)
+	now := time.Now()
+
+	p.mu.Lock()
+	bucket, ok := p.buckets[domain]
+	if !ok {
+		rate := p.rate
+		if configured := p.config.forHost(host).RequestsPerSecond; configured != 0 {
+			rate = configured
+		}
+		bucket = &tokenBucket{rate: rate, tokens: float64(p.burst), last: now}
+		p.buckets[domain] = bucket
+	}
+	if bucket.rate <= 0 {
+		p.mu.Unlock()
+		return nil
+	}
+	bucket.tokens = min(float64(p.burst), bucket.tokens+now.Sub(bucket.last).Seconds()*bucket.rate)
+	bucket.last = now
+	bucket.tokens--
+	wait := time.Duration(-bucket.tokens / bucket.rate * float64(time.Second))
+	p.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	slog.Debug("Pacing requests to domain", "domain", domain, "wait", wait)
+	sleepCtx(ctx, wait)
+	return ctx.Err()
+}
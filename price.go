@@ -0,0 +1,159 @@
+package main
+
+import (
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// --- Price Normalization ---
+// Raw prices such as "$1,299.00", "₹1,29,900" or "1.299,00 €" become an
+// amount plus an ISO 4217 currency code. The domain's locale decides which
+// separator is the decimal point; without one it is guessed per price.
+
+// Languages that write the decimal separator as a comma.
+var decimalCommaLanguages = map[string]bool{
+	"cs": true, "da": true, "de": true, "es": true, "fi": true, "fr": true,
+	"id": true, "it": true, "nb": true, "nl": true, "pl": true, "pt": true,
+	"ru": true, "sv": true, "tr": true,
+}
+
+// Locales of those languages that still use a decimal point, like Swiss
+// prices ("CHF 1'299.50").
+var decimalPointLocales = map[string]bool{"de-CH": true, "it-CH": true}
+
+// Currency assumed for a locale's region when the price shows none.
+var regionCurrencies = map[string]string{
+	"AU": "AUD", "CA": "CAD", "CH": "CHF", "DE": "EUR", "ES": "EUR", "FR": "EUR",
+	"GB": "GBP", "IN": "INR", "IT": "EUR", "JP": "JPY", "NL": "EUR",
+	"US": "USD",
+}
+
+// Currency symbols in match order; longer symbols come first so "US$"
+// is not read as a bare "$".
+var currencySymbols = []struct{ symbol, code string }{
+	{"US$", "USD"}, {"CA$", "CAD"}, {"A$", "AUD"}, {"Rs.", "INR"}, {"Rs", "INR"},
+	{"₹", "INR"}, {"€", "EUR"}, {"£", "GBP"}, {"¥", "JPY"}, {"$", "USD"},
+}
+
+var (
+	currencyCodePattern = regexp.MustCompile(`\b(USD|EUR|GBP|INR|JPY|CAD|AUD|CHF|CNY)\b`)
+	// Digits with grouping and decimal separators, including the spaces
+	// and apostrophes some locales group thousands with.
+	amountPattern = regexp.MustCompile(`\d[\d.,'\x{00A0}\x{202F} ]*`)
+)
+
+// --- Extract and Normalize a Product's Prices ---
+func extractPrices(doc *goquery.Document, cfg DomainConfig, product *Product) {
+	product.PriceRaw = selectText(doc, cfg.PriceSelector)
+	product.MemberPriceRaw = selectText(doc, cfg.MemberPriceSelector)
+
+	var currency, memberCurrency string
+	product.Price, currency = normalizePrice(product.PriceRaw, cfg)
+	product.MemberPrice, memberCurrency = normalizePrice(product.MemberPriceRaw, cfg)
+	product.Currency = currency
+	if product.Currency == "" {
+		product.Currency = memberCurrency
+	}
+
+	if (product.PriceRaw != "" && product.Price == nil) || (product.MemberPriceRaw != "" && product.MemberPrice == nil) {
+		product.PriceUnparsed = true
+		slog.Debug("Unparseable price", "url", product.URL, "price", product.PriceRaw, "member_price", product.MemberPriceRaw)
+	}
+}
+
+// --- Text of the First Element Matching selector ---
+func selectText(doc *goquery.Document, selector string) string {
+	if selector == "" {
+		return ""
+	}
+	return strings.TrimSpace(doc.Find(selector).First().Text())
+}
+
+// --- Normalize One Raw Price ---
+// Returns a nil amount when raw holds no parseable number.
+func normalizePrice(raw string, cfg DomainConfig) (*float64, string) {
+	if raw == "" {
+		return nil, ""
+	}
+	language, region, _ := strings.Cut(cfg.Locale, "-")
+
+	var amount float64
+	var ok bool
+	if language == "" {
+		amount, ok = parsePrice(raw)
+	} else {
+		decimalComma := decimalCommaLanguages[strings.ToLower(language)] && !decimalPointLocales[cfg.Locale]
+		amount, ok = parseLocalizedPrice(raw, decimalComma)
+	}
+	if !ok {
+		return nil, ""
+	}
+	return &amount, priceCurrency(raw, cfg.Currency, strings.ToUpper(region))
+}
+
+// --- Parse a Price Written with a Known Decimal Separator ---
+// Every other separator groups digits, so Indian lakh grouping such as
+// "1,29,900" parses like any other thousands grouping.
+func parseLocalizedPrice(text string, decimalComma bool) (float64, bool) {
+	match := strings.TrimRight(amountPattern.FindString(text), ".,'   ")
+	if match == "" {
+		return 0, false
+	}
+	decimal, grouping := ".", ","
+	if decimalComma {
+		decimal, grouping = ",", "."
+	}
+	digits := strings.NewReplacer(grouping, "", "'", "", " ", "", " ", "", " ", "").Replace(match)
+	digits = strings.Replace(digits, decimal, ".", 1)
+	amount, err := strconv.ParseFloat(digits, 64)
+	if err != nil {
+		return 0, false
+	}
+	return amount, true
+}
+
+// --- Parse a Price Like "$1,299.99" or "1.299,99 €" ---
+// Used without a locale hint: the last separator is the decimal point when
+// one or two digits follow it; every other separator groups thousands.
+func parsePrice(text string) (float64, bool) {
+	match := countPattern.FindString(text)
+	if match == "" {
+		return 0, false
+	}
+	match = strings.TrimRight(match, ",.")
+	whole, fraction := match, ""
+	if i := strings.LastIndexAny(match, ",."); i >= 0 && len(match)-i-1 <= 2 {
+		whole, fraction = match[:i], match[i+1:]
+	}
+	digits := strings.NewReplacer(",", "", ".", "").Replace(whole)
+	if fraction != "" {
+		digits += "." + fraction
+	}
+	price, err := strconv.ParseFloat(digits, 64)
+	if err != nil {
+		return 0, false
+	}
+	return price, true
+}
+
+// --- Currency of a Price ---
+// The configured currency wins, then an ISO code or symbol in the text,
+// then the currency of the locale's region.
+func priceCurrency(raw, configured, region string) string {
+	if configured != "" {
+		return strings.ToUpper(configured)
+	}
+	if code := currencyCodePattern.FindString(raw); code != "" {
+		return code
+	}
+	for _, s := range currencySymbols {
+		if strings.Contains(raw, s.symbol) {
+			return s.code
+		}
+	}
+	return regionCurrencies[region]
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// --- Product Detail Model ---
+// Product holds fields read from a product page. Optional counts are
+// pointers so "not shown on the page" stays distinct from zero.
+//
+// Price is the public price and MemberPrice the members-only price, both
+// normalized amounts in Currency and nil when the page does not show one;
+// the *Raw fields keep the text as shown. URL is the page's canonical URL
+// and RequestedURL the requested product URL when it differs, because of
+// a redirect or a canonical tag. PriceChangedAt is maintained by
+// the store and records when Price last differed from the stored price.
+type Product struct {
+	ID                    uint       `gorm:"primaryKey" json:"-" bson:"-"`
+	URL                   string     `gorm:"uniqueIndex" json:"url" bson:"-"`
+	RequestedURL          string     `json:"requested_url,omitempty" bson:"requested_url,omitempty"`
+	Domain                string     `gorm:"index" json:"domain" bson:"-"`
+	Title                 string     `json:"title,omitempty" bson:"title,omitempty"`
+	Brand                 string     `json:"brand,omitempty" bson:"brand,omitempty"`
+	GTIN                  string     `gorm:"index" json:"gtin,omitempty" bson:"gtin,omitempty"` // padded to 14 digits
+	SKU                   string     `json:"sku,omitempty" bson:"sku,omitempty"`                // the site's own product code
+	QACount               *int       `json:"qa_count,omitempty" bson:"qa_count,omitempty"`
+	Price                 *float64   `json:"price,omitempty" bson:"price,omitempty"`
+	MemberPrice           *float64   `json:"member_price,omitempty" bson:"member_price,omitempty"`
+	Currency              string     `json:"currency,omitempty" bson:"currency,omitempty"`
+	PriceRaw              string     `json:"price_raw,omitempty" bson:"price_raw,omitempty"`
+	MemberPriceRaw        string     `json:"member_price_raw,omitempty" bson:"member_price_raw,omitempty"`
+	PriceUnparsed         bool       `json:"price_unparsed,omitempty" bson:"price_unparsed,omitempty"` // a raw price could not be normalized
+	Coupons               []string   `gorm:"serializer:json" json:"coupons,omitempty" bson:"coupons,omitempty"`
+	ImageURL              string     `json:"image_url,omitempty" bson:"image_url,omitempty"`
+	Availability          string     `json:"availability,omitempty" bson:"availability,omitempty"` // schema.org ItemAvailability, e.g. "InStock"
+	Rating                *float64   `json:"rating,omitempty" bson:"rating,omitempty"`
+	ReviewCount           *int       `json:"review_count,omitempty" bson:"review_count,omitempty"`
+	ShippingCost          *float64   `json:"shipping_cost,omitempty" bson:"shipping_cost,omitempty"` // 0 for free shipping
+	ShippingRaw           string     `json:"shipping_raw,omitempty" bson:"shipping_raw,omitempty"`
+	FreeShippingThreshold *float64   `json:"free_shipping_threshold,omitempty" bson:"free_shipping_threshold,omitempty"` // order value above which shipping is free
+	PriceChangedAt        *time.Time `json:"price_changed_at,omitempty" bson:"price_changed_at,omitempty"`
+	UpdatedAt             time.Time  `json:"updated_at" bson:"updated_at"`
+}
+
+// --- Price History Model ---
+// One row per observed price change, recorded with -price-history.
+type PriceChange struct {
+	ID        uint      `gorm:"primaryKey" json:"-" bson:"-"`
+	URL       string    `gorm:"index" json:"url" bson:"url"`
+	OldPrice  *float64  `json:"old_price" bson:"old_price"`
+	NewPrice  *float64  `json:"new_price" bson:"new_price"`
+	ChangedAt time.Time `json:"changed_at" bson:"changed_at"`
+}
+
+// --- Price Snapshot Model ---
+// With -price-history every crawl of a product page records one snapshot,
+// whether or not the price changed, so prices can be followed over time.
+// URL and RunID are unique together, so a write repeated after a
+// reconnect does not record the crawl twice.
+type PriceSnapshot struct {
+	ID           uint      `gorm:"primaryKey" json:"-" bson:"-"`
+	URL          string    `gorm:"uniqueIndex:idx_price_snapshot_run" json:"url" bson:"-"`
+	RunID        string    `gorm:"uniqueIndex:idx_price_snapshot_run" json:"run_id" bson:"run_id"`
+	Price        *float64  `json:"price,omitempty" bson:"price,omitempty"`
+	MemberPrice  *float64  `json:"member_price,omitempty" bson:"member_price,omitempty"`
+	Currency     string    `json:"currency,omitempty" bson:"currency,omitempty"`
+	Availability string    `json:"availability,omitempty" bson:"availability,omitempty"`
+	SeenAt       time.Time `gorm:"index" json:"seen_at" bson:"seen_at"`
+}
+
+func newPriceSnapshot(product Product, runID string, now time.Time) PriceSnapshot {
+	return PriceSnapshot{
+		URL:          product.URL,
+		RunID:        runID,
+		Price:        product.Price,
+		MemberPrice:  product.MemberPrice,
+		Currency:     product.Currency,
+		Availability: product.Availability,
+		SeenAt:       now,
+	}
+}
+
+// --- Compare Optional Prices ---
+func samePrice(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// --- Pattern for Counts Like "1,234 answered questions" ---
+var countPattern = regexp.MustCompile(`\d[\d,.]*`)
+
+// --- Pattern for Coupon Codes Like "Use code SAVE10" ---
+var couponPattern = regexp.MustCompile(`\b(?i:use|apply|enter|promo|coupon)\s+(?i:code):?\s+([A-Z0-9][A-Z0-9_-]{2,19})\b`)
+
+// --- Extract Product Details from HTML ---
+// Selectors come from the domain config; fields whose selector is unset or
+// matches nothing are left empty. schema.org structured data, when the
+// page has it, takes precedence for the fields it carries.
+func extractProductDetails(htmlContent, pageURL string, cfg DomainConfig) Product {
+	product := Product{URL: canonicalURL(pageURL), Domain: urlHost(pageURL), UpdatedAt: time.Now().UTC()}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlContent))
+	if err != nil {
+		slog.Warn("Failed to parse product page", "url", pageURL, "error", err)
+		return product
+	}
+
+	if canonical := canonicalLink(doc, pageURL); canonical != "" {
+		product.URL = canonical
+	}
+
+	extractIdentity(doc, cfg, &product)
+	product.Coupons = extractCoupons(doc, cfg)
+	extractPrices(doc, cfg, &product)
+	extractShipping(doc, cfg, &product)
+	product.ImageURL = extractImageURL(doc, cfg, pageURL)
+	applyStructuredData(extractStructuredData(doc), cfg, pageURL, &product)
+
+	if cfg.QACountSelector != "" {
+		text := strings.TrimSpace(doc.Find(cfg.QACountSelector).First().Text())
+		if count, ok := parseCount(text); ok {
+			product.QACount = &count
+		} else if text != "" {
+			slog.Debug("Unparseable Q&A count", "url", pageURL, "text", text)
+		}
+	}
+	return product
+}
+
+// --- Apply Structured Data Over Selector Results ---
+// Name and price from structured data replace the selector results, since
+// markup meant for search engines is more reliable than visible text; the
+// member price still comes from its selector. Image and brand only fill
+// gaps, as configured selectors pick them deliberately.
+func applyStructuredData(sd structuredProduct, cfg DomainConfig, pageURL string, product *Product) {
+	if sd.Name != "" {
+		product.Title = sd.Name
+	}
+	if sd.Price != nil {
+		product.Price, product.PriceRaw = sd.Price, sd.PriceRaw
+		_, region, _ := strings.Cut(cfg.Locale, "-")
+		product.Currency = priceCurrency("", cfg.Currency, strings.ToUpper(region))
+		if sd.Currency != "" && cfg.Currency == "" {
+			product.Currency = strings.ToUpper(sd.Currency)
+		}
+		product.PriceUnparsed = product.MemberPriceRaw != "" && product.MemberPrice == nil
+	}
+	if product.Brand == "" {
+		product.Brand = sd.Brand
+	}
+	if product.SKU == "" {
+		product.SKU = sd.SKU
+	}
+	if product.ImageURL == "" && sd.Image != "" {
+		product.ImageURL = resolveURL(pageURL, sd.Image)
+	}
+	product.Availability = sd.Availability
+	product.Rating = sd.Rating
+	product.ReviewCount = sd.ReviewCount
+}
+
+// --- Canonical Link of a Page ---
+// Faceted navigation reaches one product under many URLs; the page's
+// <link rel="canonical"> names the one to store it under. A canonical
+// pointing to another site is ignored.
+func canonicalLink(doc *goquery.Document, pageURL string) string {
+	href := strings.TrimSpace(doc.Find(`link[rel="canonical"]`).First().AttrOr("href", ""))
+	if href == "" {
+		return ""
+	}
+	canonical := canonicalURL(resolveURL(pageURL, href))
+	if !strings.HasPrefix(canonical, "http") || registrableDomain(urlHost(canonical)) != registrableDomain(urlHost(pageURL)) {
+		slog.Debug("Ignoring off-site canonical link", "url", pageURL, "canonical", href)
+		return ""
+	}
+	return canonical
+}
+
+// --- Extract Coupon Codes ---
+// Codes are upper-cased and de-duplicated in page order.
+func extractCoupons(doc *goquery.Document, cfg DomainConfig) []string {
+	selection := doc.Find("body")
+	if cfg.CouponSelector != "" {
+		selection = doc.Find(cfg.CouponSelector)
+	}
+	pattern := cfg.couponPattern()
+
+	var coupons []string
+	seen := make(map[string]bool)
+	selection.Each(func(_ int, s *goquery.Selection) {
+		for _, match := range pattern.FindAllStringSubmatch(s.Text(), -1) {
+			code := match[0]
+			if len(match) > 1 {
+				code = match[1]
+			}
+			code = strings.ToUpper(strings.TrimSpace(code))
+			if code != "" && !seen[code] {
+				seen[code] = true
+				coupons = append(coupons, code)
+			}
+		}
+	})
+	return coupons
+}
+
+// --- Parse the First Integer in a Text ---
+// Thousands separators are ignored, so "1,234" and "1.234" both yield 1234.
+func parseCount(text string) (int, bool) {
+	match := countPattern.FindString(text)
+	if match == "" {
+		return 0, false
+	}
+	digits := strings.NewReplacer(",", "", ".", "").Replace(match)
+	count, err := strconv.Atoi(digits)
+	if err != nil {
+		return 0, false
+	}
+	return count, true
+}
+
+// --- Visit Product Pages and Extract Details ---
+func (c *Crawler) fetchProductDetails(ctx context.Context, productURLs []string) []Product {
+	var products []Product
+	for _, productURL := range productURLs {
+		if ctx.Err() != nil || !c.limits.TakePage(urlHost(productURL)) {
+			break
+		}
+		htmlContent, finalURL, err := c.fetchPage(ctx, productURL, "", false)
+		if errors.Is(err, errRobotsDisallowed) {
+			slog.Info("Skipping product page disallowed by robots.txt", "url", productURL)
+			c.audit.Info("page_skipped", "url", productURL, "reason", "robots")
+			continue
+		}
+		if err != nil {
+			slog.Warn("Failed to load product page", "url", productURL, "error", err)
+			c.stats.Error(urlHost(productURL))
+			c.audit.Error("page_failed", "url", productURL, "error", err.Error())
+			continue
+		}
+		c.stats.PageVisited(urlHost(productURL))
+		c.audit.Info("page_loaded", "url", productURL, "final_url", finalURL)
+		product, ok := registeredProductDetails(htmlContent, finalURL)
+		if !ok {
+			host := urlHost(finalURL)
+			product = adapterFor(host).ExtractProduct(htmlContent, finalURL, c.config.forHost(host))
+		}
+		if product.URL != productURL {
+			product.RequestedURL = productURL
+		}
+		// URLs that canonicalize to a product already extracted this run
+		// are the same product.
+		if first, loaded := c.canonicals.LoadOrStore(product.URL, productURL); loaded {
+			slog.Debug("Duplicate product content", "url", productURL, "canonical", product.URL, "first", first)
+			continue
+		}
+		products = append(products, product)
+	}
+	return products
+}
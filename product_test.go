@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+// readFixture returns a saved page from testdata.
+func readFixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestExtractProductDetailsQACount(t *testing.T) {
+	page := readFixture(t, "product-qa.html")
+	pageURL := "https://www.amazon.in/dp/B0AAAAAAAA/"
+
+	product := extractProductDetails(page, pageURL, DomainConfig{QACountSelector: "#askATFLink span"})
+	if product.QACount == nil || *product.QACount != 1234 {
+		t.Errorf("QACount = %v, want 1234", product.QACount)
+	}
+
+	for name, cfg := range map[string]DomainConfig{
+		"no selector":        {},
+		"selector not found": {QACountSelector: "#answered-questions"},
+	} {
+		if product := extractProductDetails(page, pageURL, cfg); product.QACount != nil {
+			t.Errorf("%s: QACount = %d, want nil", name, *product.QACount)
+		}
+	}
+}
+
+func TestExtractProductDetailsCoupons(t *testing.T) {
+	page := readFixture(t, "product-coupons.html")
+	pageURL := "https://www.myntra.com/shirts/roadster/slim-fit-shirt/123456/buy"
+	tests := []struct {
+		name string
+		cfg  DomainConfig
+		want []string
+	}{
+		{"whole page", DomainConfig{}, []string{"SAVE10", "FESTIVE20", "NEWS15"}},
+		{"selector", DomainConfig{CouponSelector: ".pdp-offers .offer"}, []string{"SAVE10", "FESTIVE20"}},
+		{"custom pattern", DomainConfig{CouponSelector: ".offer", coupon: regexp.MustCompile(`(?i)promo:\s*(\w+)`)}, []string{"DIWALI5"}},
+		{"no match", DomainConfig{CouponSelector: "h1"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractProductDetails(page, pageURL, tt.cfg).Coupons; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Coupons = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractProductDetailsMemberPrice(t *testing.T) {
+	cfg := DomainConfig{PriceSelector: ".price-public", MemberPriceSelector: ".price-member .amount", Locale: "en-IN"}
+	pageURL := "https://www.example.in/product/organic-almonds-1kg"
+	tests := []struct {
+		fixture            string
+		price, memberPrice *float64
+	}{
+		{"product-member-price.html", ptr(1299.0), ptr(1049.0)},
+		{"product-public-price.html", ptr(1299.0), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			product := extractProductDetails(readFixture(t, tt.fixture), pageURL, cfg)
+			if !samePrice(product.Price, tt.price) {
+				t.Errorf("Price = %v, want %v", deref(product.Price), deref(tt.price))
+			}
+			if !samePrice(product.MemberPrice, tt.memberPrice) {
+				t.Errorf("MemberPrice = %v, want %v", deref(product.MemberPrice), deref(tt.memberPrice))
+			}
+			if product.Currency != "INR" || product.PriceUnparsed {
+				t.Errorf("Currency = %q, unparsed = %v; want INR and parsed", product.Currency, product.PriceUnparsed)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+// deref prints an optional amount as a number or <nil>.
+func deref(amount *float64) any {
+	if amount == nil {
+		return nil
+	}
+	return *amount
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	httppprof "net/http/pprof"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// --- Profiling ---
+// -pprof-addr serves the live net/http/pprof endpoints while the crawl runs;
+// -cpuprofile and -memprofile write profile files covering the crawl
+// itself, from its start until results are saved. Nothing is started when
+// the flags are unset.
+type profiler struct {
+	cpuFile *os.File // open while the CPU profile runs; nil without -cpuprofile
+	memPath string
+}
+
+// --- Start Profiling ---
+// A nil profiler is returned when no profiling was requested.
+func startProfiling(addr, cpuPath, memPath string) (*profiler, error) {
+	if addr != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/debug/pprof/", httppprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", httppprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", httppprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", httppprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", httppprof.Trace)
+		go func() {
+			if err := http.ListenAndServe(addr, mux); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("pprof server stopped", "addr", addr, "error", err)
+			}
+		}()
+		slog.Info("pprof server listening", "url", "http://"+addr+"/debug/pprof/")
+	}
+	if cpuPath == "" && memPath == "" {
+		return nil, nil
+	}
+
+	p := &profiler{memPath: memPath}
+	if cpuPath != "" {
+		file, err := os.Create(cpuPath)
+		if err != nil {
+			return nil, fmt.Errorf("create CPU profile: %w", err)
+		}
+		if err := pprof.StartCPUProfile(file); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("start CPU profile: %w", err)
+		}
+		p.cpuFile = file
+	}
+	return p, nil
+}
+
+// --- Stop Profiling and Write the Profiles ---
+// The heap profile follows a GC so it reflects live memory only. Failures
+// are logged; profiling never fails the run.
+func (p *profiler) Stop() {
+	if p == nil {
+		return
+	}
+	if p.cpuFile != nil {
+		pprof.StopCPUProfile()
+		if err := p.cpuFile.Close(); err != nil {
+			slog.Error("Failed to write CPU profile", "path", p.cpuFile.Name(), "error", err)
+		} else {
+			slog.Info("CPU profile saved", "path", p.cpuFile.Name())
+		}
+	}
+	if p.memPath != "" {
+		if err := writeHeapProfile(p.memPath); err != nil {
+			slog.Error("Failed to write memory profile", "path", p.memPath, "error", err)
+		} else {
+			slog.Info("Memory profile saved", "path", p.memPath)
+		}
+	}
+}
+
+func writeHeapProfile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
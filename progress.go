@@ -0,0 +1,62 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+)
+
+// --- No-Progress Stop Condition ---
+// Deep pagination and endless category pages keep yielding pages without
+// any product URL the run has not already kept. With -stop-after-empty N,
+// a host whose last N listing pages (seed pages, queued listings and
+// pagination clicks alike) produced no new product URL is stopped for the
+// rest of the run. Progress is tracked per host; 0 disables the check.
+type progressTracker struct {
+	mu      sync.Mutex
+	limit   int
+	empty   map[string]int  // consecutive pages without a new URL
+	stopped map[string]bool // hosts given up on
+}
+
+// newProgressTracker returns nil, which never stops a host, when limit is 0.
+func newProgressTracker(limit int) *progressTracker {
+	if limit <= 0 {
+		return nil
+	}
+	return &progressTracker{limit: limit, empty: make(map[string]int), stopped: make(map[string]bool)}
+}
+
+// --- Record a Listing Page ---
+// newURLs counts the page's product URLs left after dedup. Reports whether
+// host is now stopped. A nil tracker never stops a host.
+func (p *progressTracker) Record(host string, newURLs int) bool {
+	if p == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped[host] {
+		return true
+	}
+	if newURLs > 0 {
+		p.empty[host] = 0
+		return false
+	}
+	p.empty[host]++
+	if p.empty[host] < p.limit {
+		return false
+	}
+	p.stopped[host] = true
+	slog.Warn("No new product URLs on recent pages, stopping domain", "host", host, "pages", p.empty[host])
+	return true
+}
+
+// --- Whether a Host Was Stopped ---
+func (p *progressTracker) Stopped(host string) bool {
+	if p == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stopped[host]
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// A proxy is dropped from the pool after this many consecutive failed
+// page loads; any success resets its count.
+const proxyMaxFailures = 3
+
+// --- Rotating Proxy Pool ---
+// Proxies come from -proxies, falling back to the PROXIES environment
+// variable and then the config's "proxies" list. Each page load takes the
+// next proxy in turn; with -proxy-sticky a registrable domain keeps its
+// proxy, and so its cookies and session, until that proxy is dropped.
+// Region retries pick their own proxy and bypass the pool. A nil pool
+// loads pages directly.
+type proxyPool struct {
+	mu       sync.Mutex
+	proxies  []string // still in rotation
+	next     int
+	sticky   bool
+	assigned map[string]string // registrable domain -> proxy, with sticky
+	failures map[string]int    // consecutive failures per proxy
+}
+
+// --- Gather Proxies From Flag, Environment or Config ---
+func proxyList(flagValue string, config *Config) []string {
+	list := flagValue
+	if list == "" {
+		list = os.Getenv("PROXIES")
+	}
+	if list == "" {
+		return config.Proxies
+	}
+	var proxies []string
+	for _, proxy := range strings.Split(list, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// newProxyPool returns nil when proxies is empty. Every proxy must be a
+// URL with a scheme, e.g. "http://10.0.0.1:3128" or "socks5://host:1080".
+func newProxyPool(proxies []string, sticky bool) (*proxyPool, error) {
+	if len(proxies) == 0 {
+		return nil, nil
+	}
+	for _, proxy := range proxies {
+		if parsed, err := url.Parse(proxy); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return nil, fmt.Errorf("invalid proxy %q: want scheme://host:port", proxy)
+		}
+	}
+	return &proxyPool{
+		proxies:  append([]string(nil), proxies...),
+		sticky:   sticky,
+		assigned: make(map[string]string),
+		failures: make(map[string]int),
+	}, nil
+}
+
+// --- Pick a Proxy for the Next Page Load ---
+// Returns "" once every proxy has been dropped, so the crawl goes on
+// without one.
+func (p *proxyPool) Pick(host string) string {
+	if p == nil {
+		return ""
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.proxies) == 0 {
+		return ""
+	}
+	domain := registrableDomain(host)
+	if proxy, ok := p.assigned[domain]; ok {
+		return proxy
+	}
+	proxy := p.proxies[p.next%len(p.proxies)]
+	p.next++
+	if p.sticky {
+		p.assigned[domain] = proxy
+	}
+	return proxy
+}
+
+// --- Record the Outcome of a Page Load ---
+// Cancellations and robots.txt refusals say nothing about the proxy and
+// are ignored.
+func (p *proxyPool) Report(proxy string, err error) {
+	if p == nil || proxy == "" {
+		return
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, errRobotsDisallowed) {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err == nil {
+		delete(p.failures, proxy)
+		return
+	}
+	p.failures[proxy]++
+	if p.failures[proxy] < proxyMaxFailures {
+		return
+	}
+
+	for i, candidate := range p.proxies {
+		if candidate == proxy {
+			p.proxies = append(p.proxies[:i], p.proxies[i+1:]...)
+			break
+		}
+	}
+	delete(p.failures, proxy)
+	for domain, assigned := range p.assigned {
+		if assigned == proxy {
+			delete(p.assigned, domain)
+		}
+	}
+	slog.Warn("Dropping failing proxy", "proxy", proxy, "failures", proxyMaxFailures, "remaining", len(p.proxies), "error", err)
+	if len(p.proxies) == 0 {
+		slog.Warn("Every proxy has been dropped, loading pages directly")
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// --- Distributed Work Queue ---
+// With -queue, crawler instances on any number of machines share one
+// frontier in Redis instead of each crawling its own seed list:
+//
+//	<name>:pending  list of jobs waiting for a worker
+//	<name>:leased   sorted set of jobs being worked on, scored by lease expiry
+//	<name>:queued   set of URLs enqueued recently, so a URL is queued once
+//
+// Popping a job and leasing it is one Lua script, so no two workers get the
+// same job. A finished job leaves the lease set; a job whose worker died is
+// put back on pending once its lease expires. Seeds are listing jobs, as
+// are the category pages followed from them with -depth; with
+// -product-details, the product URLs they surface become product jobs
+// that any instance may pick up.
+const (
+	queueLease        = 5 * time.Minute
+	queuePollInterval = time.Second
+)
+
+const (
+	jobListing = "listing"
+	jobProduct = "product"
+)
+
+type queueJob struct {
+	Kind  string `json:"kind"`
+	URL   string `json:"url"`
+	Seed  string `json:"seed,omitempty"`
+	Depth int    `json:"depth,omitempty"` // links followed from the seed; see frontier.go
+}
+
+// popAndLease moves the oldest pending job into the lease set.
+var popAndLease = redis.NewScript(`
+local job = redis.call('RPOP', KEYS[1])
+if job then
+	redis.call('ZADD', KEYS[2], ARGV[1], job)
+end
+return job
+`)
+
+type workQueue struct {
+	client  *redis.Client
+	pending string
+	leased  string
+	queued  string
+}
+
+func newWorkQueue(client *redis.Client, name string) *workQueue {
+	return &workQueue{
+		client:  client,
+		pending: name + ":pending",
+		leased:  name + ":leased",
+		queued:  name + ":queued",
+	}
+}
+
+// --- Enqueue Jobs ---
+// URLs already enqueued by any instance are skipped.
+func (q *workQueue) Push(ctx context.Context, jobs ...queueJob) error {
+	for _, job := range jobs {
+		added, err := q.client.SAdd(ctx, q.queued, job.URL).Result()
+		if err != nil {
+			return err
+		}
+		if added == 0 {
+			continue
+		}
+		data, err := json.Marshal(job)
+		if err != nil {
+			return err
+		}
+		if err := q.client.LPush(ctx, q.pending, data).Err(); err != nil {
+			return err
+		}
+	}
+	// Like visited marks, the enqueued set expires so later runs can
+	// queue the same seeds again.
+	return q.client.Expire(ctx, q.queued, redisExpiry).Err()
+}
+
+// --- Lease the Next Job ---
+// ok is false when nothing is pending.
+func (q *workQueue) Pop(ctx context.Context) (job queueJob, raw string, ok bool, err error) {
+	deadline := float64(time.Now().Add(queueLease).Unix())
+	raw, err = popAndLease.Run(ctx, q.client, []string{q.pending, q.leased}, deadline).Text()
+	if errors.Is(err, redis.Nil) {
+		return job, "", false, nil
+	}
+	if err != nil {
+		return job, "", false, err
+	}
+	if err := json.Unmarshal([]byte(raw), &job); err != nil {
+		q.Done(ctx, raw)
+		return job, "", false, err
+	}
+	return job, raw, true, nil
+}
+
+// --- Release a Finished Job ---
+func (q *workQueue) Done(ctx context.Context, raw string) {
+	if err := q.client.ZRem(ctx, q.leased, raw).Err(); err != nil {
+		slog.Warn("Failed to release queue job", "job", raw, "error", err)
+	}
+}
+
+// --- Requeue Jobs with Expired Leases ---
+// ZREM decides which instance requeues a job, so it is requeued once.
+func (q *workQueue) RequeueExpired(ctx context.Context) error {
+	now := time.Now().Unix()
+	expired, err := q.client.ZRangeByScore(ctx, q.leased, &redis.ZRangeBy{Min: "-inf", Max: strconv.FormatInt(now, 10)}).Result()
+	if err != nil {
+		return err
+	}
+	for _, raw := range expired {
+		removed, err := q.client.ZRem(ctx, q.leased, raw).Result()
+		if err != nil {
+			return err
+		}
+		if removed == 1 {
+			slog.Warn("Queue lease expired, requeueing job", "job", raw)
+			if err := q.client.RPush(ctx, q.pending, raw).Err(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// --- Check for Remaining Work ---
+// The queue is drained when nothing is pending and nothing is leased; a
+// leased job may still surface new jobs.
+func (q *workQueue) Drained(ctx context.Context) (bool, error) {
+	pending, err := q.client.LLen(ctx, q.pending).Result()
+	if err != nil {
+		return false, err
+	}
+	leased, err := q.client.ZCard(ctx, q.leased).Result()
+	if err != nil {
+		return false, err
+	}
+	return pending == 0 && leased == 0, nil
+}
+
+// --- Crawl from the Shared Queue ---
+// The seeds are enqueued (a no-op for seeds another instance already
+// queued), then workers lease jobs until the queue has been drained for
+// idleTimeout. Each instance returns the results of the jobs it ran.
+func (c *Crawler) crawlQueue(ctx context.Context, seeds []string, workers int, idleTimeout time.Duration) []CrawlResult {
+	jobs := make([]queueJob, 0, len(seeds))
+	for _, seed := range seeds {
+		jobs = append(jobs, queueJob{Kind: jobListing, URL: seed, Seed: seed})
+	}
+	if err := c.queue.Push(ctx, jobs...); err != nil {
+		slog.Error("Failed to enqueue seeds", "error", err)
+	}
+
+	reaperCtx, stopReaper := context.WithCancel(ctx)
+	defer stopReaper()
+	go func() {
+		ticker := time.NewTicker(queueLease / 4)
+		defer ticker.Stop()
+		for {
+			if err := c.queue.RequeueExpired(reaperCtx); err != nil && reaperCtx.Err() == nil {
+				slog.Warn("Failed to requeue expired jobs", "error", err)
+			}
+			select {
+			case <-reaperCtx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	var mu sync.Mutex
+	var results []CrawlResult
+	productsByHost := make(map[string]int) // index into results of each host's product result
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			idleSince := time.Time{}
+			for ctx.Err() == nil {
+				job, raw, ok, err := c.queue.Pop(ctx)
+				if err != nil {
+					slog.Warn("Failed to lease queue job", "error", err)
+				}
+				if !ok {
+					if c.queueDrained(ctx, &idleSince, idleTimeout) {
+						return
+					}
+					sleepCtx(ctx, queuePollInterval)
+					continue
+				}
+				idleSince = time.Time{}
+
+				result, isProduct := c.runJob(ctx, job)
+				if ctx.Err() != nil {
+					// Interrupted mid-job: the lease expires and another
+					// worker redoes the job.
+					return
+				}
+				c.queue.Done(ctx, raw)
+				if result == nil {
+					continue
+				}
+				mu.Lock()
+				if idx, seen := productsByHost[result.Domain]; isProduct && seen {
+					results[idx].URLs = append(results[idx].URLs, result.URLs...)
+					results[idx].Products = append(results[idx].Products, result.Products...)
+				} else {
+					if isProduct {
+						productsByHost[result.Domain] = len(results)
+					}
+					results = append(results, *result)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	slog.Info("Work queue drained", "results", len(results))
+	return results
+}
+
+// --- Run One Queue Job ---
+// Product jobs are grouped by host in the results, like the fetch phase.
+func (c *Crawler) runJob(ctx context.Context, job queueJob) (*CrawlResult, bool) {
+	switch job.Kind {
+	case jobListing:
+		resultChan := make(chan CrawlResult, 1)
+		var wg sync.WaitGroup
+		wg.Add(1)
+		c.scrapeWebsite(ctx, job, resultChan, &wg)
+		select {
+		case res := <-resultChan:
+			return &res, false
+		default:
+			return nil, false
+		}
+	case jobProduct:
+		host := urlHost(job.URL)
+		products := c.fetchProductDetails(ctx, []string{job.URL})
+		if c.dryRun {
+			slog.Info("Dry run: would store product details", "url", job.URL, "products", len(products))
+		} else if err := c.store.SaveProducts(ctx, products); err != nil {
+			slog.Error("Failed to store product details", "url", job.URL, "error", err)
+			c.stats.Error(host)
+		}
+		return &CrawlResult{Domain: host, URLs: []string{job.URL}, Products: products}, true
+	default:
+		slog.Warn("Unknown queue job", "kind", job.Kind, "url", job.URL)
+		return nil, false
+	}
+}
+
+// --- Enqueue Product Jobs ---
+func (c *Crawler) enqueueProducts(ctx context.Context, urls []string, seed string) {
+	jobs := make([]queueJob, 0, len(urls))
+	for _, url := range urls {
+		jobs = append(jobs, queueJob{Kind: jobProduct, URL: url, Seed: seed})
+	}
+	if err := c.queue.Push(ctx, jobs...); err != nil {
+		slog.Error("Failed to enqueue product URLs", "seed", seed, "error", err)
+	}
+}
+
+// --- Decide Whether a Worker Should Stop ---
+// A worker stops once the queue has stayed drained for idleTimeout; until
+// then other instances may still be adding work.
+func (c *Crawler) queueDrained(ctx context.Context, idleSince *time.Time, idleTimeout time.Duration) bool {
+	drained, err := c.queue.Drained(ctx)
+	if err != nil || !drained {
+		*idleSince = time.Time{}
+		return false
+	}
+	if idleSince.IsZero() {
+		*idleSince = time.Now()
+	}
+	return time.Since(*idleSince) >= idleTimeout
+}
+
+// --- Sleep Unless Cancelled ---
+func sleepCtx(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- Rate-Limit Header Throttling ---
+// Once a host's advertised X-RateLimit-Remaining drops below this many
+// requests, the remaining quota is spread evenly until X-RateLimit-Reset.
+const rateLimitLowWater = 10
+
+// --- 429 Too Many Requests ---
+// A 429 pauses the host for its Retry-After, or for a backoff doubling
+// from tooManyRequestsBackoff with every consecutive 429, whichever is
+// longer. Any other response ends the streak. The page is retried up to
+// tooManyRequestsRetries times once the pause is over.
+const (
+	tooManyRequestsBackoff    = 5 * time.Second
+	tooManyRequestsBackoffMax = 5 * time.Minute
+	tooManyRequestsRetries    = 3
+)
+
+// errTooManyRequests marks a page the server answered with 429.
+var errTooManyRequests = errors.New("429 Too Many Requests")
+
+// rateLimitThrottle paces requests per host: after a 429 always, and
+// according to the quota the server advertises with -ratelimit-headers.
+// A nil throttle never waits.
+type rateLimitThrottle struct {
+	mu           sync.Mutex
+	quotaHeaders bool
+	hosts        map[string]rateLimitState
+	pauses       map[string]rateLimitPause
+}
+
+type rateLimitState struct {
+	remaining int
+	reset     time.Time
+}
+
+type rateLimitPause struct {
+	until   time.Time
+	strikes int // consecutive 429 responses
+}
+
+func newRateLimitThrottle(quotaHeaders bool) *rateLimitThrottle {
+	return &rateLimitThrottle{
+		quotaHeaders: quotaHeaders,
+		hosts:        make(map[string]rateLimitState),
+		pauses:       make(map[string]rateLimitPause),
+	}
+}
+
+// --- Record a Response ---
+// A 429 starts or extends the host's pause. Other responses end a 429
+// streak and, with quota headers enabled, record the advertised quota;
+// responses without both headers leave the quota untouched.
+func (t *rateLimitThrottle) Observe(host string, status int, headers http.Header) {
+	if t == nil {
+		return
+	}
+	if status == http.StatusTooManyRequests {
+		t.tooManyRequests(host, headers)
+		return
+	}
+
+	t.mu.Lock()
+	delete(t.pauses, host)
+	t.mu.Unlock()
+	if !t.quotaHeaders {
+		return
+	}
+	state, ok := parseRateLimitHeaders(headers, time.Now())
+	if !ok {
+		return
+	}
+
+	t.mu.Lock()
+	t.hosts[host] = state
+	t.mu.Unlock()
+	slog.Debug("Rate limit observed", "host", host, "remaining", state.remaining, "reset", state.reset)
+}
+
+func (t *rateLimitThrottle) tooManyRequests(host string, headers http.Header) {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	pause := t.pauses[host]
+	pause.strikes++
+	delay := min(tooManyRequestsBackoff<<(pause.strikes-1), tooManyRequestsBackoffMax)
+	if retryAfter, ok := parseRetryAfter(headers.Get("Retry-After"), now); ok && retryAfter > delay {
+		delay = retryAfter
+	}
+	if until := now.Add(delay); until.After(pause.until) {
+		pause.until = until
+	}
+	t.pauses[host] = pause
+	slog.Warn("Too many requests, pausing host", "host", host, "strikes", pause.strikes, "wait", delay)
+}
+
+// --- Wait Before the Next Request to a Host ---
+func (t *rateLimitThrottle) Wait(ctx context.Context, host string) error {
+	if t == nil {
+		return nil
+	}
+	now := time.Now()
+	t.mu.Lock()
+	state, ok := t.hosts[host]
+	pause := t.pauses[host]
+	t.mu.Unlock()
+
+	delay := pause.until.Sub(now)
+	if ok {
+		if quotaDelay := state.delay(now); quotaDelay > delay {
+			delay = quotaDelay
+			if state.remaining <= 0 {
+				slog.Info("Rate limit exhausted, pausing until reset", "host", host, "wait", delay)
+			} else {
+				slog.Debug("Slowing down for rate limit", "host", host, "remaining", state.remaining, "wait", delay)
+			}
+		}
+	}
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// --- Delay Implied by a Quota ---
+// Exhausted quotas wait for the reset; low quotas share the time left until
+// the reset between the remaining requests.
+func (s rateLimitState) delay(now time.Time) time.Duration {
+	untilReset := s.reset.Sub(now)
+	if untilReset <= 0 {
+		return 0
+	}
+	switch {
+	case s.remaining <= 0:
+		return untilReset
+	case s.remaining < rateLimitLowWater:
+		return untilReset / time.Duration(s.remaining+1)
+	}
+	return 0
+}
+
+// --- Parse X-RateLimit-* Headers ---
+// Reset may be seconds until the reset or a Unix timestamp; values large
+// enough to be a timestamp are treated as one.
+func parseRateLimitHeaders(headers http.Header, now time.Time) (rateLimitState, bool) {
+	remainingValue := headers.Get("X-RateLimit-Remaining")
+	resetValue := headers.Get("X-RateLimit-Reset")
+	if remainingValue == "" || resetValue == "" {
+		return rateLimitState{}, false
+	}
+
+	remaining, err := strconv.Atoi(strings.TrimSpace(remainingValue))
+	if err != nil {
+		return rateLimitState{}, false
+	}
+	reset, err := strconv.ParseFloat(strings.TrimSpace(resetValue), 64)
+	if err != nil || reset < 0 {
+		return rateLimitState{}, false
+	}
+
+	state := rateLimitState{remaining: remaining}
+	if reset > 1e9 {
+		state.reset = time.Unix(int64(reset), 0)
+	} else {
+		state.reset = now.Add(time.Duration(reset * float64(time.Second)))
+	}
+	return state, true
+}
+
+// --- Parse Retry-After ---
+// The header is either delay seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// --- Convert CDP Headers to http.Header ---
+func cdpHeaders(headers map[string]any) http.Header {
+	converted := make(http.Header, len(headers))
+	for name, value := range headers {
+		if s, ok := value.(string); ok {
+			converted.Set(name, s)
+		}
+	}
+	return converted
+}
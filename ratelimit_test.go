@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func quotaHeaders(remaining, reset string) http.Header {
+	headers := make(http.Header)
+	headers.Set("X-RateLimit-Remaining", remaining)
+	headers.Set("X-RateLimit-Reset", reset)
+	return headers
+}
+
+func TestRateLimitStateDelay(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		remaining int
+		reset     string
+		want      time.Duration
+	}{
+		{"plenty left", 100, "60", 0},
+		{"at the low-water mark", rateLimitLowWater, "60", 0},
+		{"low quota shares the window", 3, "60", 15 * time.Second},
+		{"last request", 1, "60", 30 * time.Second},
+		{"exhausted waits for reset", 0, "60", time.Minute},
+		{"reset as a Unix timestamp", 0, "4102444800", time.Unix(4102444800, 0).Sub(now)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, ok := parseRateLimitHeaders(quotaHeaders(strconv.Itoa(tt.remaining), tt.reset), now)
+			if !ok {
+				t.Fatal("headers not parsed")
+			}
+			if got := state.delay(now); got != tt.want {
+				t.Errorf("delay = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for name, headers := range map[string]http.Header{
+		"no headers":     {},
+		"remaining only": {"X-Ratelimit-Remaining": {"5"}},
+		"bad reset":      quotaHeaders("5", "soon"),
+	} {
+		if _, ok := parseRateLimitHeaders(headers, now); ok {
+			t.Errorf("%s: parsed, want ignored", name)
+		}
+	}
+}
+
+func TestRateLimitThrottleSlowsAndPauses(t *testing.T) {
+	const host = "www.snapdeal.com"
+	ctx := context.Background()
+
+	throttle := newRateLimitThrottle(true)
+	throttle.Observe(host, http.StatusOK, quotaHeaders("50", "1"))
+	if elapsed := timeWait(t, ctx, throttle, host); elapsed > 50*time.Millisecond {
+		t.Errorf("plenty of quota: waited %v, want no wait", elapsed)
+	}
+
+	throttle.Observe(host, http.StatusOK, quotaHeaders("3", "0.4"))
+	if elapsed := timeWait(t, ctx, throttle, host); elapsed < 80*time.Millisecond || elapsed > 300*time.Millisecond {
+		t.Errorf("low quota: waited %v, want about 100ms", elapsed)
+	}
+
+	throttle.Observe(host, http.StatusOK, quotaHeaders("0", "0.3"))
+	if elapsed := timeWait(t, ctx, throttle, host); elapsed < 250*time.Millisecond {
+		t.Errorf("exhausted quota: waited %v, want the 300ms until reset", elapsed)
+	}
+	if elapsed := timeWait(t, ctx, throttle, "www.myntra.com"); elapsed > 50*time.Millisecond {
+		t.Errorf("other host waited %v, want no wait", elapsed)
+	}
+
+	throttle.Observe(host, http.StatusOK, quotaHeaders("0", "60"))
+	short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := throttle.Wait(short, host); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait past the deadline = %v, want context.DeadlineExceeded", err)
+	}
+
+	ignoring := newRateLimitThrottle(false)
+	ignoring.Observe(host, http.StatusOK, quotaHeaders("0", "60"))
+	if elapsed := timeWait(t, ctx, ignoring, host); elapsed > 50*time.Millisecond {
+		t.Errorf("quota headers off: waited %v, want no wait", elapsed)
+	}
+}
+
+func timeWait(t *testing.T, ctx context.Context, throttle *rateLimitThrottle, host string) time.Duration {
+	t.Helper()
+	start := time.Now()
+	if err := throttle.Wait(ctx, host); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	return time.Since(start)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// --- Reconnect on Transient Connection Loss ---
+// A reconnector retries Redis and Postgres operations that failed because
+// the connection dropped, or because Postgres aborted a transaction that
+// may succeed when repeated. Both clients pool their connections and dial
+// again on the next call, so reconnecting means waiting until the backend
+// answers a health check and then repeating the operation. Running out of
+// attempts returns an errUnreachable error; the caller buffers its writes
+// or stops the run, since carrying on would silently re-crawl visited
+// pages or drop stored URLs.
+const (
+	reconnectBackoffMin = 500 * time.Millisecond
+	reconnectBackoffMax = 10 * time.Second
+)
+
+type reconnector struct {
+	backend  string // "Redis" or "database", for logs
+	attempts int
+	ping     func(ctx context.Context) error
+}
+
+func newReconnector(backend string, attempts int, ping func(ctx context.Context) error) *reconnector {
+	return &reconnector{backend: backend, attempts: attempts, ping: ping}
+}
+
+// --- Run an Operation, Reconnecting as Needed ---
+// Permanent errors are returned unchanged. A nil
+// reconnector runs op once. Cancelling ctx stops reconnecting and returns
+// ctx.Err(); a shutdown is not an unreachable backend.
+func (r *reconnector) Do(ctx context.Context, op func() error) error {
+	err := op()
+	if r == nil || err == nil || ctx.Err() != nil || !isTransientError(err) {
+		return err
+	}
+
+	backoff := reconnectBackoffMin
+	for attempt := 1; attempt <= r.attempts; attempt++ {
+		slog.Warn("Transient backend error, retrying", "backend", r.backend, "attempt", attempt, "error", err)
+		sleepCtx(ctx, backoff)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		backoff = min(2*backoff, reconnectBackoffMax)
+
+		pingCtx, cancel := context.WithTimeout(ctx, reconnectBackoffMax)
+		pingErr := r.ping(pingCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if pingErr != nil {
+			err = pingErr
+			continue
+		}
+		if err = op(); err == nil || !isTransientError(err) {
+			slog.Info("Reconnected", "backend", r.backend, "attempt", attempt)
+			return err
+		}
+	}
+	return fmt.Errorf("%s %w: gave up after %d reconnect attempts: %w", r.backend, errUnreachable, r.attempts, err)
+}
+
+// --- Classify Transient Errors ---
+// Serialization failures and deadlocks roll the transaction back, so the
+// operation can be repeated as a whole.
+func isTransientError(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && (pgErr.Code == "40001" || pgErr.Code == "40P01") {
+		return true
+	}
+	return isConnectionError(err)
+}
+
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE)
+}
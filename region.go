@@ -0,0 +1,101 @@
+package main
+
+import (
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+// --- Default Region-Block Markers ---
+// Phrases retailers commonly show instead of content when a visitor's
+// location is not served. Matched case-insensitively against the page HTML.
+const defaultRegionMarkers = "not available in your region,not available in your country," +
+	"not available in your location,not available in your area,currently unavailable in your country"
+
+// --- Region Handling State ---
+var (
+	regionMarkers []string
+	regionProxies map[string][]string // region -> proxy servers
+	siteRegions   map[string][]string // host -> regions the site serves
+)
+
+// --- Parse Region-Block Markers ---
+func parseRegionMarkers(list string) []string {
+	var markers []string
+	for _, marker := range strings.Split(list, ",") {
+		if marker = strings.ToLower(strings.TrimSpace(marker)); marker != "" {
+			markers = append(markers, marker)
+		}
+	}
+	return markers
+}
+
+// --- Parse key=value Region Lists ---
+// Entries look like "us=http://10.0.0.1:3128,in=socks5://10.0.0.2:1080";
+// a key may repeat and values may also be separated with "|".
+func parseRegionMap(list string) map[string][]string {
+	result := make(map[string][]string)
+	for _, entry := range strings.Split(list, ",") {
+		key, values, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			if entry != "" {
+				slog.Warn("Ignoring malformed region entry", "entry", entry)
+			}
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		for _, value := range strings.Split(values, "|") {
+			if value = strings.TrimSpace(value); value != "" {
+				result[key] = append(result[key], value)
+			}
+		}
+	}
+	return result
+}
+
+// --- Detect Region-Restricted Pages ---
+func isRegionBlocked(htmlContent string) bool {
+	if len(regionMarkers) == 0 {
+		return false
+	}
+	lower := strings.ToLower(htmlContent)
+	for _, marker := range regionMarkers {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// --- Pick Proxies Able to Reach a Host ---
+// When the host has configured regions only proxies in those regions are
+// returned; otherwise every region proxy is a candidate.
+func regionProxiesFor(host string) []string {
+	host = strings.ToLower(host)
+	regions, ok := siteRegions[host]
+	if !ok {
+		for h, r := range siteRegions {
+			if strings.HasSuffix(host, "."+h) {
+				regions, ok = r, true
+				break
+			}
+		}
+	}
+
+	var proxies []string
+	if ok {
+		for _, region := range regions {
+			proxies = append(proxies, regionProxies[strings.ToLower(region)]...)
+		}
+		return proxies
+	}
+	regions = make([]string, 0, len(regionProxies))
+	for region := range regionProxies {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+	for _, region := range regions {
+		proxies = append(proxies, regionProxies[region]...)
+	}
+	return proxies
+}
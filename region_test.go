@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+const (
+	regionBlockedPage = `<html><body><h1>Sorry!</h1><p>This item is Not Available in Your Region.</p></body></html>`
+	regionServedPage  = `<html><body><a href="/dp/B0AAAAAAAA/">Phone</a></body></html>`
+)
+
+// regionProxy serves every proxied request itself, as a proxy in a region
+// the site serves would see it.
+func regionProxy(t *testing.T, page string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	requests := new(atomic.Int32)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		fmt.Fprint(w, page)
+	}))
+	t.Cleanup(proxy.Close)
+	return proxy, requests
+}
+
+func TestIsRegionBlocked(t *testing.T) {
+	setGlobal(t, &regionMarkers, parseRegionMarkers(defaultRegionMarkers+", geo fenced "))
+	tests := []struct {
+		name string
+		html string
+		want bool
+	}{
+		{"default marker any case", regionBlockedPage, true},
+		{"configured marker", "<p>This listing is GEO FENCED.</p>", true},
+		{"regular page", regionServedPage, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRegionBlocked(tt.html); got != tt.want {
+				t.Errorf("isRegionBlocked = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	setGlobal(t, &regionMarkers, nil)
+	if isRegionBlocked(regionBlockedPage) {
+		t.Error("isRegionBlocked without markers = true, want false")
+	}
+}
+
+func TestRetryViaRegionProxies(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, regionBlockedPage)
+	}))
+	defer site.Close()
+	pageURL := site.URL + "/s?k=phone"
+	setGlobal(t, &regionMarkers, parseRegionMarkers(defaultRegionMarkers))
+
+	t.Run("retried via a proxy in a served region", func(t *testing.T) {
+		blocked, blockedRequests := regionProxy(t, regionBlockedPage)
+		served, servedRequests := regionProxy(t, regionServedPage)
+		setGlobal(t, &regionProxies, parseRegionMap("us="+blocked.URL+",in="+served.URL))
+		setGlobal(t, &siteRegions, parseRegionMap(urlHost(site.URL)+"=in"))
+
+		html, restricted := newTestCrawler(t).retryViaRegionProxies(context.Background(), pageURL, regionBlockedPage)
+		if restricted {
+			t.Fatal("page flagged region-restricted, want it retried")
+		}
+		if html != regionServedPage {
+			t.Errorf("html = %q, want the page served via the proxy", html)
+		}
+		if servedRequests.Load() != 1 || blockedRequests.Load() != 0 {
+			t.Errorf("proxy requests: served region %d, other region %d; want 1 and 0", servedRequests.Load(), blockedRequests.Load())
+		}
+	})
+
+	t.Run("flagged when every proxy is blocked", func(t *testing.T) {
+		blocked, requests := regionProxy(t, regionBlockedPage)
+		setGlobal(t, &regionProxies, parseRegionMap("us="+blocked.URL))
+		setGlobal(t, &siteRegions, map[string][]string{})
+
+		html, restricted := newTestCrawler(t).retryViaRegionProxies(context.Background(), pageURL, regionBlockedPage)
+		if !restricted || html != regionBlockedPage {
+			t.Errorf("restricted = %v, html = %q; want the original page flagged", restricted, html)
+		}
+		if requests.Load() != 1 {
+			t.Errorf("proxy requests = %d, want 1", requests.Load())
+		}
+	})
+
+	t.Run("flagged without a suitable proxy", func(t *testing.T) {
+		other, requests := regionProxy(t, regionServedPage)
+		setGlobal(t, &regionProxies, parseRegionMap("us="+other.URL))
+		setGlobal(t, &siteRegions, parseRegionMap(urlHost(site.URL)+"=in"))
+
+		_, restricted := newTestCrawler(t).retryViaRegionProxies(context.Background(), pageURL, regionBlockedPage)
+		if !restricted {
+			t.Error("page not flagged region-restricted")
+		}
+		if requests.Load() != 0 {
+			t.Errorf("proxy outside the site's regions got %d requests", requests.Load())
+		}
+	})
+}
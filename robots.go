@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// --- robots.txt Compliance ---
+// Every page is checked against its site's robots.txt before it is
+// fetched, unless -ignore-robots is set. The group for robotsAgent
+// applies, falling back to the "*" group; the longest matching Allow or
+// Disallow rule decides, Allow winning ties, and "*" and "$" wildcards are
+// understood. A Crawl-delay spaces requests to the host.
+//
+// robots.txt files are fetched once per run and cached in Redis for
+// robotsCacheTTL, so other instances and later runs skip the fetch. As
+// RFC 9309 asks, a missing robots.txt (4xx) allows everything and an
+// unreachable one (5xx or network error) disallows everything.
+const (
+	robotsAgent        = "ecommerce-crawler"
+	robotsCacheTTL     = 24 * time.Hour
+	robotsFetchTimeout = 10 * time.Second
+	maxRobotsBytes     = 512 << 10 // RFC 9309 requires parsing at least 500 KiB
+	robotsKeyPrefix    = "robots:"
+)
+
+// errRobotsDisallowed marks a page robots.txt does not let us fetch.
+var errRobotsDisallowed = errors.New("disallowed by robots.txt")
+
+// --- Parsed Rules for One Site ---
+type robotsRules struct {
+	rules       []robotsRule
+	crawlDelay  time.Duration
+	disallowAll bool     // robots.txt was unreachable
+	sitemaps    []string // Sitemap lines, which apply to every agent
+}
+
+type robotsRule struct {
+	allow   bool
+	length  int // of the pattern, for longest-match precedence
+	pattern *regexp.Regexp
+}
+
+// --- Whether a Path May Be Fetched ---
+// path includes the query string.
+func (r *robotsRules) Allowed(path string) bool {
+	if r.disallowAll {
+		return false
+	}
+	allowed, longest := true, -1
+	for _, rule := range r.rules {
+		if rule.length < longest || !rule.pattern.MatchString(path) {
+			continue
+		}
+		if rule.length > longest || rule.allow {
+			allowed, longest = rule.allow, rule.length
+		}
+	}
+	return allowed
+}
+
+// --- Parse robots.txt ---
+// Rules come from the groups naming agent, or from the "*" groups when none
+// does. Consecutive User-agent lines share the rules that follow them.
+func parseRobots(body, agent string) *robotsRules {
+	var specific, wildcard robotsRules
+	var hasSpecific bool
+	var agents []string
+	inRules := false
+
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		field, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		field, value = strings.ToLower(strings.TrimSpace(field)), strings.TrimSpace(value)
+
+		if field == "sitemap" {
+			if value != "" {
+				specific.sitemaps = append(specific.sitemaps, value)
+				wildcard.sitemaps = append(wildcard.sitemaps, value)
+			}
+			continue
+		}
+		if field == "user-agent" {
+			if inRules {
+				agents, inRules = nil, false
+			}
+			agents = append(agents, strings.ToLower(value))
+			continue
+		}
+		inRules = true
+		var targets []*robotsRules
+		for _, name := range agents {
+			switch {
+			case name == agent:
+				targets, hasSpecific = append(targets, &specific), true
+			case name == "*":
+				targets = append(targets, &wildcard)
+			}
+		}
+		for _, target := range targets {
+			target.add(field, value)
+		}
+	}
+	if hasSpecific {
+		return &specific
+	}
+	return &wildcard
+}
+
+func (r *robotsRules) add(field, value string) {
+	switch field {
+	case "allow", "disallow":
+		if value == "" {
+			return // an empty Disallow allows everything
+		}
+		r.rules = append(r.rules, robotsRule{allow: field == "allow", length: len(value), pattern: robotsPattern(value)})
+	case "crawl-delay":
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+			r.crawlDelay = time.Duration(seconds * float64(time.Second))
+		}
+	}
+}
+
+// --- Compile a Path Pattern ---
+// Patterns match path prefixes; "*" matches any run of characters and a
+// trailing "$" anchors the end.
+func robotsPattern(value string) *regexp.Regexp {
+	anchored := strings.HasSuffix(value, "$")
+	value = strings.TrimSuffix(value, "$")
+	parts := strings.Split(value, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	expr := "^" + strings.Join(parts, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+// --- robots.txt Policy ---
+// Holds the rules of every site seen this run and when each host may be
+// requested next under its Crawl-delay. A nil policy allows everything.
+type robotsPolicy struct {
+	client *redis.Client // shared cache; nil keeps robots.txt in memory only
+	http   *http.Client
+
+	mu    sync.Mutex
+	sites map[string]*robotsEntry // scheme://host -> rules, fetched once
+	next  map[string]time.Time    // host -> earliest next request
+}
+
+type robotsEntry struct {
+	once  sync.Once
+	rules *robotsRules
+}
+
+func newRobotsPolicy(client *redis.Client) *robotsPolicy {
+	return &robotsPolicy{
+		client: client,
+		http:   &http.Client{Timeout: robotsFetchTimeout},
+		sites:  make(map[string]*robotsEntry),
+		next:   make(map[string]time.Time),
+	}
+}
+
+// --- Check a Page Before Fetching It ---
+// Returns errRobotsDisallowed for a disallowed page, and otherwise waits
+// out the host's Crawl-delay, reserving the next slot so concurrent
+// requests to the host stay spaced.
+func (p *robotsPolicy) Check(ctx context.Context, pageURL string) error {
+	if p == nil {
+		return nil
+	}
+	parsed, err := url.Parse(pageURL)
+	if err != nil || parsed.Host == "" {
+		return nil
+	}
+	rules := p.rulesFor(ctx, parsed.Scheme+"://"+parsed.Host)
+	if !rules.Allowed(parsed.RequestURI()) {
+		return fmt.Errorf("%w: %s", errRobotsDisallowed, pageURL)
+	}
+	if rules.crawlDelay <= 0 {
+		return nil
+	}
+
+	host := strings.ToLower(parsed.Host)
+	p.mu.Lock()
+	now := time.Now()
+	slot := p.next[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	p.next[host] = slot.Add(rules.crawlDelay)
+	p.mu.Unlock()
+	if wait := slot.Sub(now); wait > 0 {
+		slog.Debug("Waiting for robots.txt Crawl-delay", "host", host, "wait", wait)
+		sleepCtx(ctx, wait)
+		return ctx.Err()
+	}
+	return nil
+}
+
+func (p *robotsPolicy) rulesFor(ctx context.Context, site string) *robotsRules {
+	p.mu.Lock()
+	entry, ok := p.sites[site]
+	if !ok {
+		entry = &robotsEntry{}
+		p.sites[site] = entry
+	}
+	p.mu.Unlock()
+	entry.once.Do(func() { entry.rules = p.load(ctx, site) })
+	return entry.rules
+}
+
+// --- Load a Site's Rules ---
+// The Redis copy is used when present; a freshly fetched robots.txt is
+// cached there, an unreachable one is not, so it is tried again next run.
+func (p *robotsPolicy) load(ctx context.Context, site string) *robotsRules {
+	if p.client != nil {
+		body, err := p.client.Get(ctx, robotsKeyPrefix+site).Result()
+		if err == nil {
+			return parseRobots(body, robotsAgent)
+		}
+		if !errors.Is(err, redis.Nil) {
+			slog.Warn("Failed to read cached robots.txt", "site", site, "error", err)
+		}
+	}
+
+	body, err := p.fetch(ctx, site)
+	if err != nil {
+		slog.Warn("robots.txt unreachable, not crawling the site", "site", site, "error", err)
+		return &robotsRules{disallowAll: true}
+	}
+	if p.client != nil {
+		if err := p.client.Set(ctx, robotsKeyPrefix+site, body, robotsCacheTTL).Err(); err != nil {
+			slog.Warn("Failed to cache robots.txt", "site", site, "error", err)
+		}
+	}
+	rules := parseRobots(body, robotsAgent)
+	slog.Debug("robots.txt loaded", "site", site, "rules", len(rules.rules), "crawl_delay", rules.crawlDelay)
+	return rules
+}
+
+// --- Fetch robots.txt ---
+// A 4xx response means there are no rules and yields an empty body.
+func (p *robotsPolicy) fetch(ctx context.Context, site string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, site+"/robots.txt", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", robotsAgent)
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return "", nil
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRobotsBytes))
+	if err != nil {
+		return "", fmt.Errorf("read robots.txt: %w", err)
+	}
+	return string(body), nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// --- S3 Result Export ---
+// Results can be uploaded to an S3 bucket, or any S3-compatible store via
+// -s3-endpoint. Credentials and region come from the standard AWS sources
+// (environment, shared config, IAM role). Objects are keyed
+// <prefix>/<runID>-<timestamp>/<file>, so runs never overwrite each other.
+const s3UploadTimeout = 2 * time.Minute
+
+// resultUploader uploads result files when set; nil keeps output local only.
+var resultUploader *s3Uploader
+
+type s3Uploader struct {
+	client    *s3.Client
+	bucket    string
+	keyPrefix string
+	skipLocal bool // upload only, without writing the local file
+}
+
+// --- Initialize S3 Uploader ---
+// A custom endpoint switches to path-style addressing, which MinIO and most
+// other S3-compatible stores expect.
+func newS3Uploader(ctx context.Context, bucket, prefix, endpoint, runID string, startedAt time.Time, skipLocal bool) (*s3Uploader, error) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load AWS config: %w", err)
+	}
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	})
+
+	run := runID + "-" + startedAt.UTC().Format("20060102T150405Z")
+	return &s3Uploader{
+		client:    client,
+		bucket:    bucket,
+		keyPrefix: path.Join(strings.Trim(prefix, "/"), run),
+		skipLocal: skipLocal,
+	}, nil
+}
+
+// --- Object Key for a Local Output Path ---
+func (u *s3Uploader) key(localPath string) string {
+	return path.Join(u.keyPrefix, filepath.Base(localPath))
+}
+
+// --- Upload One Output File ---
+// With -sign the detached signature is uploaded next to it as <key>.sig.
+func (u *s3Uploader) Upload(localPath string, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s3UploadTimeout)
+	defer cancel()
+
+	key := u.key(localPath)
+	if err := u.put(ctx, key, "application/json", data); err != nil {
+		return err
+	}
+	if outputSigner != nil {
+		sig, err := outputSigner.Sign(data)
+		if err != nil {
+			return err
+		}
+		if err := u.put(ctx, key+".sig", "text/plain", []byte(sig+"\n")); err != nil {
+			return err
+		}
+	}
+	slog.Info("Uploaded results to S3", "bucket", u.bucket, "key", key)
+	return nil
+}
+
+func (u *s3Uploader) put(ctx context.Context, key, contentType string, data []byte) error {
+	_, err := u.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(u.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return fmt.Errorf("upload s3://%s/%s: %w", u.bucket, key, err)
+	}
+	return nil
+}
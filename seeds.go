@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// --- Default Seeds ---
+// Crawled when no seed URLs are given on the command line.
+var defaultSeeds = []string{
+	"https://www.amazon.com/s?k=iphone",
+	"https://www.snapdeal.com/search?keyword=mobile",
+	"https://www.myntra.com/mobiles",
+}
+
+// --- Validate and Normalize Seed URLs ---
+// A seed without a scheme gets https://, the host is lowercased and
+// duplicates are dropped, keeping the first occurrence. Every invalid seed
+// is reported at once so bad input fails before any worker starts.
+func normalizeSeeds(raw []string) ([]string, error) {
+	var seeds []string
+	var errs []error
+	seen := make(map[string]bool)
+	for _, entry := range raw {
+		seed, err := normalizeSeed(entry)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("seed %q: %w", entry, err))
+			continue
+		}
+		if seed != entry {
+			slog.Info("Normalized seed", "seed", entry, "normalized", seed)
+		}
+		if seen[seed] {
+			slog.Info("Dropping duplicate seed", "seed", seed)
+			continue
+		}
+		seen[seed] = true
+		seeds = append(seeds, seed)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	if len(seeds) == 0 {
+		return nil, errors.New("no seed URLs")
+	}
+	return seeds, nil
+}
+
+// --- Seeds for Stored Domains ---
+// A stored domain is normally the seed URL that found the products and is
+// crawled as is; a bare host is crawled from its root page.
+func seedsFromDomains(domains []string) []string {
+	seeds := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		if domain == "" {
+			continue
+		}
+		if !strings.Contains(domain, "://") {
+			domain = "https://" + domain + "/"
+		}
+		seeds = append(seeds, domain)
+	}
+	return seeds
+}
+
+func normalizeSeed(entry string) (string, error) {
+	entry = strings.TrimSpace(entry)
+	if entry == "" {
+		return "", errors.New("empty")
+	}
+	if !strings.Contains(entry, "://") {
+		entry = "https://" + entry
+	}
+	parsed, err := url.Parse(entry)
+	if err != nil {
+		return "", err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return "", fmt.Errorf("unsupported scheme %q", parsed.Scheme)
+	}
+	host := parsed.Hostname()
+	if host == "" || strings.ContainsAny(host, " _") || (!strings.Contains(host, ".") && host != "localhost") {
+		return "", fmt.Errorf("invalid host %q", parsed.Host)
+	}
+	parsed.Host = strings.ToLower(parsed.Host)
+	return parsed.String(), nil
+}
+
+// --- Per-Run Seed Attribution ---
+// seedTracker records which seeds surfaced each product URL during this
+// run, independently of whether the URL was new to storage.
+type seedTracker struct {
+	mu    sync.Mutex
+	seeds map[string]map[string]bool
+}
+
+func newSeedTracker() *seedTracker {
+	return &seedTracker{seeds: make(map[string]map[string]bool)}
+}
+
+func (t *seedTracker) Add(url, seed string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.seeds[url] == nil {
+		t.seeds[url] = make(map[string]bool)
+	}
+	t.seeds[url][seed] = true
+}
+
+// --- Seeds per URL, Sorted ---
+func (t *seedTracker) Report() map[string][]string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	report := make(map[string][]string, len(t.seeds))
+	for url, set := range t.seeds {
+		seeds := make([]string, 0, len(set))
+		for seed := range set {
+			seeds = append(seeds, seed)
+		}
+		sort.Strings(seeds)
+		report[url] = seeds
+	}
+	return report
+}
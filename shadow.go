@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"html"
+	"log/slog"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+)
+
+// --- Shadow DOM Link Collection ---
+// OuterHTML does not serialize shadow roots, so product cards rendered as
+// web components never reach the regex. This script walks every open
+// shadow root, including nested ones, and returns the resolved hrefs.
+const shadowLinksScript = `(() => {
+	const hrefs = [];
+	const walk = (root) => {
+		for (const el of root.querySelectorAll('*')) {
+			if (el.shadowRoot) {
+				for (const a of el.shadowRoot.querySelectorAll('a[href]')) {
+					hrefs.push(a.href);
+				}
+				walk(el.shadowRoot);
+			}
+		}
+	};
+	walk(document);
+	return hrefs;
+})()`
+
+// --- Collect Links Hidden in Shadow Roots ---
+// The links are returned as plain anchors so they can be appended to the
+// page HTML and go through the normal extraction pipeline.
+func collectShadowLinks(ctx context.Context, pageURL string) string {
+	var hrefs []string
+	if err := chromedp.Run(ctx, chromedp.Evaluate(shadowLinksScript, &hrefs)); err != nil {
+		slog.Warn("Failed to collect shadow DOM links", "url", pageURL, "error", err)
+		return ""
+	}
+	if len(hrefs) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("\n<!-- shadow DOM links -->\n")
+	for _, href := range hrefs {
+		b.WriteString(`<a href="`)
+		b.WriteString(html.EscapeString(href))
+		b.WriteString("\"></a>\n")
+	}
+	slog.Debug("Collected shadow DOM links", "url", pageURL, "links", len(hrefs))
+	return b.String()
+}
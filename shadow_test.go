@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+func TestCollectShadowLinks(t *testing.T) {
+	if testing.Short() {
+		t.Skip("launches a browser")
+	}
+	site := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer site.Close()
+	pageURL := site.URL + "/shadow-dom.html"
+
+	opts := append(chromedp.DefaultExecAllocatorOptions[:], chromedp.NoSandbox)
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(context.Background(), opts...)
+	defer cancelAlloc()
+	ctx, cancel := chromedp.NewContext(allocCtx)
+	defer cancel()
+	ctx, cancelTimeout := context.WithTimeout(ctx, 30*time.Second)
+	defer cancelTimeout()
+	if err := chromedp.Run(ctx); err != nil {
+		t.Skipf("no browser available: %v", err)
+	}
+
+	var htmlContent string
+	if err := chromedp.Run(ctx, chromedp.Navigate(pageURL), chromedp.OuterHTML("html", &htmlContent)); err != nil {
+		t.Fatal(err)
+	}
+	light := extractProductURLs(htmlContent, pageURL, DomainConfig{})
+	if want := []string{site.URL + "/dp/B0AAAAAAAA/"}; !reflect.DeepEqual(light, want) {
+		t.Fatalf("without shadow roots: %v, want only the light DOM link %v", light, want)
+	}
+
+	got := extractProductURLs(htmlContent+collectShadowLinks(ctx, pageURL), pageURL, DomainConfig{})
+	want := []string{
+		site.URL + "/dp/B0AAAAAAAA/",
+		site.URL + "/dp/B0BBBBBBBB/",
+		site.URL + "/dp/B0CCCCCCCC/",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("with shadow links: %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// --- Shipping Cost Extraction ---
+// Shipping is read from the domain's shippingSelector when configured and
+// otherwise from schema.org shippingDetails in the page's JSON-LD. "Free
+// shipping" normalizes to a zero cost; "Free over $35" records a
+// free-shipping threshold instead.
+
+var (
+	freeOverPattern = regexp.MustCompile(`(?i)\bfree\b.*?\b(?:over|above|orders? (?:of|over|above)|min(?:imum)?(?: order)?(?: of)?)\s*(.*\d.*)`)
+	freePattern     = regexp.MustCompile(`(?i)\bfree\s+(?:standard\s+)?(?:shipping|delivery)\b|\b(?:shipping|delivery)\s*:?\s*free\b`)
+)
+
+// --- Extract a Product's Shipping Details ---
+func extractShipping(doc *goquery.Document, cfg DomainConfig, product *Product) {
+	if text := selectText(doc, cfg.ShippingSelector); text != "" {
+		product.ShippingRaw = text
+		product.ShippingCost, product.FreeShippingThreshold = parseShipping(text, cfg)
+		return
+	}
+
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		var data any
+		if json.Unmarshal([]byte(s.Text()), &data) != nil {
+			return true
+		}
+		details := findJSONKey(data, "shippingDetails")
+		if details == nil {
+			return true
+		}
+		product.ShippingCost = jsonAmount(findJSONKey(details, "shippingRate"))
+		product.FreeShippingThreshold = jsonAmount(findJSONKey(details, "freeShippingThreshold"))
+		return product.ShippingCost == nil && product.FreeShippingThreshold == nil
+	})
+}
+
+// --- Parse Shipping Text ---
+// Returns the shipping cost and the order value above which shipping is
+// free; either is nil when the text does not state it.
+func parseShipping(text string, cfg DomainConfig) (cost, threshold *float64) {
+	if match := freeOverPattern.FindStringSubmatch(text); match != nil {
+		threshold, _ = normalizePrice(match[1], cfg)
+		return nil, threshold
+	}
+	if freePattern.MatchString(text) {
+		zero := 0.0
+		return &zero, nil
+	}
+	cost, _ = normalizePrice(text, cfg)
+	return cost, nil
+}
+
+// --- Find a Key Anywhere in Decoded JSON ---
+func findJSONKey(data any, key string) any {
+	switch v := data.(type) {
+	case map[string]any:
+		if found, ok := v[key]; ok {
+			return found
+		}
+		for _, child := range v {
+			if found := findJSONKey(child, key); found != nil {
+				return found
+			}
+		}
+	case []any:
+		for _, child := range v {
+			if found := findJSONKey(child, key); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+// --- Amount of a schema.org MonetaryAmount ---
+// Accepts a bare number or string as well as {"value": ...} and
+// {"price": ...} objects.
+func jsonAmount(data any) *float64 {
+	switch v := data.(type) {
+	case float64:
+		return &v
+	case string:
+		if amount, err := strconv.ParseFloat(v, 64); err == nil {
+			return &amount
+		}
+	case map[string]any:
+		if value, ok := v["value"]; ok {
+			return jsonAmount(value)
+		}
+		if price, ok := v["price"]; ok {
+			return jsonAmount(price)
+		}
+	case []any:
+		if len(v) > 0 {
+			return jsonAmount(v[0])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import "testing"
+
+func TestExtractProductDetailsShipping(t *testing.T) {
+	cfg := DomainConfig{ShippingSelector: ".delivery-message", Locale: "en-US"}
+	pageURL := "https://www.example.com/product/water-bottle"
+	tests := []struct {
+		fixture         string
+		cfg             DomainConfig
+		cost, threshold *float64
+	}{
+		{"product-shipping-free.html", cfg, ptr(0.0), nil},
+		{"product-shipping-flat.html", cfg, ptr(5.99), nil},
+		{"product-shipping-threshold.html", cfg, nil, ptr(35.0)},
+		{"product-shipping-jsonld.html", DomainConfig{}, ptr(4.5), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			product := extractProductDetails(readFixture(t, tt.fixture), pageURL, tt.cfg)
+			if !samePrice(product.ShippingCost, tt.cost) {
+				t.Errorf("ShippingCost = %v, want %v", deref(product.ShippingCost), deref(tt.cost))
+			}
+			if !samePrice(product.FreeShippingThreshold, tt.threshold) {
+				t.Errorf("FreeShippingThreshold = %v, want %v", deref(product.FreeShippingThreshold), deref(tt.threshold))
+			}
+		})
+	}
+}
+
+func TestParseShipping(t *testing.T) {
+	cfg := DomainConfig{Locale: "en-IN"}
+	tests := []struct {
+		text            string
+		cost, threshold *float64
+	}{
+		{"Free Delivery", ptr(0.0), nil},
+		{"Delivery: FREE", ptr(0.0), nil},
+		{"Free standard shipping", ptr(0.0), nil},
+		{"₹40 delivery charge", ptr(40.0), nil},
+		{"FREE delivery on orders above ₹499", nil, ptr(499.0)},
+		{"Free shipping on minimum order of ₹1,000", nil, ptr(1000.0)},
+		{"Delivery calculated at checkout", nil, nil},
+	}
+	for _, tt := range tests {
+		cost, threshold := parseShipping(tt.text, cfg)
+		if !samePrice(cost, tt.cost) || !samePrice(threshold, tt.threshold) {
+			t.Errorf("parseShipping(%q) = %v, %v; want %v, %v", tt.text, deref(cost), deref(threshold), deref(tt.cost), deref(tt.threshold))
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// --- Output Signing ---
+// Output files can be signed with HMAC-SHA256 or Ed25519. The detached
+// signature is written next to the file as <file>.sig in the form
+// "<alg>:<base64 signature>", so -verify knows which check to run.
+const (
+	signHMAC    = "hmac"
+	signEd25519 = "ed25519"
+)
+
+// outputSigner signs every output file when set; nil disables signing.
+var outputSigner *signer
+
+type signer struct {
+	alg     string
+	hmacKey []byte
+	private ed25519.PrivateKey
+	public  ed25519.PublicKey
+}
+
+// --- Load Signing Key ---
+// HMAC keys are the raw file contents with surrounding whitespace trimmed.
+// Ed25519 keys are PEM: a PKCS#8 private key signs and verifies, a PKIX
+// public key can only verify.
+func loadSigner(alg, keyPath string) (*signer, error) {
+	if keyPath == "" {
+		return nil, fmt.Errorf("-sign-key is required")
+	}
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("read signing key: %w", err)
+	}
+
+	switch alg {
+	case signHMAC:
+		key := bytes.TrimSpace(data)
+		if len(key) == 0 {
+			return nil, fmt.Errorf("HMAC key %s is empty", keyPath)
+		}
+		return &signer{alg: alg, hmacKey: key}, nil
+	case signEd25519:
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("%s is not a PEM file", keyPath)
+		}
+		switch block.Type {
+		case "PRIVATE KEY":
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("parse private key: %w", err)
+			}
+			private, ok := key.(ed25519.PrivateKey)
+			if !ok {
+				return nil, fmt.Errorf("%s is not an Ed25519 private key", keyPath)
+			}
+			return &signer{alg: alg, private: private, public: private.Public().(ed25519.PublicKey)}, nil
+		case "PUBLIC KEY":
+			key, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("parse public key: %w", err)
+			}
+			public, ok := key.(ed25519.PublicKey)
+			if !ok {
+				return nil, fmt.Errorf("%s is not an Ed25519 public key", keyPath)
+			}
+			return &signer{alg: alg, public: public}, nil
+		default:
+			return nil, fmt.Errorf("unsupported PEM block %q in %s", block.Type, keyPath)
+		}
+	default:
+		return nil, fmt.Errorf("unknown signing algorithm %q (want %s or %s)", alg, signHMAC, signEd25519)
+	}
+}
+
+// --- Sign Data ---
+func (s *signer) Sign(data []byte) (string, error) {
+	var sig []byte
+	switch s.alg {
+	case signHMAC:
+		mac := hmac.New(sha256.New, s.hmacKey)
+		mac.Write(data)
+		sig = mac.Sum(nil)
+	case signEd25519:
+		if s.private == nil {
+			return "", errors.New("an Ed25519 public key cannot sign")
+		}
+		sig = ed25519.Sign(s.private, data)
+	}
+	return s.alg + ":" + base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// --- Verify a Detached Signature ---
+func (s *signer) Verify(data []byte, signature string) error {
+	alg, encoded, ok := strings.Cut(strings.TrimSpace(signature), ":")
+	if !ok {
+		return errors.New("malformed signature")
+	}
+	if alg != s.alg {
+		return fmt.Errorf("signature uses %s but the key is %s", alg, s.alg)
+	}
+	sig, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("decode signature: %w", err)
+	}
+
+	switch s.alg {
+	case signHMAC:
+		mac := hmac.New(sha256.New, s.hmacKey)
+		mac.Write(data)
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return errors.New("signature mismatch")
+		}
+	case signEd25519:
+		if !ed25519.Verify(s.public, data, sig) {
+			return errors.New("signature mismatch")
+		}
+	}
+	return nil
+}
+
+// --- Write <path>.sig for an Output File ---
+// A nil signer leaves the output unsigned.
+func (s *signer) SignFile(path string, data []byte) error {
+	if s == nil {
+		return nil
+	}
+	sig, err := s.Sign(data)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".sig", []byte(sig+"\n"), 0o644); err != nil {
+		return fmt.Errorf("write signature: %w", err)
+	}
+	return nil
+}
+
+// --- Verify a File Against <path>.sig ---
+func (s *signer) VerifyFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	sig, err := os.ReadFile(path + ".sig")
+	if err != nil {
+		return err
+	}
+	return s.Verify(data, string(sig))
+}
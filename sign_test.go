@@ -0,0 +1,117 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeKey writes key material to a file in dir and returns its path.
+func writeKey(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSignedOutputVerifies(t *testing.T) {
+	dir := t.TempDir()
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey := writeKey(t, dir, "ed25519.pem", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}))
+	publicKey := writeKey(t, dir, "ed25519.pub.pem", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+
+	tests := []struct {
+		name               string
+		alg                string
+		signKey, verifyKey string
+		otherKey           string
+	}{
+		{"hmac", signHMAC, writeKey(t, dir, "hmac.key", []byte("s3cret\n")), "", writeKey(t, dir, "other.key", []byte("other"))},
+		{"ed25519", signEd25519, privateKey, publicKey, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := filepath.Join(t.TempDir(), "urls-www.amazon.in.txt")
+			data := []byte("https://www.amazon.in/dp/B0AAAAAAAA/\nhttps://www.amazon.in/dp/B0BBBBBBBB/\n")
+			if err := os.WriteFile(output, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			signing, err := loadSigner(tt.alg, tt.signKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := signing.SignFile(output, data); err != nil {
+				t.Fatal(err)
+			}
+
+			verifyKey := tt.signKey
+			if tt.verifyKey != "" {
+				verifyKey = tt.verifyKey
+			}
+			verifying, err := loadSigner(tt.alg, verifyKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := verifying.VerifyFile(output); err != nil {
+				t.Fatalf("untouched output: %v", err)
+			}
+
+			if tt.otherKey != "" {
+				other, err := loadSigner(tt.alg, tt.otherKey)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if other.VerifyFile(output) == nil {
+					t.Error("output verified with another key")
+				}
+			}
+
+			tampered := append([]byte("https://evil.example.com/dp/B0CCCCCCCC/\n"), data...)
+			if err := os.WriteFile(output, tampered, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if verifying.VerifyFile(output) == nil {
+				t.Error("tampered output verified")
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsMismatchedSignatures(t *testing.T) {
+	hmacSigner := &signer{alg: signHMAC, hmacKey: []byte("s3cret")}
+	data := []byte("https://www.myntra.com/shirts/123456/buy\n")
+	for name, signature := range map[string]string{
+		"malformed":       "no separator",
+		"other algorithm": "ed25519:AAAA",
+		"bad base64":      "hmac:!!!",
+		"truncated":       "hmac:AAAA",
+	} {
+		if hmacSigner.Verify(data, signature) == nil {
+			t.Errorf("%s signature verified", name)
+		}
+	}
+
+	public, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&signer{alg: signEd25519, public: public}).Sign(data); err == nil {
+		t.Error("public key signed data")
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// --- Product Event Sink ---
+// A ProductSink receives one event per product URL that was new to
+// storage, for downstream consumers such as an indexing pipeline. SINK
+// selects it: "webhook" posts to SINK_WEBHOOK_URL, "kafka" produces to
+// KAFKA_TOPIC on the comma-separated KAFKA_BROKERS. The -kafka-brokers and
+// -kafka-topic flags override those variables and select the Kafka sink on
+// their own. Unset disables it.
+type ProductSink interface {
+	Publish(ctx context.Context, events []productEvent) error
+}
+
+// --- New Product Event ---
+type productEvent struct {
+	URL          string    `json:"url"`
+	Domain       string    `json:"domain"`
+	SourceURL    string    `json:"source_url"`
+	Seed         string    `json:"seed"`
+	RunID        string    `json:"run_id"`
+	DiscoveredAt time.Time `json:"discovered_at"`
+}
+
+const sinkTimeout = 10 * time.Second
+
+// --- Select Product Sink ---
+// brokers and topic come from -kafka-brokers and -kafka-topic.
+func initSink(brokers, topic string) (ProductSink, error) {
+	sink := os.Getenv("SINK")
+	if brokers != "" || topic != "" {
+		sink = "kafka"
+	}
+	switch sink {
+	case "":
+		return nil, nil
+	case "webhook":
+		url := os.Getenv("SINK_WEBHOOK_URL")
+		if url == "" {
+			return nil, fmt.Errorf("SINK_WEBHOOK_URL is missing in .env file")
+		}
+		return &webhookSink{url: url, client: &http.Client{Timeout: sinkTimeout}}, nil
+	case "kafka":
+		if brokers == "" {
+			brokers = os.Getenv("KAFKA_BROKERS")
+		}
+		if topic == "" {
+			topic = os.Getenv("KAFKA_TOPIC")
+		}
+		if brokers == "" || topic == "" {
+			return nil, fmt.Errorf("Kafka brokers and topic are required for the kafka sink")
+		}
+		return newKafkaSink(strings.Split(brokers, ","), topic), nil
+	default:
+		return nil, fmt.Errorf("unknown SINK %q", sink)
+	}
+}
+
+// --- Webhook Sink ---
+// Posts each batch as one JSON array.
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *webhookSink) Publish(ctx context.Context, events []productEvent) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// --- Kafka Sink ---
+// One message per product, keyed by URL so updates for a product stay on
+// one partition. The writer is asynchronous: Publish only queues messages,
+// which are sent in batches and retried by the writer, so a slow broker
+// never stalls the crawl. Failed batches are logged; Close flushes the
+// queue at exit.
+type kafkaSink struct {
+	writer *kafka.Writer
+}
+
+func newKafkaSink(brokers []string, topic string) *kafkaSink {
+	return &kafkaSink{writer: &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		WriteTimeout: sinkTimeout,
+		BatchTimeout: time.Second,
+		MaxAttempts:  5,
+		Async:        true,
+		Completion: func(messages []kafka.Message, err error) {
+			if err != nil {
+				slog.Warn("Failed to publish products to Kafka", "topic", topic, "products", len(messages), "error", err)
+			}
+		},
+	}}
+}
+
+func (s *kafkaSink) Publish(ctx context.Context, events []productEvent) error {
+	messages := make([]kafka.Message, 0, len(events))
+	for _, event := range events {
+		value, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, kafka.Message{Key: []byte(event.URL), Value: value})
+	}
+	return s.writer.WriteMessages(ctx, messages...)
+}
+
+func (s *kafkaSink) Close() error {
+	return s.writer.Close()
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// --- Sitemap Seeding ---
+// With -sitemap, no page is rendered: each seed's site is looked up in
+// its robots.txt Sitemap lines, falling back to /sitemap.xml, and every
+// <loc> that matches the domain's product URL patterns is stored like a
+// URL found on a listing page. Sitemap index files are followed and
+// gzipped sitemaps (.xml.gz) are decompressed.
+const (
+	maxSitemapBytes = 50 << 20 // the sitemaps.org limit for an uncompressed file
+	maxSitemapDepth = 3        // nested index files followed
+	maxSitemapFiles = 1000     // sitemap files read per site
+)
+
+// sitemapDoc decodes both <urlset> and <sitemapindex>; only one of the
+// lists is filled.
+type sitemapDoc struct {
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// --- Crawl the Seeds' Sitemaps Concurrently ---
+// One result per seed, whose URLs are the new product URLs kept from all
+// of its site's sitemaps.
+func (c *Crawler) crawlSitemaps(ctx context.Context, seeds []string) []CrawlResult {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var results []CrawlResult
+	sites := make(map[string]bool)
+	for _, seed := range seeds {
+		parsed, err := url.Parse(seed)
+		if err != nil {
+			continue
+		}
+		site := parsed.Scheme + "://" + parsed.Host
+		if sites[site] {
+			continue // seeds on one site share its sitemaps
+		}
+		sites[site] = true
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := c.crawlSitemap(ctx, site, seed)
+			mu.Lock()
+			defer mu.Unlock()
+			results = append(results, result)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// --- Store the Product URLs of One Site's Sitemaps ---
+func (c *Crawler) crawlSitemap(ctx context.Context, site, seed string) CrawlResult {
+	c.audit.Info("domain_started", "url", seed, "mode", "sitemap")
+	host := urlHost(site)
+	cfg := c.config.forHost(host)
+	result := CrawlResult{Domain: seed, SourceURL: site}
+
+	pending := c.sitemapURLs(ctx, site)
+	depth := make(map[string]int, len(pending)) // sitemap URL -> index files above it
+	for _, sitemapURL := range pending {
+		depth[sitemapURL] = 0
+	}
+	for files := 0; len(pending) > 0 && files < maxSitemapFiles && ctx.Err() == nil; files++ {
+		sitemapURL := pending[0]
+		pending = pending[1:]
+
+		doc, err := c.fetchSitemap(ctx, sitemapURL)
+		if err != nil {
+			slog.Warn("Failed to read sitemap", "url", sitemapURL, "error", err)
+			c.stats.Error(host)
+			continue
+		}
+		c.stats.PageVisited(host)
+		for _, child := range doc.Sitemaps {
+			loc := strings.TrimSpace(child.Loc)
+			if _, queued := depth[loc]; !queued && loc != "" && depth[sitemapURL] < maxSitemapDepth {
+				depth[loc] = depth[sitemapURL] + 1
+				pending = append(pending, loc)
+			}
+		}
+
+		var extracted []string
+		for _, entry := range doc.URLs {
+			extracted = append(extracted, extractProductURLs(strings.TrimSpace(entry.Loc), site, cfg)...)
+		}
+		productURLs, crossSeedURLs := c.filterSeen(extracted, seed)
+		productURLs = c.dropRecent(ctx, productURLs)
+		productURLs = productURLs[:c.limits.TakeURLs(host, len(productURLs))]
+		newCount := c.storeProductURLs(ctx, append(productURLs, crossSeedURLs...), sitemapURL, seed)
+		c.stats.URLsFound(host, len(extracted), newCount)
+		slog.Info("Sitemap read", "url", sitemapURL, "entries", len(doc.URLs), "sitemaps", len(doc.Sitemaps), "products", len(productURLs))
+		result.URLs = append(result.URLs, productURLs...)
+	}
+
+	if c.productDetails {
+		result.Products = c.fetchProductDetails(ctx, result.URLs)
+		if c.dryRun {
+			slog.Info("Dry run: would store product details", "url", seed, "products", len(result.Products))
+		} else if err := c.store.SaveProducts(ctx, result.Products); err != nil {
+			slog.Error("Failed to store product details", "url", seed, "error", err)
+			c.stats.Error(host)
+		}
+	}
+	c.audit.Info("domain_finished", "url", seed, "urls", len(result.URLs), "products", len(result.Products))
+	return result
+}
+
+// --- Sitemaps of a Site ---
+// The Sitemap lines of robots.txt, or /sitemap.xml when it lists none.
+func (c *Crawler) sitemapURLs(ctx context.Context, site string) []string {
+	policy := c.robots
+	if policy == nil {
+		policy = newRobotsPolicy(nil) // -ignore-robots still reads the Sitemap lines
+	}
+	if sitemaps := policy.rulesFor(ctx, site).sitemaps; len(sitemaps) > 0 {
+		return sitemaps
+	}
+	return []string{site + "/sitemap.xml"}
+}
+
+// --- Fetch and Decode One Sitemap ---
+// A gzipped file is recognized by its magic bytes, since servers label
+// .xml.gz files inconsistently.
+func (c *Crawler) fetchSitemap(ctx context.Context, sitemapURL string) (*sitemapDoc, error) {
+	host := urlHost(sitemapURL)
+	if err := c.throttle.Wait(ctx, host); err != nil {
+		return nil, err
+	}
+	if err := c.pacer.Wait(ctx, host); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sitemapURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range c.requestHeaders(host) {
+		req.Header.Set(name, value)
+	}
+	client := &http.Client{Timeout: c.config.forHost(host).crawlTimeout(), CheckRedirect: checkRedirect}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	c.throttle.Observe(host, resp.StatusCode, resp.Header)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var body io.Reader = bufio.NewReader(resp.Body)
+	if magic, err := body.(*bufio.Reader).Peek(2); err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("decode gzipped sitemap: %w", err)
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	var doc sitemapDoc
+	if err := xml.NewDecoder(io.LimitReader(body, maxSitemapBytes)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("parse sitemap: %w", err)
+	}
+	return &doc, nil
+}
@@ -0,0 +1,717 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// --- Storage Backend Interface ---
+// Store persists discovered product URLs. Save must be idempotent: saving a
+// URL that already exists never creates a second record.
+type Store interface {
+	Save(ctx context.Context, urls []ProductURL) error
+	Exists(ctx context.Context, url string) (bool, error)
+	// ExistingURLs returns which of urls are already stored, in one
+	// lookup per storeBatchSize URLs.
+	ExistingURLs(ctx context.Context, urls []string) (map[string]bool, error)
+	SaveProducts(ctx context.Context, products []Product) error
+	URLs(ctx context.Context) ([]string, error)    // every stored product URL, for the fetch phase
+	Domains(ctx context.Context) ([]string, error) // distinct stored domains, for -domains-from-db
+	// RecentURLs returns which of urls were last seen at or after since,
+	// for -since.
+	RecentURLs(ctx context.Context, urls []string, since time.Time) (map[string]bool, error)
+	// Records returns every stored URL record, sorted by URL, for the
+	// export and serve commands.
+	Records(ctx context.Context) ([]ProductURL, error)
+	// PriceHistory returns the price snapshots of url, or of every product
+	// when url is empty, sorted by URL and then time, for the history
+	// command.
+	PriceHistory(ctx context.Context, url string) ([]PriceSnapshot, error)
+}
+
+// --- Select Storage Backend ---
+// backend comes from -store, falling back to STORAGE; Postgres is the
+// default. Postgres and SQLite share the GORM store and its migrations;
+// "none" needs no database at all. runID labels the price snapshots this
+// run records and is empty for commands that only read.
+func initStore(backend, runID string) (Store, error) {
+	if backend == "" {
+		backend = os.Getenv("STORAGE")
+	}
+	switch backend {
+	case "", "postgres":
+		db, err := initDB()
+		if err != nil {
+			return nil, err
+		}
+		buffer, err := writeBufferFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		return &gormStore{db: db, priceHistory: *priceHistory, runID: runID, conn: dbReconnector(db), buffer: buffer}, nil
+	case "sqlite":
+		db, err := initSQLite()
+		if err != nil {
+			return nil, err
+		}
+		return &gormStore{db: db, priceHistory: *priceHistory, runID: runID}, nil
+	case "mongo":
+		return newMongoStore(*priceHistory, runID)
+	case "stdout":
+		return newStdoutStore(os.Stdout), nil
+	case "none":
+		// Nothing outlives the run; results only reach the output files.
+		slog.Info("No database: product URLs are kept in memory and written to the output files only")
+		store := newMemoryStore()
+		store.runID = runID
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
+
+// --- Valid Postgres sslmode Values ---
+var postgresSSLModes = map[string]bool{
+	"disable": true, "allow": true, "prefer": true,
+	"require": true, "verify-ca": true, "verify-full": true,
+}
+
+// --- Initialize PostgreSQL Connection ---
+func initDB() (*gorm.DB, error) {
+	dbHost := os.Getenv("DB_HOST")
+	dbUser := os.Getenv("DB_USER")
+	dbPassword := os.Getenv("DB_PASSWORD")
+	dbName := os.Getenv("DB_NAME")
+	dbPort := os.Getenv("DB_PORT")
+
+	if dbHost == "" || dbUser == "" || dbPassword == "" || dbName == "" || dbPort == "" {
+		return nil, fmt.Errorf("database credentials are missing in .env file")
+	}
+
+	// DB_SSLMODE is needed for managed databases; local dev keeps "disable".
+	sslMode := os.Getenv("DB_SSLMODE")
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	if !postgresSSLModes[sslMode] {
+		return nil, fmt.Errorf("invalid DB_SSLMODE %q (want disable, allow, prefer, require, verify-ca or verify-full)", sslMode)
+	}
+
+	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
+		dbHost, dbUser, dbPassword, dbName, dbPort, sslMode)
+
+	// TranslateError maps unique violations to gorm.ErrDuplicatedKey.
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
+	if err != nil {
+		return nil, fmt.Errorf("database connection failed: %w", err)
+	}
+
+	// Configure connection pooling
+	pool, err := dbPoolFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("configure DB connection pool: %w", err)
+	}
+	sqlDB.SetMaxOpenConns(pool.maxOpen)
+	sqlDB.SetMaxIdleConns(pool.maxIdle)
+	sqlDB.SetConnMaxLifetime(pool.maxLifetime)
+
+	if err := migrate(db); err != nil {
+		return nil, err
+	}
+	slog.Info("Database initialized successfully")
+	return db, nil
+}
+
+// --- Postgres Connection Pool Settings ---
+// DB_MAX_OPEN_CONNS, DB_MAX_IDLE_CONNS and DB_CONN_MAX_LIFETIME (e.g.
+// "30m") override the defaults of 20 open, 10 idle and 30 minutes. Raise
+// them together with crawl concurrency.
+type dbPool struct {
+	maxOpen     int
+	maxIdle     int
+	maxLifetime time.Duration
+}
+
+func dbPoolFromEnv() (dbPool, error) {
+	pool := dbPool{maxOpen: 20, maxIdle: 10, maxLifetime: 30 * time.Minute}
+	if value := os.Getenv("DB_MAX_OPEN_CONNS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return pool, fmt.Errorf("DB_MAX_OPEN_CONNS %q must be a positive integer", value)
+		}
+		pool.maxOpen = n
+	}
+	if value := os.Getenv("DB_MAX_IDLE_CONNS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return pool, fmt.Errorf("DB_MAX_IDLE_CONNS %q must be a non-negative integer", value)
+		}
+		pool.maxIdle = n
+	}
+	if value := os.Getenv("DB_CONN_MAX_LIFETIME"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return pool, fmt.Errorf("DB_CONN_MAX_LIFETIME %q must be a non-negative duration", value)
+		}
+		pool.maxLifetime = d
+	}
+	if pool.maxIdle > pool.maxOpen {
+		return pool, fmt.Errorf("DB_MAX_IDLE_CONNS (%d) must not exceed DB_MAX_OPEN_CONNS (%d)", pool.maxIdle, pool.maxOpen)
+	}
+	return pool, nil
+}
+
+// --- Initialize SQLite Database ---
+// SQLITE_PATH names the database file, crawler.db by default. SQLite allows
+// a single writer, so the pool is one connection and workers queue on it.
+func initSQLite() (*gorm.DB, error) {
+	path := os.Getenv("SQLITE_PATH")
+	if path == "" {
+		path = "crawler.db"
+	}
+
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{TranslateError: true})
+	if err != nil {
+		return nil, fmt.Errorf("open SQLite database %s: %w", path, err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("configure SQLite connection: %w", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	if err := migrate(db); err != nil {
+		return nil, err
+	}
+	slog.Info("SQLite database initialized", "path", path)
+	return db, nil
+}
+
+// --- Auto-Create Tables ---
+func migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&ProductURL{}, &ProductURLSeed{}, &Product{}, &PriceChange{}, &PriceSnapshot{}); err != nil {
+		return fmt.Errorf("migrate database: %w", err)
+	}
+	return nil
+}
+
+// --- GORM Store (Postgres or SQLite) ---
+type gormStore struct {
+	db           *gorm.DB
+	priceHistory bool         // record every price change in price_changes
+	runID        string       // labels price_snapshots rows; empty records none
+	conn         *reconnector // nil for SQLite, which has no connection to lose
+	buffer       *writeBuffer // rows held during a Postgres outage; nil for SQLite
+}
+
+// --- Database Health Check for Reconnects ---
+// Writes are buffered through an outage, so giving up is not fatal.
+func dbReconnector(db *gorm.DB) *reconnector {
+	return newReconnector("database", *maxReconnects, func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	})
+}
+
+// Save upserts on URL: new URLs are inserted, known ones only get LastSeen
+// refreshed and SeenCount incremented. URLs are written storeBatchSize at
+// a time, one upsert statement per batch, so concurrent workers saving the
+// same URL cannot hit a unique violation. Being idempotent, the whole
+// batch is simply repeated after a reconnect.
+func (s *gormStore) Save(ctx context.Context, urls []ProductURL) error {
+	return s.write(ctx, urls, nil)
+}
+
+// storeBatchSize caps the rows of one upsert statement, well below
+// Postgres' 65535 bind parameters.
+const storeBatchSize = 500
+
+func (s *gormStore) save(db *gorm.DB, urls []ProductURL) error {
+	if len(urls) == 0 {
+		return nil
+	}
+	// One statement cannot upsert a row twice, so repeats are folded into
+	// the first occurrence; every seed is still recorded.
+	now := time.Now().UTC()
+	records := make([]ProductURL, 0, len(urls))
+	index := make(map[string]bool, len(urls))
+	var seeds []ProductURLSeed
+	seedIndex := make(map[ProductURLSeed]bool)
+	for _, record := range urls {
+		if !index[record.URL] {
+			index[record.URL] = true
+			record.LastSeen = now
+			record.SeenCount = 1
+			records = append(records, record)
+		}
+		seed := ProductURLSeed{URL: record.URL, Seed: record.Seed}
+		if record.Seed != "" && !seedIndex[seed] {
+			seedIndex[seed] = true
+			seeds = append(seeds, seed)
+		}
+	}
+
+	err := db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "url"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"last_seen":  now,
+			"seen_count": gorm.Expr("product_urls.seen_count + 1"),
+		}),
+	}).CreateInBatches(&records, storeBatchSize).Error
+	if err != nil {
+		return fmt.Errorf("upsert %d product URLs: %w", len(records), err)
+	}
+	slog.Debug("Stored product URLs", "urls", len(records))
+
+	// Seed attribution is recorded even for duplicates.
+	if len(seeds) > 0 {
+		err := db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&seeds, storeBatchSize).Error
+		if err != nil {
+			return fmt.Errorf("record seeds of %d product URLs: %w", len(records), err)
+		}
+	}
+	return nil
+}
+
+// SaveProducts upserts on URL so a re-crawled product refreshes its row.
+// Prices are compared against the stored row first so PriceChangedAt and
+// the optional price history reflect real changes only.
+func (s *gormStore) SaveProducts(ctx context.Context, products []Product) error {
+	return s.write(ctx, nil, products)
+}
+
+func (s *gormStore) saveProducts(db *gorm.DB, products []Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	for i := range products {
+		product := &products[i]
+		var stored Product
+		err := db.Select("url", "price", "price_changed_at").Where("url = ?", product.URL).Take(&stored).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			continue
+		case err != nil:
+			return fmt.Errorf("load stored price for %s: %w", product.URL, err)
+		case samePrice(stored.Price, product.Price):
+			product.PriceChangedAt = stored.PriceChangedAt
+			continue
+		}
+
+		product.PriceChangedAt = &now
+		slog.Info("Price changed", "url", product.URL, "old", stored.Price, "new", product.Price)
+		if s.priceHistory {
+			change := PriceChange{URL: product.URL, OldPrice: stored.Price, NewPrice: product.Price, ChangedAt: now}
+			if err := db.Create(&change).Error; err != nil {
+				return fmt.Errorf("record price change for %s: %w", product.URL, err)
+			}
+		}
+	}
+
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "url"}},
+		UpdateAll: true,
+	}).CreateInBatches(&products, storeBatchSize).Error
+	if err != nil {
+		return fmt.Errorf("upsert products: %w", err)
+	}
+
+	if !s.priceHistory || s.runID == "" {
+		return nil
+	}
+	snapshots := make([]PriceSnapshot, 0, len(products))
+	for _, product := range products {
+		snapshots = append(snapshots, newPriceSnapshot(product, s.runID, now))
+	}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&snapshots).Error; err != nil {
+		return fmt.Errorf("record price snapshots: %w", err)
+	}
+	return nil
+}
+
+func (s *gormStore) URLs(ctx context.Context) ([]string, error) {
+	var urls []string
+	err := s.conn.Do(ctx, func() error {
+		urls = nil
+		return s.db.WithContext(ctx).Model(&ProductURL{}).Order("url").Pluck("url", &urls).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return urls, nil
+}
+
+func (s *gormStore) Domains(ctx context.Context) ([]string, error) {
+	var domains []string
+	err := s.conn.Do(ctx, func() error {
+		domains = nil
+		return s.db.WithContext(ctx).Model(&ProductURL{}).Distinct("domain").Order("domain").Pluck("domain", &domains).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return domains, nil
+}
+
+func (s *gormStore) Records(ctx context.Context) ([]ProductURL, error) {
+	var records []ProductURL
+	err := s.conn.Do(ctx, func() error {
+		records = nil
+		return s.db.WithContext(ctx).Order("url").Find(&records).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (s *gormStore) PriceHistory(ctx context.Context, url string) ([]PriceSnapshot, error) {
+	var snapshots []PriceSnapshot
+	err := s.conn.Do(ctx, func() error {
+		snapshots = nil
+		query := s.db.WithContext(ctx).Order("url").Order("seen_at")
+		if url != "" {
+			query = query.Where("url = ?", url)
+		}
+		return query.Find(&snapshots).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+func (s *gormStore) RecentURLs(ctx context.Context, urls []string, since time.Time) (map[string]bool, error) {
+	if len(urls) == 0 {
+		return nil, nil
+	}
+	var recent []string
+	err := s.conn.Do(ctx, func() error {
+		recent = nil
+		return s.db.WithContext(ctx).Model(&ProductURL{}).
+			Where("url IN ? AND last_seen >= ?", urls, since).Pluck("url", &recent).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[string]bool, len(recent))
+	for _, url := range recent {
+		set[url] = true
+	}
+	return set, nil
+}
+
+func (s *gormStore) ExistingURLs(ctx context.Context, urls []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	for start := 0; start < len(urls); start += storeBatchSize {
+		chunk := urls[start:min(start+storeBatchSize, len(urls))]
+		var found []string
+		err := s.conn.Do(ctx, func() error {
+			found = nil
+			return s.db.WithContext(ctx).Model(&ProductURL{}).Where("url IN ?", chunk).Pluck("url", &found).Error
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, url := range found {
+			existing[url] = true
+		}
+	}
+	return existing, nil
+}
+
+func (s *gormStore) Exists(ctx context.Context, url string) (bool, error) {
+	var count int64
+	err := s.conn.Do(ctx, func() error {
+		return s.db.WithContext(ctx).Model(&ProductURL{}).Where("url = ?", url).Count(&count).Error
+	})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// --- In-Memory Store ---
+// memoryStore keeps records in a map; it backs tests and runs without a
+// database.
+type memoryStore struct {
+	mu           sync.Mutex
+	records      map[string]ProductURL
+	seeds        map[string]map[string]bool
+	products     map[string]Product
+	priceChanges []PriceChange
+	snapshots    []PriceSnapshot
+	runID        string
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		records:  make(map[string]ProductURL),
+		seeds:    make(map[string]map[string]bool),
+		products: make(map[string]Product),
+	}
+}
+
+func (s *memoryStore) SaveProducts(_ context.Context, products []Product) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	for _, product := range products {
+		if stored, ok := s.products[product.URL]; ok {
+			if samePrice(stored.Price, product.Price) {
+				product.PriceChangedAt = stored.PriceChangedAt
+			} else {
+				product.PriceChangedAt = &now
+				s.priceChanges = append(s.priceChanges, PriceChange{URL: product.URL, OldPrice: stored.Price, NewPrice: product.Price, ChangedAt: now})
+			}
+		}
+		s.products[product.URL] = product
+		s.snapshots = append(s.snapshots, newPriceSnapshot(product, s.runID, now))
+	}
+	return nil
+}
+
+func (s *memoryStore) Save(_ context.Context, urls []ProductURL) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, record := range urls {
+		if existing, ok := s.records[record.URL]; ok {
+			existing.LastSeen = time.Now().UTC()
+			existing.SeenCount++
+			s.records[record.URL] = existing
+		} else {
+			record.LastSeen = time.Now().UTC()
+			record.SeenCount = 1
+			s.records[record.URL] = record
+		}
+		if record.Seed != "" {
+			if s.seeds[record.URL] == nil {
+				s.seeds[record.URL] = make(map[string]bool)
+			}
+			s.seeds[record.URL][record.Seed] = true
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) URLs(_ context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	urls := make([]string, 0, len(s.records))
+	for url := range s.records {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	return urls, nil
+}
+
+func (s *memoryStore) Domains(_ context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	set := make(map[string]bool)
+	for _, record := range s.records {
+		set[record.Domain] = true
+	}
+	domains := make([]string, 0, len(set))
+	for domain := range set {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+	return domains, nil
+}
+
+func (s *memoryStore) Records(_ context.Context) ([]ProductURL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := make([]ProductURL, 0, len(s.records))
+	for _, record := range s.records {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].URL < records[j].URL })
+	return records, nil
+}
+
+func (s *memoryStore) PriceHistory(_ context.Context, url string) ([]PriceSnapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var snapshots []PriceSnapshot
+	for _, snapshot := range s.snapshots {
+		if url == "" || snapshot.URL == url {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool { return snapshots[i].URL < snapshots[j].URL })
+	return snapshots, nil
+}
+
+func (s *memoryStore) RecentURLs(_ context.Context, urls []string, since time.Time) (map[string]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	recent := make(map[string]bool)
+	for _, url := range urls {
+		if record, ok := s.records[url]; ok && !record.LastSeen.Before(since) {
+			recent[url] = true
+		}
+	}
+	return recent, nil
+}
+
+func (s *memoryStore) ExistingURLs(_ context.Context, urls []string) (map[string]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing := make(map[string]bool)
+	for _, url := range urls {
+		if _, ok := s.records[url]; ok {
+			existing[url] = true
+		}
+	}
+	return existing, nil
+}
+
+func (s *memoryStore) Exists(_ context.Context, url string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.records[url]
+	return ok, nil
+}
+
+// --- Stdout Store ---
+// stdoutStore writes each newly found URL and each product as a JSON line,
+// for runs that pipe results elsewhere instead of keeping a database.
+// Known URLs are only remembered for the lifetime of the process.
+type stdoutStore struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	urls    map[string]bool
+	domains map[string]bool
+}
+
+func newStdoutStore(w io.Writer) *stdoutStore {
+	return &stdoutStore{encoder: json.NewEncoder(w), urls: make(map[string]bool), domains: make(map[string]bool)}
+}
+
+func (s *stdoutStore) Save(_ context.Context, urls []ProductURL) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, record := range urls {
+		if s.urls[record.URL] {
+			continue
+		}
+		s.urls[record.URL] = true
+		s.domains[record.Domain] = true
+		line := map[string]string{"type": "url", "url": record.URL, "domain": record.Domain, "source_url": record.SourceURL}
+		if err := s.encoder.Encode(line); err != nil {
+			return fmt.Errorf("write %s: %w", record.URL, err)
+		}
+	}
+	return nil
+}
+
+func (s *stdoutStore) SaveProducts(_ context.Context, products []Product) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, product := range products {
+		line := struct {
+			Type string `json:"type"`
+			Product
+		}{"product", product}
+		if err := s.encoder.Encode(line); err != nil {
+			return fmt.Errorf("write product %s: %w", product.URL, err)
+		}
+	}
+	return nil
+}
+
+func (s *stdoutStore) URLs(_ context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	urls := make([]string, 0, len(s.urls))
+	for url := range s.urls {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	return urls, nil
+}
+
+func (s *stdoutStore) Domains(_ context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	domains := make([]string, 0, len(s.domains))
+	for domain := range s.domains {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+	return domains, nil
+}
+
+// Records carries URLs only: their details went to stdout.
+func (s *stdoutStore) Records(_ context.Context) ([]ProductURL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := make([]ProductURL, 0, len(s.urls))
+	for url := range s.urls {
+		records = append(records, ProductURL{URL: url})
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].URL < records[j].URL })
+	return records, nil
+}
+
+// --- Stdout Price History ---
+// Returns nothing: products are written out, not kept.
+func (s *stdoutStore) PriceHistory(_ context.Context, _ string) ([]PriceSnapshot, error) {
+	return nil, nil
+}
+
+// RecentURLs reports the URLs written by this process, the only ones it
+// knows.
+func (s *stdoutStore) RecentURLs(_ context.Context, urls []string, _ time.Time) (map[string]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	recent := make(map[string]bool)
+	for _, url := range urls {
+		if s.urls[url] {
+			recent[url] = true
+		}
+	}
+	return recent, nil
+}
+
+func (s *stdoutStore) ExistingURLs(_ context.Context, urls []string) (map[string]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing := make(map[string]bool)
+	for _, url := range urls {
+		if s.urls[url] {
+			existing[url] = true
+		}
+	}
+	return existing, nil
+}
+
+func (s *stdoutStore) Exists(_ context.Context, url string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.urls[url], nil
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// newSQLiteStore returns a GORM store on a fresh, migrated in-memory
+// SQLite database. The store's single connection keeps it alive.
+func newSQLiteStore(t *testing.T) *gormStore {
+	t.Helper()
+	t.Setenv("SQLITE_PATH", "file::memory:")
+	db, err := initSQLite()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return &gormStore{db: db}
+}
+
+// storedSeeds returns the seeds recorded per URL, sorted.
+func storedSeeds(t *testing.T, store *gormStore) map[string][]string {
+	t.Helper()
+	var rows []ProductURLSeed
+	if err := store.db.Order("url").Order("seed").Find(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	seeds := make(map[string][]string)
+	for _, row := range rows {
+		seeds[row.URL] = append(seeds[row.URL], row.Seed)
+	}
+	return seeds
+}
+
+func TestOverlappingSeedsStoreOneRowPerURL(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	c := newTestCrawler(t)
+	c.store = store
+	phones, mobiles := "https://www.amazon.in/s?k=phone", "https://www.amazon.in/s?k=mobile"
+	shared := "https://www.amazon.in/dp/B0BBBBBBBB/"
+
+	c.storeProductURLs(ctx, []string{"https://www.amazon.in/dp/B0AAAAAAAA/", shared}, phones, phones)
+	c.storeProductURLs(ctx, []string{shared, "https://www.amazon.in/dp/B0CCCCCCCC/", shared}, mobiles, mobiles)
+
+	records, err := store.Records(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var urls []string
+	for _, record := range records {
+		urls = append(urls, record.URL)
+		if record.URL == shared && (record.SeenCount != 2 || record.SourceURL != phones) {
+			t.Errorf("shared URL: seen %d, source %q; want 2 and the first source", record.SeenCount, record.SourceURL)
+		}
+	}
+	wantURLs := []string{"https://www.amazon.in/dp/B0AAAAAAAA/", shared, "https://www.amazon.in/dp/B0CCCCCCCC/"}
+	if !reflect.DeepEqual(urls, wantURLs) {
+		t.Errorf("stored URLs = %v, want one row each for %v", urls, wantURLs)
+	}
+
+	wantSeeds := map[string][]string{
+		"https://www.amazon.in/dp/B0AAAAAAAA/": {phones},
+		shared:                                 {mobiles, phones},
+		"https://www.amazon.in/dp/B0CCCCCCCC/": {mobiles},
+	}
+	if got := storedSeeds(t, store); !reflect.DeepEqual(got, wantSeeds) {
+		t.Errorf("stored seeds = %v, want %v", got, wantSeeds)
+	}
+	if got := c.seeds.Report(); !reflect.DeepEqual(got, wantSeeds) {
+		t.Errorf("seed report = %v, want %v", got, wantSeeds)
+	}
+}
+
+func TestStoreSaveUpsertsByURL(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"memory": func(*testing.T) Store { return newMemoryStore() },
+		"sqlite": func(t *testing.T) Store { return newSQLiteStore(t) },
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+			phone, laptop, tablet := "https://www.amazon.in/dp/B0AAAAAAAA/", "https://www.amazon.in/dp/B0BBBBBBBB/", "https://www.amazon.in/dp/B0CCCCCCCC/"
+
+			err := store.Save(ctx, []ProductURL{
+				{URL: phone, Domain: "www.amazon.in", SourceURL: "https://www.amazon.in/s?k=phone"},
+				{URL: laptop, Domain: "www.amazon.in", SourceURL: "https://www.amazon.in/s?k=laptop"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			first, err := store.Records(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = store.Save(ctx, []ProductURL{{URL: phone, Domain: "www.amazon.in", SourceURL: "https://www.amazon.in/s?k=mobile"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for url, want := range map[string]bool{phone: true, laptop: true, tablet: false} {
+				if got, err := store.Exists(ctx, url); err != nil || got != want {
+					t.Errorf("Exists(%s) = %v, %v; want %v", url, got, err, want)
+				}
+			}
+			existing, err := store.ExistingURLs(ctx, []string{phone, tablet, laptop})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := map[string]bool{phone: true, laptop: true}; !reflect.DeepEqual(existing, want) {
+				t.Errorf("ExistingURLs = %v, want %v", existing, want)
+			}
+
+			records, err := store.Records(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != 2 {
+				t.Fatalf("stored %d records, want one per URL", len(records))
+			}
+			for i, record := range records {
+				wantSeen := map[string]int{phone: 2, laptop: 1}[record.URL]
+				if record.SeenCount != wantSeen {
+					t.Errorf("%s seen %d times, want %d", record.URL, record.SeenCount, wantSeen)
+				}
+				if record.URL == phone {
+					if record.SourceURL != "https://www.amazon.in/s?k=phone" {
+						t.Errorf("source = %q, want the page that first found the URL", record.SourceURL)
+					}
+					if !record.LastSeen.After(first[i].LastSeen) {
+						t.Errorf("LastSeen %v not refreshed from %v", record.LastSeen, first[i].LastSeen)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestGormStoreSaveFoldsRepeatsAndKeepsSeeds(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	phone := "https://www.snapdeal.com/product/redmi-12-5g-4gb/677361127342"
+	phones, mobiles := "https://www.snapdeal.com/products/mobiles", "https://www.snapdeal.com/search?keyword=phone"
+
+	// One batch repeating a URL under two seeds, as a crawl of overlapping
+	// seeds hands it over.
+	err := store.Save(ctx, []ProductURL{
+		{URL: phone, Domain: "www.snapdeal.com", SourceURL: phones, Seed: phones},
+		{URL: phone, Domain: "www.snapdeal.com", SourceURL: mobiles, Seed: mobiles},
+		{URL: phone, Domain: "www.snapdeal.com", SourceURL: mobiles, Seed: mobiles},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := store.Records(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].SeenCount != 1 || records[0].SourceURL != phones {
+		t.Errorf("records = %+v, want one row seen once from the first source", records)
+	}
+	if got, want := storedSeeds(t, store), map[string][]string{phone: {phones, mobiles}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("stored seeds = %v, want %v", got, want)
+	}
+}
+
+func TestStoreProductURLsWithSQLite(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCrawler(t)
+	c.store = newSQLiteStore(t)
+	source := "https://www.myntra.com/men-shirts"
+	urls := []string{
+		"https://www.myntra.com/shirts/roadster/roadster-men-navy-slim-fit-casual-shirt/11352730/buy",
+		"https://www.myntra.com/shirts/wrogn/wrogn-men-black-slim-fit-shirt/13421598/buy",
+	}
+
+	if got := c.storeProductURLs(ctx, urls, source, source); got != 2 {
+		t.Errorf("first page: %d new URLs, want 2", got)
+	}
+	if got := c.storeProductURLs(ctx, append(urls[1:], "https://m.myntra.com/shirts/here-now/here-and-now-men-olive-shirt/17428370/buy"), source, source); got != 1 {
+		t.Errorf("second page: %d new URLs, want 1", got)
+	}
+	records, err := c.store.Records(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	domains := make(map[string]string)
+	for _, record := range records {
+		domains[record.URL] = record.Domain
+	}
+	want := map[string]string{
+		urls[0]: "www.myntra.com",
+		urls[1]: "www.myntra.com",
+		"https://m.myntra.com/shirts/here-now/here-and-now-men-olive-shirt/17428370/buy": "m.myntra.com",
+	}
+	if !reflect.DeepEqual(domains, want) {
+		t.Errorf("stored domains = %v, want %v", domains, want)
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// --- schema.org Structured Data ---
+// Most shops describe their product pages for search engines, so name,
+// price, availability and ratings can be read without per-site selectors.
+// Sources are tried in order of reliability: JSON-LD, then microdata, then
+// OpenGraph product tags; a later source only fills fields an earlier one
+// left empty. Configured selectors are the fallback for whatever is still
+// missing.
+type structuredProduct struct {
+	Name         string
+	Brand        string
+	SKU          string
+	Image        string
+	Price        *float64
+	PriceRaw     string // the price as written in the markup
+	Currency     string
+	Availability string // schema.org ItemAvailability, e.g. "InStock"
+	Rating       *float64
+	ReviewCount  *int
+}
+
+// --- Extract Structured Product Data ---
+func extractStructuredData(doc *goquery.Document) structuredProduct {
+	var sp structuredProduct
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		var data any
+		if json.Unmarshal([]byte(s.Text()), &data) != nil {
+			return true
+		}
+		if item := findJSONType(data, "Product"); item != nil {
+			sp.fromJSONLD(item)
+			return false
+		}
+		return true
+	})
+	if item := doc.Find(`[itemscope][itemtype$="schema.org/Product"]`).First(); item.Length() > 0 {
+		sp.fromMicrodata(item)
+	}
+	sp.fromOpenGraph(doc)
+	return sp
+}
+
+// --- Find a JSON-LD Node by @type ---
+// Searches nested objects, arrays and @graph lists; @type may be a string
+// or a list of types.
+func findJSONType(data any, typeName string) map[string]any {
+	switch v := data.(type) {
+	case map[string]any:
+		switch t := v["@type"].(type) {
+		case string:
+			if t == typeName {
+				return v
+			}
+		case []any:
+			for _, name := range t {
+				if name == typeName {
+					return v
+				}
+			}
+		}
+		for _, child := range v {
+			if found := findJSONType(child, typeName); found != nil {
+				return found
+			}
+		}
+	case []any:
+		for _, child := range v {
+			if found := findJSONType(child, typeName); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+func (sp *structuredProduct) fromJSONLD(item map[string]any) {
+	sp.Name = jsonName(item["name"])
+	sp.Brand = jsonName(item["brand"])
+	sp.SKU = jsonScalar(item["sku"])
+	sp.Image = jsonImage(item["image"])
+
+	// Offers may be one Offer, a list of them or an AggregateOffer, whose
+	// lowPrice stands in for the price.
+	if offers := item["offers"]; offers != nil {
+		price := findJSONKey(offers, "price")
+		if price == nil {
+			price = findJSONKey(offers, "lowPrice")
+		}
+		sp.setPrice(jsonScalar(price))
+		sp.Currency = jsonScalar(findJSONKey(offers, "priceCurrency"))
+		sp.Availability = schemaEnum(jsonScalar(findJSONKey(offers, "availability")))
+	}
+	if rating := item["aggregateRating"]; rating != nil {
+		sp.Rating = jsonAmount(findJSONKey(rating, "ratingValue"))
+		count := findJSONKey(rating, "reviewCount")
+		if count == nil {
+			count = findJSONKey(rating, "ratingCount")
+		}
+		if amount := jsonAmount(count); amount != nil {
+			reviews := int(*amount)
+			sp.ReviewCount = &reviews
+		}
+	}
+}
+
+// fromMicrodata reads itemprop values, preferring the machine-readable
+// content attribute and, for links such as availability, href.
+func (sp *structuredProduct) fromMicrodata(item *goquery.Selection) {
+	prop := func(name string) string {
+		el := item.Find(`[itemprop="` + name + `"]`).First()
+		if el.Length() == 0 {
+			return ""
+		}
+		for _, attr := range []string{"content", "href"} {
+			if value, ok := el.Attr(attr); ok {
+				return strings.TrimSpace(value)
+			}
+		}
+		return strings.TrimSpace(el.Text())
+	}
+	if sp.Name == "" {
+		sp.Name = prop("name")
+	}
+	if sp.Brand == "" {
+		sp.Brand = prop("brand")
+	}
+	if sp.SKU == "" {
+		sp.SKU = prop("sku")
+	}
+	if sp.Price == nil {
+		sp.setPrice(prop("price"))
+	}
+	if sp.Currency == "" {
+		sp.Currency = prop("priceCurrency")
+	}
+	if sp.Availability == "" {
+		sp.Availability = schemaEnum(prop("availability"))
+	}
+	if sp.Rating == nil {
+		if rating, err := strconv.ParseFloat(prop("ratingValue"), 64); err == nil {
+			sp.Rating = &rating
+		}
+	}
+	if sp.ReviewCount == nil {
+		count := prop("reviewCount")
+		if count == "" {
+			count = prop("ratingCount")
+		}
+		if reviews, ok := parseCount(count); ok {
+			sp.ReviewCount = &reviews
+		}
+	}
+}
+
+func (sp *structuredProduct) fromOpenGraph(doc *goquery.Document) {
+	meta := func(property string) string {
+		return strings.TrimSpace(doc.Find(`meta[property="`+property+`"]`).First().AttrOr("content", ""))
+	}
+	if sp.Name == "" {
+		sp.Name = meta("og:title")
+	}
+	if sp.Image == "" {
+		sp.Image = meta("og:image")
+	}
+	if sp.Brand == "" {
+		sp.Brand = meta("product:brand")
+	}
+	if sp.Price == nil {
+		sp.setPrice(meta("product:price:amount"))
+		if sp.Price == nil {
+			sp.setPrice(meta("og:price:amount"))
+		}
+	}
+	if sp.Currency == "" {
+		sp.Currency = meta("product:price:currency")
+	}
+	if sp.Availability == "" {
+		sp.Availability = schemaEnum(meta("product:availability"))
+	}
+}
+
+// setPrice parses a machine-readable price, which always uses a decimal
+// point and no grouping.
+func (sp *structuredProduct) setPrice(raw string) {
+	if raw == "" {
+		return
+	}
+	if amount, err := strconv.ParseFloat(raw, 64); err == nil && amount >= 0 {
+		sp.Price, sp.PriceRaw = &amount, raw
+	}
+}
+
+// --- URL of a schema.org Image ---
+// Accepts a URL, a list of URLs or an ImageObject.
+func jsonImage(data any) string {
+	switch v := data.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case map[string]any:
+		return jsonImage(v["url"])
+	case []any:
+		if len(v) > 0 {
+			return jsonImage(v[0])
+		}
+	}
+	return ""
+}
+
+// --- Name of a schema.org Enumeration Member ---
+// "https://schema.org/InStock", "http://schema.org/InStock" and
+// "instock" all become "InStock"; OpenGraph's "in stock" and "oos" map to
+// their schema.org names too.
+func schemaEnum(value string) string {
+	value = strings.TrimSpace(value)
+	if i := strings.LastIndex(value, "/"); i >= 0 {
+		value = value[i+1:]
+	}
+	switch strings.ToLower(strings.ReplaceAll(value, " ", "")) {
+	case "":
+		return ""
+	case "instock":
+		return "InStock"
+	case "outofstock", "oos":
+		return "OutOfStock"
+	case "preorder":
+		return "PreOrder"
+	case "backorder":
+		return "BackOrder"
+	case "discontinued":
+		return "Discontinued"
+	case "limitedavailability":
+		return "LimitedAvailability"
+	case "soldout":
+		return "SoldOut"
+	}
+	return value
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// --- Per-Domain Crawl Statistics ---
+// New counts product URLs that were not yet in storage; every other URL
+// found on a page is a duplicate, whether of an earlier run or this one.
+type domainStats struct {
+	Pages      int `json:"pages"`
+	Found      int `json:"product_urls"`
+	New        int `json:"new"`
+	Duplicates int `json:"duplicates"`
+	Errors     int `json:"errors"`
+}
+
+func (d *domainStats) add(other domainStats) {
+	d.Pages += other.Pages
+	d.Found += other.Found
+	d.New += other.New
+	d.Duplicates += other.Duplicates
+	d.Errors += other.Errors
+}
+
+// --- Concurrency-Safe Run Statistics ---
+// Updated by every crawl goroutine and read once the crawl has finished.
+type crawlStats struct {
+	mu      sync.Mutex
+	started time.Time
+	domains map[string]*domainStats
+}
+
+func newCrawlStats() *crawlStats {
+	return &crawlStats{started: time.Now(), domains: make(map[string]*domainStats)}
+}
+
+func (s *crawlStats) update(host string, fn func(*domainStats)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.domains[host]
+	if !ok {
+		d = &domainStats{}
+		s.domains[host] = d
+	}
+	fn(d)
+}
+
+func (s *crawlStats) PageVisited(host string) {
+	s.update(host, func(d *domainStats) { d.Pages++ })
+}
+
+func (s *crawlStats) URLsFound(host string, found, fresh int) {
+	s.update(host, func(d *domainStats) {
+		d.Found += found
+		d.New += fresh
+		d.Duplicates += found - fresh
+	})
+}
+
+func (s *crawlStats) Error(host string) {
+	s.update(host, func(d *domainStats) { d.Errors++ })
+}
+
+// --- Crawl Summary ---
+type crawlSummary struct {
+	Elapsed        time.Duration          `json:"-"`
+	ElapsedSeconds float64                `json:"elapsed_seconds"`
+	TimeLimited    bool                   `json:"time_limited,omitempty"` // stopped by -max-runtime
+	Domains        map[string]domainStats `json:"domains"`
+	Total          domainStats            `json:"total"`
+}
+
+// --- Snapshot the Statistics ---
+func (s *crawlStats) Summary() crawlSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elapsed := time.Since(s.started).Round(time.Millisecond)
+	summary := crawlSummary{
+		Elapsed:        elapsed,
+		ElapsedSeconds: elapsed.Seconds(),
+		Domains:        make(map[string]domainStats, len(s.domains)),
+	}
+	for host, d := range s.domains {
+		summary.Domains[host] = *d
+		summary.Total.add(*d)
+	}
+	return summary
+}
+
+// --- Print the Summary as a Table ---
+func (s crawlSummary) Print(w io.Writer) {
+	hosts := make([]string, 0, len(s.Domains))
+	for host := range s.Domains {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "DOMAIN\tPAGES\tPRODUCT URLS\tNEW\tDUPLICATE\tERRORS\t")
+	row := func(name string, d domainStats) {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t\n", name, d.Pages, d.Found, d.New, d.Duplicates, d.Errors)
+	}
+	for _, host := range hosts {
+		row(host, s.Domains[host])
+	}
+	row("TOTAL", s.Total)
+	tw.Flush()
+	fmt.Fprintf(w, "Elapsed: %s\n", s.Elapsed)
+	if s.TimeLimited {
+		fmt.Fprintln(w, "Stopped early: -max-runtime reached; results are partial")
+	}
+}
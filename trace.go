@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// --- Per-Page Extraction Trace ---
+// With -trace every listing page logs one "Page trace" record telling
+// apart the ways a page can yield no product URLs: it failed to load, the
+// wait selector never showed up, it did not scroll, or the patterns did
+// not match. The trace travels in the context so the fetch path keeps its
+// signatures; without -trace traceFrom returns nil and recording is a
+// no-op.
+type pageTrace struct {
+	mu           sync.Mutex
+	url          string
+	finalURL     string
+	fetch        string // "http" or "chrome"
+	status       int    // HTTP status of the document, 0 if unknown
+	waitSelector string // "found" or "timeout" when the domain configures one
+	strategy     string // listing strategy chosen in Chrome
+	scrolls      int    // infinite-scroll passes performed
+	pages        int    // listing pages visited when paginating
+	htmlBytes    int
+	rawMatches   int // pattern matches before filtering and dedup
+	urls         int // product URLs kept
+	err          string
+}
+
+type pageTraceKey struct{}
+
+// --- Start a Page Trace ---
+// Returns ctx unchanged and a nil trace when tracing is off.
+func (c *Crawler) startTrace(ctx context.Context, url string) (context.Context, *pageTrace) {
+	if !c.trace {
+		return ctx, nil
+	}
+	trace := &pageTrace{url: url}
+	return context.WithValue(ctx, pageTraceKey{}, trace), trace
+}
+
+func traceFrom(ctx context.Context) *pageTrace {
+	trace, _ := ctx.Value(pageTraceKey{}).(*pageTrace)
+	return trace
+}
+
+// record applies fn under the lock; the browser reports the status from
+// its own goroutine.
+func (t *pageTrace) record(fn func(t *pageTrace)) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fn(t)
+}
+
+func (t *pageTrace) log() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	slog.Info("Page trace",
+		"url", t.url,
+		"final_url", t.finalURL,
+		"fetch", t.fetch,
+		"status", t.status,
+		"wait_selector", t.waitSelector,
+		"strategy", t.strategy,
+		"scrolls", t.scrolls,
+		"pages", t.pages,
+		"html_bytes", t.htmlBytes,
+		"raw_matches", t.rawMatches,
+		"urls", t.urls,
+		"error", t.err,
+	)
+}
+
+// --- Count Raw Pattern Matches ---
+// Every match of every product pattern, before domain, non-product and
+// duplicate filtering.
+func rawMatchCount(htmlContent string, cfg DomainConfig) int {
+	count := 0
+	for _, pattern := range cfg.productPatterns() {
+		count += len(pattern.FindAllStringIndex(htmlContent, -1))
+	}
+	return count
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// --- Visited Page Tracking ---
+// VisitedSet remembers which page URLs have already been crawled. Claim
+// checks and marks in one atomic step, so when several workers race on the
+// same URL exactly one of them wins it.
+type VisitedSet interface {
+	IsVisited(ctx context.Context, url string) bool
+	MarkVisited(ctx context.Context, url string)
+	Claim(ctx context.Context, url string) bool
+}
+
+// --- Initialize Redis Client ---
+// REDIS_PASSWORD, REDIS_DB and REDIS_TLS=true are optional, for managed
+// Redis instances; unset they keep the local defaults.
+func initRedis() (*redis.Client, error) {
+	redisAddr := os.Getenv("REDIS_ADDR")
+	if redisAddr == "" {
+		return nil, fmt.Errorf("REDIS_ADDR is missing in .env file")
+	}
+
+	opts := &redis.Options{Addr: redisAddr, Password: os.Getenv("REDIS_PASSWORD")}
+	if value := os.Getenv("REDIS_DB"); value != "" {
+		db, err := strconv.Atoi(value)
+		if err != nil || db < 0 {
+			return nil, fmt.Errorf("REDIS_DB %q must be a non-negative integer", value)
+		}
+		opts.DB = db
+	}
+	if value := os.Getenv("REDIS_TLS"); value != "" {
+		useTLS, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("REDIS_TLS %q must be true or false", value)
+		}
+		if useTLS {
+			host, _, _ := net.SplitHostPort(redisAddr)
+			opts.TLSConfig = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+		}
+	}
+
+	redisClient := redis.NewClient(opts)
+	_, err := redisClient.Ping(context.Background()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("connect to Redis: %w", err)
+	}
+
+	slog.Info("Redis connected successfully", "addr", redisAddr)
+	return redisClient, nil
+}
+
+// --- URL Fingerprints ---
+// Visited marks are keyed by a hash of the normalized URL rather than the
+// URL itself, so spellings of one page share a mark and long URLs do not
+// bloat Redis keys. Normalizing lower-cases scheme and host, drops default
+// ports and the fragment, sorts the query and gives an empty path "/".
+func urlFingerprint(rawURL string) string {
+	sum := sha256.Sum256([]byte(normalizeVisitedURL(rawURL)))
+	return hex.EncodeToString(sum[:16])
+}
+
+func normalizeVisitedURL(rawURL string) string {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || parsed.Host == "" {
+		return rawURL
+	}
+	normalizeURL(parsed)
+	if parsed.Path == "" {
+		parsed.Path = "/"
+	}
+	parsed.RawQuery = parsed.Query().Encode()
+	return parsed.String()
+}
+
+// --- Redis Visited Set ---
+// Marks live at <namespace>:<fingerprint>, so crawls with different
+// -visited-namespace values keep separate visited sets in one Redis.
+// Commands that fail because Redis dropped are retried through conn, so a
+// brief outage neither re-crawls visited pages nor loses visited marks.
+// Once conn gives up, abort stops the run: pages count as visited and
+// nothing is claimed, since crawling on would re-crawl everything.
+type redisVisitedSet struct {
+	client    *redis.Client
+	conn      *reconnector
+	namespace string
+	abort     context.CancelCauseFunc // set by main; nil leaves the run going
+}
+
+func newRedisVisitedSet(client *redis.Client, namespace string) *redisVisitedSet {
+	return &redisVisitedSet{
+		client:    client,
+		namespace: namespace,
+		conn: newReconnector("Redis", *maxReconnects, func(ctx context.Context) error {
+			return client.Ping(ctx).Err()
+		}),
+	}
+}
+
+func (v *redisVisitedSet) key(url string) string {
+	return v.namespace + ":" + urlFingerprint(url)
+}
+
+// --- Check if URL is Already Visited (Redis) ---
+func (v *redisVisitedSet) IsVisited(ctx context.Context, url string) bool {
+	var exists int64
+	err := v.conn.Do(ctx, func() (err error) {
+		exists, err = v.client.Exists(ctx, v.key(url)).Result()
+		return err
+	})
+	if v.unreachable(err) {
+		return true
+	}
+	if err != nil {
+		slog.Warn("Redis error", "url", url, "error", err)
+		return false
+	}
+	return exists > 0
+}
+
+// --- Mark URL as Visited (Redis) ---
+func (v *redisVisitedSet) MarkVisited(ctx context.Context, url string) {
+	err := v.conn.Do(ctx, func() error {
+		return v.client.Set(ctx, v.key(url), 1, redisExpiry).Err()
+	})
+	if err != nil {
+		v.unreachable(err)
+		slog.Warn("Failed to mark URL as visited", "url", url, "error", err)
+	}
+}
+
+// --- Claim URL for Crawling (Redis) ---
+// SETNX makes the claim atomic across processes sharing the Redis. On a
+// non-connection Redis error the URL is claimed, matching IsVisited's
+// fail-open behavior. A cancelled crawl claims nothing.
+func (v *redisVisitedSet) Claim(ctx context.Context, url string) bool {
+	var claimed bool
+	err := v.conn.Do(ctx, func() (err error) {
+		claimed, err = v.client.SetNX(ctx, v.key(url), 1, redisExpiry).Result()
+		return err
+	})
+	if ctx.Err() != nil || v.unreachable(err) {
+		return false
+	}
+	if err != nil {
+		slog.Warn("Failed to claim URL", "url", url, "error", err)
+		return true
+	}
+	return claimed
+}
+
+// --- Stop the Run Once Redis Is Given Up On ---
+// Reports whether err is conn giving up.
+func (v *redisVisitedSet) unreachable(err error) bool {
+	if !errors.Is(err, errUnreachable) {
+		return false
+	}
+	if v.abort != nil {
+		v.abort(err)
+	}
+	return true
+}
+
+// --- Load Visited Fingerprints from Redis ---
+// Scans the namespace's keys so a Bloom filter can be populated at startup.
+func (v *redisVisitedSet) Each(ctx context.Context, fn func(fingerprint string)) error {
+	prefix := v.namespace + ":"
+	iter := v.client.Scan(ctx, 0, prefix+"*", 1000).Iterator()
+	for iter.Next(ctx) {
+		fn(strings.TrimPrefix(iter.Val(), prefix))
+	}
+	return iter.Err()
+}
+
+// --- In-Memory Visited Set ---
+// memoryVisitedSet is a process-local VisitedSet for tests and runs
+// without Redis. Marks expire after redisExpiry, like the Redis keys.
+type memoryVisitedSet struct {
+	mu   sync.Mutex
+	urls map[string]time.Time // URL fingerprint -> when it was marked
+}
+
+func newMemoryVisitedSet() *memoryVisitedSet {
+	return &memoryVisitedSet{urls: make(map[string]time.Time)}
+}
+
+func (v *memoryVisitedSet) IsVisited(_ context.Context, url string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.visited(urlFingerprint(url))
+}
+
+func (v *memoryVisitedSet) MarkVisited(_ context.Context, url string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.urls[urlFingerprint(url)] = time.Now()
+}
+
+func (v *memoryVisitedSet) Claim(_ context.Context, url string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	fingerprint := urlFingerprint(url)
+	if v.visited(fingerprint) {
+		return false
+	}
+	v.urls[fingerprint] = time.Now()
+	return true
+}
+
+// visited reports an unexpired mark; v.mu must be held.
+func (v *memoryVisitedSet) visited(fingerprint string) bool {
+	markedAt, ok := v.urls[fingerprint]
+	if ok && time.Since(markedAt) >= redisExpiry {
+		delete(v.urls, fingerprint)
+		return false
+	}
+	return ok
+}
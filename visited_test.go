@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/go-redis/redismock/v9"
+)
+
+func TestNormalizeVisitedURL(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{"already normal", "https://www.amazon.in/dp/B0AAAAAAAA/", "https://www.amazon.in/dp/B0AAAAAAAA/"},
+		{"scheme and host case", "HTTPS://WWW.Amazon.IN/dp/B0AAAAAAAA/", "https://www.amazon.in/dp/B0AAAAAAAA/"},
+		{"default port", "https://www.amazon.in:443/dp/B0AAAAAAAA/", "https://www.amazon.in/dp/B0AAAAAAAA/"},
+		{"fragment", "https://www.amazon.in/dp/B0AAAAAAAA/#reviews", "https://www.amazon.in/dp/B0AAAAAAAA/"},
+		{"query order", "https://www.snapdeal.com/search?sort=plrty&keyword=phone", "https://www.snapdeal.com/search?keyword=phone&sort=plrty"},
+		{"empty path", "https://www.myntra.com", "https://www.myntra.com/"},
+		{"surrounding space", " https://www.myntra.com/shirts ", "https://www.myntra.com/shirts"},
+		{"path case kept", "https://www.myntra.com/Shirts", "https://www.myntra.com/Shirts"},
+		{"no host", "/dp/B0AAAAAAAA/", "/dp/B0AAAAAAAA/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeVisitedURL(tt.url); got != tt.want {
+				t.Errorf("normalizeVisitedURL(%q) = %q, want %q", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryVisitedSetSharesMarksAcrossSpellings(t *testing.T) {
+	ctx := context.Background()
+	visited := newMemoryVisitedSet()
+
+	if !visited.Claim(ctx, "https://www.snapdeal.com/search?keyword=phone&sort=plrty") {
+		t.Fatal("first claim failed")
+	}
+	for _, spelling := range []string{
+		"https://www.snapdeal.com/search?keyword=phone&sort=plrty",
+		"HTTPS://www.SNAPDEAL.com:443/search?sort=plrty&keyword=phone#top",
+	} {
+		if !visited.IsVisited(ctx, spelling) {
+			t.Errorf("IsVisited(%q) = false after claiming another spelling", spelling)
+		}
+		if visited.Claim(ctx, spelling) {
+			t.Errorf("Claim(%q) succeeded twice", spelling)
+		}
+	}
+
+	other := "https://www.snapdeal.com/search?keyword=laptop"
+	if visited.IsVisited(ctx, other) {
+		t.Errorf("IsVisited(%q) = true before any mark", other)
+	}
+	visited.MarkVisited(ctx, other)
+	if visited.Claim(ctx, other) {
+		t.Errorf("Claim(%q) succeeded after MarkVisited", other)
+	}
+}
+
+func TestRedisVisitedSet(t *testing.T) {
+	ctx := context.Background()
+	client, mock := redismock.NewClientMock()
+	visited := newRedisVisitedSet(client, "crawl-a")
+	url := "https://www.myntra.com/shirts/roadster/slim-fit-shirt/11352730/buy"
+	key := "crawl-a:" + urlFingerprint(url)
+
+	mock.ExpectSetNX(key, 1, redisExpiry).SetVal(true)
+	mock.ExpectSetNX(key, 1, redisExpiry).SetVal(false)
+	mock.ExpectExists(key).SetVal(1)
+	mock.ExpectSet(key, 1, redisExpiry).SetVal("OK")
+	mock.ExpectSetNX(key, 1, redisExpiry).SetErr(errors.New("ERR unknown command"))
+	mock.ExpectScan(0, "crawl-a:*", 1000).SetVal([]string{key}, 0)
+
+	if !visited.Claim(ctx, url) {
+		t.Error("first claim failed")
+	}
+	// Another spelling of the page shares its key.
+	if visited.Claim(ctx, "HTTPS://WWW.MYNTRA.COM/shirts/roadster/slim-fit-shirt/11352730/buy#reviews") {
+		t.Error("second claim succeeded")
+	}
+	if !visited.IsVisited(ctx, url) {
+		t.Error("IsVisited = false for a claimed URL")
+	}
+	visited.MarkVisited(ctx, url)
+	if !visited.Claim(ctx, url) {
+		t.Error("claim on a Redis error = false, want fail-open")
+	}
+	var fingerprints []string
+	if err := visited.Each(ctx, func(fingerprint string) { fingerprints = append(fingerprints, fingerprint) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(fingerprints) != 1 || fingerprints[0] != urlFingerprint(url) {
+		t.Errorf("Each = %v, want the namespace's one fingerprint", fingerprints)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRedisVisitedSetStopsRunWhenUnreachable(t *testing.T) {
+	setGlobal(t, maxReconnects, 1)
+	ctx, abort := context.WithCancelCause(context.Background())
+	defer abort(nil)
+	client, mock := redismock.NewClientMock()
+	visited := newRedisVisitedSet(client, "crawl-a")
+	visited.abort = abort
+	url := "https://www.myntra.com/shirts/roadster/slim-fit-shirt/11352730/buy"
+
+	mock.ExpectSetNX("crawl-a:"+urlFingerprint(url), 1, redisExpiry).SetErr(io.EOF)
+	mock.ExpectPing().SetErr(io.EOF)
+	if visited.Claim(ctx, url) {
+		t.Error("claimed a URL with Redis unreachable")
+	}
+	if cause := context.Cause(ctx); !errors.Is(cause, errUnreachable) {
+		t.Errorf("run stopped with %v, want errUnreachable", cause)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWorkQueue(t *testing.T) {
+	ctx := context.Background()
+	client, mock := redismock.NewClientMock()
+	queue := newWorkQueue(client, "crawl")
+	phones := queueJob{Kind: jobListing, URL: "https://www.snapdeal.com/products/mobiles", Seed: "https://www.snapdeal.com/products/mobiles"}
+	data, err := json.Marshal(phones)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectSAdd("crawl:queued", phones.URL).SetVal(1)
+	mock.ExpectLPush("crawl:pending", data).SetVal(1)
+	mock.ExpectSAdd("crawl:queued", phones.URL).SetVal(0)
+	mock.ExpectExpire("crawl:queued", redisExpiry).SetVal(true)
+	// The lease deadline depends on the clock, so only the keys are matched.
+	leaseKeys := func(expected, actual []any) error {
+		if !reflect.DeepEqual(expected[:5], actual[:5]) {
+			return fmt.Errorf("EVALSHA %v, want %v", actual, expected)
+		}
+		return nil
+	}
+	mock.CustomMatch(leaseKeys).ExpectEvalSha(popAndLease.Hash(), []string{"crawl:pending", "crawl:leased"}, 0).SetVal(string(data))
+	mock.ExpectZRem("crawl:leased", string(data)).SetVal(1)
+	mock.ExpectLLen("crawl:pending").SetVal(0)
+	mock.ExpectZCard("crawl:leased").SetVal(0)
+	mock.CustomMatch(leaseKeys).ExpectEvalSha(popAndLease.Hash(), []string{"crawl:pending", "crawl:leased"}, 0).RedisNil()
+
+	if err := queue.Push(ctx, phones, phones); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	job, raw, ok, err := queue.Pop(ctx)
+	if err != nil || !ok || job != phones {
+		t.Fatalf("Pop = %+v, %v, %v; want the pushed job", job, ok, err)
+	}
+	queue.Done(ctx, raw)
+	if drained, err := queue.Drained(ctx); err != nil || !drained {
+		t.Errorf("Drained = %v, %v; want true", drained, err)
+	}
+	if _, _, ok, err := queue.Pop(ctx); ok || err != nil {
+		t.Errorf("Pop on an empty queue = %v, %v; want nothing", ok, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
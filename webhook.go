@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// --- Webhook Delivery Settings ---
+const (
+	webhookAttempts = 3
+	webhookBackoff  = 2 * time.Second // doubled after each failed attempt
+	webhookTimeout  = 10 * time.Second
+)
+
+// --- Run Completion Payload ---
+type webhookPayload struct {
+	RunID            string    `json:"run_id"`
+	StartedAt        time.Time `json:"started_at"`
+	FinishedAt       time.Time `json:"finished_at"`
+	Interrupted      bool      `json:"interrupted"`
+	TimeLimited      bool      `json:"time_limited"`
+	DryRun           bool      `json:"dry_run"`
+	Pages            int       `json:"pages"`
+	URLs             int       `json:"urls"`
+	New              int       `json:"new"`
+	Duplicates       int       `json:"duplicates"`
+	Errors           int       `json:"errors"`
+	Products         int       `json:"products"`
+	RegionRestricted int       `json:"region_restricted"`
+	Output           string    `json:"output"`
+}
+
+// --- Generate a Run ID ---
+func newRunID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().UTC().Format("20060102T150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
+
+// --- Summarize Results for the Webhook ---
+func newWebhookPayload(runID string, startedAt time.Time, summary crawlSummary, results []CrawlResult, output string) webhookPayload {
+	payload := webhookPayload{
+		RunID:      runID,
+		StartedAt:  startedAt,
+		FinishedAt: time.Now().UTC(),
+		Pages:      summary.Total.Pages,
+		URLs:       summary.Total.Found,
+		New:        summary.Total.New,
+		Duplicates: summary.Total.Duplicates,
+		Errors:     summary.Total.Errors,
+		Output:     output,
+	}
+	for _, res := range results {
+		payload.Products += len(res.Products)
+		if res.RegionRestricted {
+			payload.RegionRestricted++
+		}
+	}
+	return payload
+}
+
+// --- Notify the Webhook ---
+// Non-2xx responses and transport errors are retried with exponential
+// backoff. Delivery failures are only logged; they never fail the crawl.
+func notifyWebhook(url string, payload webhookPayload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("Failed to encode webhook payload", "error", err)
+		return
+	}
+
+	client := &http.Client{Timeout: webhookTimeout}
+	backoff := webhookBackoff
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		err = postWebhook(client, url, body)
+		if err == nil {
+			slog.Info("Webhook notified", "url", url, "run_id", payload.RunID)
+			return
+		}
+		slog.Warn("Webhook delivery failed", "url", url, "attempt", attempt, "error", err)
+		if attempt < webhookAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+	slog.Error("Giving up on webhook", "url", url, "run_id", payload.RunID, "attempts", webhookAttempts)
+}
+
+func postWebhook(client *http.Client, url string, body []byte) error {
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// --- Buffer Writes Through Database Outages ---
+// When Postgres stays unreachable after the reconnect attempts, the rows
+// of a failed batch are kept in memory instead of ending the crawl. Every
+// later write first checks whether the database is back and, if so,
+// flushes the buffered rows in their original order. An outage lasting
+// longer than DB_SPILL_AFTER (default 5m) spills the buffer to a JSON file
+// in DB_SPILL_DIR (default "spill") so memory stays bounded; rows still
+// buffered when the crawler exits are spilled as well.
+const (
+	defaultSpillAfter = 5 * time.Minute
+	defaultSpillDir   = "spill"
+	bufferPingTimeout = 2 * time.Second
+)
+
+// errUnreachable marks a backend the reconnector gave up on.
+var errUnreachable = errors.New("backend unreachable")
+
+type writeBuffer struct {
+	mu         sync.Mutex
+	urls       []ProductURL
+	products   []Product
+	since      time.Time // first buffered write of the current outage
+	flushing   bool      // a flush has taken the rows out to write them
+	spillAfter time.Duration
+	spillDir   string
+}
+
+// spillFile is the layout of a spill file.
+type spillFile struct {
+	URLs     []ProductURL `json:"urls,omitempty"`
+	Products []Product    `json:"products,omitempty"`
+}
+
+func writeBufferFromEnv() (*writeBuffer, error) {
+	buffer := &writeBuffer{spillAfter: defaultSpillAfter, spillDir: defaultSpillDir}
+	if value := os.Getenv("DB_SPILL_AFTER"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("DB_SPILL_AFTER %q must be a positive duration", value)
+		}
+		buffer.spillAfter = d
+	}
+	if value := os.Getenv("DB_SPILL_DIR"); value != "" {
+		buffer.spillDir = value
+	}
+	return buffer, nil
+}
+
+// --- Write a Batch, Buffering on Outage ---
+// A batch that fails because the database is unreachable, or because the
+// crawl was cancelled mid-write, is buffered and reported as written.
+func (s *gormStore) write(ctx context.Context, urls []ProductURL, products []Product) error {
+	if s.buffer == nil {
+		return s.writeBatch(ctx, urls, products)
+	}
+	if s.buffer.outage() && !s.flush(ctx) {
+		s.buffer.add(urls, products)
+		return nil
+	}
+
+	err := s.writeBatch(ctx, urls, products)
+	if errors.Is(err, errUnreachable) || ctx.Err() != nil {
+		slog.Warn("Database write failed, buffering rows", "urls", len(urls), "products", len(products), "error", err)
+		s.buffer.add(urls, products)
+		return nil
+	}
+	return err
+}
+
+// writeBatch stores URLs and products in one transaction, so a retried
+// batch is never half applied.
+func (s *gormStore) writeBatch(ctx context.Context, urls []ProductURL, products []Product) error {
+	return s.conn.Do(ctx, func() error {
+		return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := s.save(tx, urls); err != nil {
+				return err
+			}
+			return s.saveProducts(tx, products)
+		})
+	})
+}
+
+// --- Flush Buffered Rows ---
+// Reports whether the buffer is empty afterwards. A single quick health
+// check decides whether to try, so writes during an outage do not each
+// wait out the full reconnect backoff. The rows are taken out of the
+// buffer while they are written, so other writers keep buffering instead
+// of waiting on mu, and put back in front of any added meanwhile when the
+// write fails. Only one flush runs at a time.
+func (s *gormStore) flush(ctx context.Context) bool {
+	b := s.buffer
+	b.mu.Lock()
+	if b.flushing {
+		b.mu.Unlock()
+		return false
+	}
+	if len(b.urls) == 0 && len(b.products) == 0 {
+		b.mu.Unlock()
+		return true
+	}
+	urls, products, since := b.urls, b.products, b.since
+	b.urls, b.products, b.flushing = nil, nil, true
+	b.mu.Unlock()
+
+	pingCtx, cancel := context.WithTimeout(ctx, bufferPingTimeout)
+	err := s.conn.ping(pingCtx)
+	cancel()
+	if err == nil {
+		err = s.writeBatch(ctx, urls, products)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.flushing = false
+	if err != nil {
+		b.urls = append(urls, b.urls...)
+		b.products = append(products, b.products...)
+		b.since = since
+		return time.Since(b.since) >= b.spillAfter && b.spill()
+	}
+
+	slog.Info("Database is back, flushed buffered rows", "urls", len(urls), "products", len(products), "outage", time.Since(since).Round(time.Second))
+	if len(b.urls) > 0 || len(b.products) > 0 {
+		return false
+	}
+	b.reset()
+	return true
+}
+
+// Close makes a last attempt to flush buffered rows and spills whatever
+// remains.
+func (s *gormStore) Close() error {
+	if s.buffer == nil || !s.buffer.outage() {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), reconnectBackoffMax)
+	defer cancel()
+	if s.flush(ctx) {
+		return nil
+	}
+	s.buffer.mu.Lock()
+	defer s.buffer.mu.Unlock()
+	s.buffer.spill()
+	return nil
+}
+
+// outage also holds while a flush is writing, so writers queue behind the
+// rows being flushed and keep their order.
+func (b *writeBuffer) outage() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.flushing || len(b.urls) > 0 || len(b.products) > 0
+}
+
+func (b *writeBuffer) add(urls []ProductURL, products []Product) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.urls) == 0 && len(b.products) == 0 {
+		b.since = time.Now()
+	}
+	b.urls = append(b.urls, urls...)
+	b.products = append(b.products, products...)
+}
+
+func (b *writeBuffer) reset() {
+	b.urls, b.products, b.since = nil, nil, time.Time{}
+}
+
+// --- Spill Buffered Rows to Disk ---
+// The caller holds mu. When the file cannot be written the rows stay
+// buffered for the next attempt and false is returned.
+func (b *writeBuffer) spill() bool {
+	path := filepath.Join(b.spillDir, "spill-"+time.Now().UTC().Format("20060102T150405.000000000")+".json")
+	if err := writeJSONFile(path, spillFile{URLs: b.urls, Products: b.products}, false); err != nil {
+		slog.Error("Failed to spill buffered rows", "path", path, "urls", len(b.urls), "products", len(b.products), "error", err)
+		return false
+	}
+	slog.Warn("Database unavailable, spilled buffered rows", "path", path, "urls", len(b.urls), "products", len(b.products))
+	b.reset()
+	return true
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Writers keep buffering while a flush waits on the database, and rows
+// written meanwhile queue behind the flushed ones.
+func TestFlushDoesNotBlockWriters(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	store.buffer = &writeBuffer{spillAfter: time.Hour, spillDir: t.TempDir()}
+	pinged, answer := make(chan struct{}), make(chan error)
+	store.conn = newReconnector("database", 0, func(context.Context) error {
+		pinged <- struct{}{}
+		return <-answer
+	})
+	row := func(id string) ProductURL {
+		return ProductURL{URL: "https://www.amazon.in/dp/B0" + id + "/", Domain: "www.amazon.in"}
+	}
+	buffered := func() []string {
+		store.buffer.mu.Lock()
+		defer store.buffer.mu.Unlock()
+		var urls []string
+		for _, u := range store.buffer.urls {
+			urls = append(urls, u.URL)
+		}
+		return urls
+	}
+	store.buffer.add([]ProductURL{row("AAAAAAAA")}, nil)
+
+	// flushWhile runs a flush and, while its health check hangs, saves
+	// another row, which must not wait for the flush.
+	flushWhile := func(pingErr error, id string) bool {
+		flushed := make(chan bool)
+		go func() { flushed <- store.flush(ctx) }()
+		<-pinged
+		saved := make(chan error)
+		go func() { saved <- store.Save(ctx, []ProductURL{row(id)}) }()
+		select {
+		case err := <-saved:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Save blocked behind a flush")
+		}
+		answer <- pingErr
+		return <-flushed
+	}
+
+	if flushWhile(errors.New("connection refused"), "BBBBBBBB") {
+		t.Error("flush reported success with the database down")
+	}
+	want := []string{row("AAAAAAAA").URL, row("BBBBBBBB").URL}
+	if got := buffered(); !reflect.DeepEqual(got, want) {
+		t.Errorf("buffered %q after a failed flush, want %q", got, want)
+	}
+
+	if flushWhile(nil, "CCCCCCCC") {
+		t.Error("flush reported an empty buffer with a row added meanwhile")
+	}
+	if got, want := buffered(), []string{row("CCCCCCCC").URL}; !reflect.DeepEqual(got, want) {
+		t.Errorf("buffered %q after flushing, want %q", got, want)
+	}
+	records, err := store.Records(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Errorf("stored %d URLs, want the 2 flushed", len(records))
+	}
+}